@@ -0,0 +1,154 @@
+// Package auth provides password hashing and token handling for the blog
+// service.
+//
+// This file contains the HashPassword and CheckPassword functions.
+//
+// Passwords are hashed with argon2id, the algorithm recommended by OWASP.
+// CheckPassword also accepts bcrypt hashes, so accounts imported from other
+// systems keep working.
+//
+// For more information on password storage, see:
+// https://cheatsheetseries.owasp.org/cheatsheets/Password_Storage_Cheat_Sheet.html
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The argon2id parameters. Memory is in KiB, so 64 MiB.
+const (
+	argonTime    = 1
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+// DefaultMaxConcurrentHashes is how many passwords are hashed or checked at
+// once unless SetMaxConcurrentHashes says otherwise.
+const DefaultMaxConcurrentHashes = 4
+
+// hashSlots limits how many argon2id hashes are computed at once. Each one
+// takes argonMemory, so a burst of logins would otherwise take as much
+// memory as there are requests. A hash takes a slot by sending to the
+// channel and gives it back by receiving from it.
+var hashSlots = make(chan struct{}, DefaultMaxConcurrentHashes)
+
+// SetMaxConcurrentHashes sets how many passwords are hashed or checked at
+// once; the others wait for their turn. It must be called before the
+// server starts, since it isn't safe to call while passwords are hashed.
+func SetMaxConcurrentHashes(n int) {
+	hashSlots = make(chan struct{}, n)
+}
+
+// idKey is argon2.IDKey, run in one of the hashSlots.
+func idKey(password, salt []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	hashSlots <- struct{}{}
+	defer func() { <-hashSlots }()
+
+	return argon2.IDKey(password, salt, time, memory, threads, keyLen)
+}
+
+// ErrUnknownHash is returned by CheckPassword for hashes in an unknown format.
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// HashPassword returns an argon2id hash of the password.
+//
+// The hash is in the PHC string format, which contains the parameters and the
+// random salt, so it can be checked even if the parameters change later:
+//
+//	$argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := idKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		argonMemory,
+		argonTime,
+		argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// CheckPassword reports whether the password matches the hash.
+// The hash can be an argon2id hash from HashPassword or a bcrypt hash.
+func CheckPassword(hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return checkArgon2id(hash, password)
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, ErrUnknownHash
+	}
+}
+
+// checkArgon2id checks a password against an argon2id hash in the PHC format.
+func checkArgon2id(hash, password string) (bool, error) {
+	// The hash looks like "$argon2id$v=19$m=65536,t=1,p=4$salt$key", so
+	// splitting it on "$" gives an empty string followed by five parts.
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, ErrUnknownHash
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, ErrUnknownHash
+	}
+	// argon2.IDKey panics with no passes or no threads.
+	if time < 1 || threads < 1 {
+		return false, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		// An empty key would match the empty key derived from any password.
+		return false, ErrUnknownHash
+	}
+
+	other := idKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+
+	// subtle.ConstantTimeCompare takes the same time whether or not the keys
+	// match, so an attacker can't learn anything from how long it takes.
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// dummyHash is a valid hash of a random password.
+var dummyHash, _ = HashPassword("dummy password used to equalize timing")
+
+// CheckNoPassword does the same amount of work as CheckPassword, and always
+// fails. Call it when a user doesn't exist, so that an attacker can't find
+// out which email addresses have an account by timing the login.
+func CheckNoPassword(password string) {
+	CheckPassword(dummyHash, password)
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+
+	ok, err := CheckPassword(hash, "correct horse")
+	if err != nil || !ok {
+		t.Errorf("CheckPassword(right password) = %v, %v; want true, nil", ok, err)
+	}
+	ok, err = CheckPassword(hash, "battery staple")
+	if err != nil || ok {
+		t.Errorf("CheckPassword(wrong password) = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestCheckPasswordBcrypt(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+
+	if ok, err := CheckPassword(string(hash), "correct horse"); err != nil || !ok {
+		t.Errorf("CheckPassword(right password) = %v, %v; want true, nil", ok, err)
+	}
+	if ok, err := CheckPassword(string(hash), "battery staple"); err != nil || ok {
+		t.Errorf("CheckPassword(wrong password) = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestCheckPasswordMalformed(t *testing.T) {
+	// The salt and key are "salt" and "key" in base64.
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"unknown algorithm", "$argon2i$v=19$m=65536,t=1,p=4$c2FsdA$a2V5"},
+		{"missing part", "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA"},
+		{"wrong version", "$argon2id$v=16$m=65536,t=1,p=4$c2FsdA$a2V5"},
+		{"bad parameters", "$argon2id$v=19$m=x,t=1,p=4$c2FsdA$a2V5"},
+		{"no passes", "$argon2id$v=19$m=65536,t=0,p=4$c2FsdA$a2V5"},
+		{"no threads", "$argon2id$v=19$m=65536,t=1,p=0$c2FsdA$a2V5"},
+		{"bad salt", "$argon2id$v=19$m=65536,t=1,p=4$!!!$a2V5"},
+		{"empty key", "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := CheckPassword(tt.hash, "password")
+			if ok || !errors.Is(err, ErrUnknownHash) {
+				t.Errorf("CheckPassword(%q) = %v, %v; want false, ErrUnknownHash", tt.hash, ok, err)
+			}
+		})
+	}
+}
+
+func TestHashPasswordWaitsForSlot(t *testing.T) {
+	old := hashSlots
+	SetMaxConcurrentHashes(1)
+	t.Cleanup(func() { hashSlots = old })
+
+	// Take the only slot, as another hash would.
+	hashSlots <- struct{}{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := HashPassword("correct horse"); err != nil {
+			t.Errorf("HashPassword: %v", err)
+		}
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("HashPassword ran while all the slots were taken")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-hashSlots
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("HashPassword didn't run after the slot was given back")
+	}
+}
@@ -0,0 +1,345 @@
+// This file contains the TokenManager, which issues and verifies JSON Web
+// Tokens (JWT) used as access tokens.
+//
+// A JWT is three base64url-encoded parts separated by dots:
+//
+//	header.claims.signature
+//
+// The header names the signing algorithm, the claims say who the token was
+// issued to and until when it is valid, and the signature proves that we
+// issued it. Two algorithms are supported: HS256 (HMAC with SHA-256 and a
+// shared secret) and EdDSA (Ed25519 signatures with a private key).
+//
+// For more information on JWT, see:
+// https://www.rfc-editor.org/rfc/rfc7519
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The supported signing algorithms.
+const (
+	HS256 = "HS256"
+	EdDSA = "EdDSA"
+)
+
+// minHMACSecretLength is the minimum length of the HS256 secret in bytes.
+// A shorter secret could be guessed by brute force.
+const minHMACSecretLength = 32
+
+// leeway is how far the clocks of different servers may be apart.
+const leeway = 30 * time.Second
+
+// ErrInvalidToken is returned for tokens that are malformed, have a wrong
+// signature, or are expired.
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenConfig contains the settings of a TokenManager.
+//
+// SigningMethod is the algorithm used to sign new tokens. Tokens signed with
+// the other algorithm are still accepted if its key is configured, which
+// allows switching algorithms without logging everybody out.
+//
+// Ed25519PrivateKey is a PEM-encoded PKCS#8 private key, as generated by
+// "openssl genpkey -algorithm ed25519".
+type TokenConfig struct {
+	SigningMethod     string
+	HMACSecret        string
+	Ed25519PrivateKey string
+	Issuer            string
+	AccessTokenTTL    time.Duration
+	RefreshTokenTTL   time.Duration
+}
+
+// Claims are the claims of an access token.
+//
+// Subject is the ID of the user, as a string, as required by the JWT
+// specification. Use UserID to get it as an int.
+//
+// Role is the role of the user when the token was issued. A new role takes
+// effect when the token is refreshed.
+type Claims struct {
+	ID        string `json:"jti"`
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+}
+
+// UserID returns the ID of the user the token was issued to.
+func (c Claims) UserID() int {
+	id, _ := strconv.Atoi(c.Subject)
+	return id
+}
+
+// header is the header of a JWT.
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+// TokenManager issues and verifies access tokens, and generates refresh
+// tokens.
+type TokenManager struct {
+	config     TokenConfig
+	hmacSecret []byte
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+
+	// now returns the current time. It is a field so that it can be replaced.
+	now func() time.Time
+}
+
+// NewTokenManager creates a new TokenManager.
+// It returns an error if the key for the signing method is missing or invalid.
+func NewTokenManager(config TokenConfig) (*TokenManager, error) {
+	m := &TokenManager{config: config, now: time.Now}
+
+	if config.HMACSecret != "" {
+		if len(config.HMACSecret) < minHMACSecretLength {
+			return nil, fmt.Errorf("the HS256 secret must be at least %d bytes long", minHMACSecretLength)
+		}
+		m.hmacSecret = []byte(config.HMACSecret)
+	}
+
+	if config.Ed25519PrivateKey != "" {
+		key, err := parseEd25519PrivateKey(config.Ed25519PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		m.privateKey = key
+		m.publicKey = key.Public().(ed25519.PublicKey)
+	}
+
+	switch config.SigningMethod {
+	case HS256:
+		if m.hmacSecret == nil {
+			return nil, errors.New("the HS256 signing method needs a secret")
+		}
+	case EdDSA:
+		if m.privateKey == nil {
+			return nil, errors.New("the EdDSA signing method needs an Ed25519 private key")
+		}
+	default:
+		return nil, fmt.Errorf("unknown signing method %q (expected %s or %s)", config.SigningMethod, HS256, EdDSA)
+	}
+
+	if config.AccessTokenTTL <= 0 || config.RefreshTokenTTL <= 0 {
+		return nil, errors.New("the access and refresh token lifetimes must be positive")
+	}
+
+	return m, nil
+}
+
+// parseEd25519PrivateKey parses a PEM-encoded PKCS#8 Ed25519 private key.
+func parseEd25519PrivateKey(s string) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("the Ed25519 private key is not PEM-encoded")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the Ed25519 private key: %w", err)
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the private key is a %T, not an Ed25519 key", key)
+	}
+
+	return edKey, nil
+}
+
+// AccessTokenTTL returns how long access tokens are valid.
+func (m *TokenManager) AccessTokenTTL() time.Duration {
+	return m.config.AccessTokenTTL
+}
+
+// RefreshTokenTTL returns how long refresh tokens are valid.
+func (m *TokenManager) RefreshTokenTTL() time.Duration {
+	return m.config.RefreshTokenTTL
+}
+
+// IssueAccessToken returns a signed access token for the user, and the time
+// at which it expires.
+func (m *TokenManager) IssueAccessToken(userID int, name, role string) (string, time.Time, error) {
+	now := m.now()
+	expiresAt := now.Add(m.config.AccessTokenTTL)
+
+	id, err := randomString(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims := Claims{
+		ID:        id,
+		Issuer:    m.config.Issuer,
+		Subject:   strconv.Itoa(userID),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+		Name:      name,
+		Role:      role,
+	}
+
+	token, err := m.sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// sign encodes the claims and signs them with the configured signing method.
+func (m *TokenManager) sign(claims Claims) (string, error) {
+	h, err := json.Marshal(header{Algorithm: m.config.SigningMethod, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodeSegment(h) + "." + encodeSegment(c)
+
+	var signature []byte
+	switch m.config.SigningMethod {
+	case HS256:
+		mac := hmac.New(sha256.New, m.hmacSecret)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case EdDSA:
+		signature = ed25519.Sign(m.privateKey, []byte(signingInput))
+	}
+
+	return signingInput + "." + encodeSegment(signature), nil
+}
+
+// VerifyAccessToken checks the signature, issuer and expiry of an access
+// token and returns its claims.
+// It returns an error wrapping ErrInvalidToken if the token is not valid.
+func (m *TokenManager) VerifyAccessToken(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed header", ErrInvalidToken)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+
+	// Only accept the algorithms we have a key for. In particular, this
+	// rejects the "none" algorithm, which has no signature at all.
+	signingInput := []byte(parts[0] + "." + parts[1])
+	switch {
+	case h.Algorithm == HS256 && m.hmacSecret != nil:
+		mac := hmac.New(sha256.New, m.hmacSecret)
+		mac.Write(signingInput)
+		// hmac.Equal compares in constant time.
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return Claims{}, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	case h.Algorithm == EdDSA && m.publicKey != nil:
+		if !ed25519.Verify(m.publicKey, signingInput, signature) {
+			return Claims{}, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	default:
+		return Claims{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, h.Algorithm)
+	}
+
+	// The signature is valid, so we can trust the claims.
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+
+	now := m.now()
+	switch {
+	case claims.Issuer != m.config.Issuer:
+		return Claims{}, fmt.Errorf("%w: wrong issuer", ErrInvalidToken)
+	case now.After(time.Unix(claims.ExpiresAt, 0).Add(leeway)):
+		return Claims{}, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case now.Before(time.Unix(claims.IssuedAt, 0).Add(-leeway)):
+		return Claims{}, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	case claims.UserID() < 1:
+		return Claims{}, fmt.Errorf("%w: bad subject", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// NewRefreshToken returns a new random refresh token and its hash.
+//
+// Refresh tokens are not JWTs but random strings. Only the hash is stored in
+// the database, so a leaked database doesn't leak usable tokens.
+func (m *TokenManager) NewRefreshToken() (token, hash string, err error) {
+	token, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken returns the hash of a refresh token, as stored in the
+// database.
+//
+// A fast hash like SHA-256 is fine here, unlike for passwords, because the
+// tokens are long random strings that can't be guessed.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewTokenFamily returns a new random ID for a family of refresh tokens.
+//
+// Every refresh token that is obtained by rotating another one belongs to the
+// same family as the original, so that the whole chain can be revoked at once.
+func NewTokenFamily() (string, error) {
+	return randomString(16)
+}
+
+// randomString returns n random bytes encoded as base64url.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// encodeSegment encodes a part of a JWT.
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeSegment decodes a part of a JWT into v.
+func decodeSegment(s string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
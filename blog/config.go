@@ -0,0 +1,62 @@
+// This file contains the config subcommand, which checks the configuration.
+//
+// Usage:
+//
+//	blog [flags] config check [file]  Check the config and print it
+//
+// The config is loaded like when the blog starts: the file given with
+// --config (or the one given here), the profile, the environment variables
+// and the flags. It is printed with the secrets redacted, followed by the
+// problems found, if any.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"blog/config"
+)
+
+// runConfig runs the config subcommand with the given arguments. cfg and
+// loadErr are what config.Load returned; flags are the flags before the
+// subcommand, which are needed to load another file.
+// It returns an error if the arguments are invalid or the config has
+// problems.
+func runConfig(cfg *config.Config, loadErr error, flags, args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return fmt.Errorf("usage: config check [file]")
+	}
+	if len(args) > 2 {
+		return fmt.Errorf("usage: config check [file]")
+	}
+
+	// A file given here takes the place of the one given with --config.
+	// The last --config flag wins, so it is added after the others.
+	if len(args) == 2 {
+		var err error
+		flags = append(append([]string{}, flags...), "--config", args[1])
+		cfg, _, err = config.Load(flags)
+		loadErr = err
+	}
+
+	var invalid *config.ValidationError
+	if loadErr != nil && !errors.As(loadErr, &invalid) {
+		return loadErr
+	}
+
+	if cfg != nil {
+		out, err := cfg.RedactedYAML()
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(out)
+	}
+
+	if invalid != nil {
+		return invalid
+	}
+
+	fmt.Fprintln(os.Stderr, "config is valid")
+	return nil
+}
@@ -0,0 +1,24 @@
+package config
+
+import "testing"
+
+func TestSecureCookies(t *testing.T) {
+	tests := []struct {
+		publicURL string
+		certFile  string
+		want      bool
+	}{
+		{"http://localhost:8080", "", false},
+		{"https://blog.example.com", "", true},
+		{"HTTPS://blog.example.com", "", true},
+		{"http://localhost:8443", "cert.pem", true},
+	}
+	for _, tt := range tests {
+		var c Config
+		c.Server.PublicURL = tt.publicURL
+		c.Server.TLS.CertFile = tt.certFile
+		if got := c.SecureCookies(); got != tt.want {
+			t.Errorf("SecureCookies() with public URL %q and cert file %q = %t, want %t", tt.publicURL, tt.certFile, got, tt.want)
+		}
+	}
+}
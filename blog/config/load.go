@@ -0,0 +1,266 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// DefaultFile is the config file that is read when no other one is given.
+// It is relative to the working directory, and it is optional: without it,
+// the config comes from the defaults, the environment and the flags.
+const DefaultFile = "config/config.yaml"
+
+// EnvPrefix is the prefix of the environment variables that set config
+// values. The rest of the name is the key in upper case, with underscores
+// instead of dots: BLOG_SERVER_PORT sets server.port.
+const EnvPrefix = "BLOG"
+
+// defaults are the values of the settings that are set nowhere else.
+// Secrets, such as the database password and the token keys, have no
+// defaults.
+var defaults = map[string]any{
+	"server.port":                8080,
+	"server.public_url":          "http://localhost:8080",
+	"server.read_header_timeout": 5 * time.Second,
+	"server.read_timeout":        15 * time.Second,
+	"server.write_timeout":       30 * time.Second,
+	"server.idle_timeout":        120 * time.Second,
+	"server.max_header_bytes":    1 << 20,
+	"server.shutdown_timeout":    20 * time.Second,
+	"server.tls.reload_interval": time.Minute,
+
+	"database.driver":       "postgres",
+	"database.host":         "localhost",
+	"database.port":         5432,
+	"database.dbname":       "blog",
+	"database.auto_migrate": true,
+
+	"auth.signing_method":        "HS256",
+	"auth.issuer":                "blog",
+	"auth.max_concurrent_hashes": 4,
+	"auth.access_token_ttl":      15 * time.Minute,
+	"auth.refresh_token_ttl":     720 * time.Hour,
+
+	"scheduler.interval":     30 * time.Second,
+	"scheduler.batch_size":   100,
+	"scheduler.stop_timeout": 10 * time.Second,
+
+	"trash.retention":      720 * time.Hour,
+	"trash.purge_interval": time.Hour,
+	"trash.batch_size":     100,
+}
+
+// profileName is what a profile name may look like. The name becomes part
+// of a file name, so it must not contain slashes or dots.
+var profileName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Load loads the configuration from these layers, each of which overrides
+// the ones before it:
+//
+//  1. the defaults above;
+//  2. the config file, which is given with --config or BLOG_CONFIG, or else
+//     DefaultFile if it exists;
+//  3. the file of the profile, which is given with --profile or
+//     BLOG_PROFILE, for example "prod" for config.prod.yaml next to the
+//     config file;
+//  4. the environment variables, such as BLOG_DATABASE_PASSWORD;
+//  5. the flags, such as --server.port=9000.
+//
+// Secrets can also be read from files, such as database.password_file,
+// which take the place of the secrets themselves; see secrets.go.
+//
+// args are the command-line arguments without the program name. The flags
+// come first; the arguments after them, such as "migrate up", are returned.
+// With -h or --help, the usage is printed and flag.ErrHelp is returned.
+//
+// The config is checked against the rules of its settings, and unknown keys
+// in the files and unknown BLOG_ environment variables are reported, since
+// they are usually typos. If there are problems, Load returns a
+// *ValidationError that lists all of them, together with the config and the
+// arguments, so that they can still be shown.
+func Load(args []string) (*Config, []string, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+
+	// Every setting can be set with an environment variable and a flag.
+	// viper only finds the environment variables that it knows about, so
+	// they are bound one by one.
+	keys := settingKeys(reflect.TypeOf(Config{}), "")
+	for _, key := range keys {
+		if err := v.BindEnv(key, envName(key)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	fset := flag.NewFlagSet("blog", flag.ContinueOnError)
+	file := fset.String("config", os.Getenv(EnvPrefix+"_CONFIG"), "the config file (default "+DefaultFile+")")
+	profile := fset.String("profile", os.Getenv(EnvPrefix+"_PROFILE"), "the profile, such as dev, test or prod")
+	overrides := map[string]string{}
+	for _, key := range keys {
+		key := key
+		fset.Func(key, "sets "+key, func(value string) error {
+			overrides[key] = value
+			return nil
+		})
+	}
+	fset.Usage = func() {
+		fmt.Fprintf(fset.Output(), "Usage: blog [flags] [config|migrate|users ...]\n\nFlags:\n")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	known := map[string]bool{}
+	for _, key := range keys {
+		known[key] = true
+	}
+
+	problems, err := readFiles(v, *file, *profile, known)
+	if err != nil {
+		return nil, nil, err
+	}
+	problems = append(problems, unknownEnv(known)...)
+
+	// Set has the highest priority in viper, above the environment.
+	for key, value := range overrides {
+		v.Set(key, value)
+	}
+
+	// Unmarshal the settings into a Config struct. The strings of the
+	// environment and the flags are converted to numbers, booleans and
+	// durations as needed. If a value can't be converted, the rules aren't
+	// checked, because the setting would be reported twice.
+	// For more information on viper, see:
+	// https://github.com/spf13/viper
+	var cfg *Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		problems = append(problems, decodeProblems(err)...)
+	} else {
+		// The secrets from files are read first, so that the rules of the
+		// secrets apply to them as well.
+		problems = append(problems, cfg.readSecretFiles()...)
+		problems = append(problems, cfg.validate()...)
+	}
+
+	if len(problems) > 0 {
+		return cfg, fset.Args(), &ValidationError{Problems: problems}
+	}
+
+	// Set Cfg to the Config struct.
+	Cfg = cfg
+
+	return cfg, fset.Args(), nil
+}
+
+// decodeProblems returns the problems of an error returned by Unmarshal,
+// which lists all the values that couldn't be converted.
+func decodeProblems(err error) []string {
+	var decodeErr *mapstructure.Error
+	if !errors.As(err, &decodeErr) {
+		return []string{err.Error()}
+	}
+
+	problems := make([]string, len(decodeErr.Errors))
+	copy(problems, decodeErr.Errors)
+	sort.Strings(problems)
+	return problems
+}
+
+// readFiles reads the config file and the file of the profile into v, and
+// returns a problem for every key in them that isn't a setting.
+//
+// A config file that was given must exist. DefaultFile may be missing, so
+// that the config can come from the environment alone, for example in a
+// container. The file of a profile must always exist.
+func readFiles(v *viper.Viper, file, profile string, known map[string]bool) ([]string, error) {
+	explicit := file != ""
+	if !explicit {
+		file = DefaultFile
+	}
+
+	problems, err := mergeFile(v, file, known)
+	if err != nil {
+		if explicit || !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	if profile == "" {
+		return problems, nil
+	}
+	if !profileName.MatchString(profile) {
+		return nil, fmt.Errorf("invalid profile %q", profile)
+	}
+
+	// The profile file is merged into the config file, so it only needs the
+	// settings that are different.
+	profileFile := filepath.Join(filepath.Dir(file), "config."+profile+".yaml")
+	profileProblems, err := mergeFile(v, profileFile, known)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile %s: %w", profile, err)
+	}
+
+	return append(problems, profileProblems...), nil
+}
+
+// mergeFile reads a config file and merges it into v. Each file is read on
+// its own first, so that the unknown keys can be reported with the file
+// they are in.
+func mergeFile(v *viper.Viper, file string, known map[string]bool) ([]string, error) {
+	f := viper.New()
+	f.SetConfigFile(file)
+	f.SetConfigType("yaml")
+	if err := f.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	if err := v.MergeConfigMap(f.AllSettings()); err != nil {
+		return nil, err
+	}
+
+	keys := f.AllKeys()
+	sort.Strings(keys)
+	return unknownKeys(file, keys, known), nil
+}
+
+// settingKeys returns the keys of all the settings in a Config, such as
+// "server.port", from the mapstructure tags of the struct type t.
+func settingKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			keys = append(keys, settingKeys(field.Type, prefix+name+".")...)
+		} else {
+			keys = append(keys, prefix+name)
+		}
+	}
+	return keys
+}
+
+// envName returns the name of the environment variable of a setting.
+func envName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces the values of secrets in the output of RedactedYAML.
+const redacted = "[redacted]"
+
+// RedactedYAML returns the config as YAML, in the same layout as the config
+// file, with the values of the settings that have a secret tag replaced by
+// "[redacted]". Secrets that aren't set are shown as empty, so that it is
+// still visible that they are missing.
+func (c *Config) RedactedYAML() ([]byte, error) {
+	return yaml.Marshal(yamlNode(reflect.ValueOf(*c)))
+}
+
+// yamlNode returns a YAML mapping with the settings of a struct, in the
+// order of its fields.
+func yamlNode(v reflect.Value) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" {
+			continue
+		}
+
+		value := &yaml.Node{}
+		fv := v.Field(i)
+		switch {
+		case fv.Kind() == reflect.Struct:
+			value = yamlNode(fv)
+		case field.Tag.Get("secret") == "true" && !fv.IsZero():
+			value.SetString(redacted)
+		case fv.Type() == reflect.TypeOf(time.Duration(0)):
+			// Durations are shown as in the config file, like "15m0s",
+			// rather than as a number of nanoseconds.
+			value.SetString(fv.Interface().(time.Duration).String())
+		default:
+			if err := value.Encode(fv.Interface()); err != nil {
+				value.SetString(err.Error())
+			}
+		}
+
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, value)
+	}
+
+	return node
+}
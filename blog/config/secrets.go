@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// secretFile is a setting that names a file that contains a secret, and the
+// secret it sets.
+type secretFile struct {
+	key    string
+	file   string
+	secret *string
+}
+
+// secretFiles returns the settings of the config that name secret files.
+func (c *Config) secretFiles() []secretFile {
+	return []secretFile{
+		{"database.password_file", c.Database.PasswordFile, &c.Database.Password},
+		{"auth.hmac_secret_file", c.Auth.HMACSecretFile, &c.Auth.HMACSecret},
+		{"auth.ed25519_private_key_file", c.Auth.Ed25519PrivateKeyFile, &c.Auth.Ed25519PrivateKey},
+	}
+}
+
+// readSecretFiles reads the secrets from the files that are set, such as
+// the secrets that Docker and Kubernetes mount into containers, and returns
+// a problem for every file that can't be read.
+//
+// Secret files usually end with a newline, which is removed.
+func (c *Config) readSecretFiles() []string {
+	var problems []string
+	for _, sf := range c.secretFiles() {
+		if sf.file == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(sf.file)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", sf.key, err))
+			continue
+		}
+		secret := strings.TrimRight(string(data), "\r\n")
+		if secret == "" {
+			problems = append(problems, fmt.Sprintf("%s: %s is empty", sf.key, sf.file))
+			continue
+		}
+
+		*sf.secret = secret
+	}
+	return problems
+}
+
+// MinRedactedLength is the length of the shortest secret that is redacted
+// from the logs. Shorter ones, such as "pw", are likely to be part of other
+// words and would garble the logs rather than protect anything.
+const MinRedactedLength = 8
+
+// Secrets returns the values of the settings that have a secret tag, and
+// the forms they take in the database URL, so that they can be removed from
+// the logs. Secrets that aren't set are left out, and so are those shorter
+// than MinRedactedLength; ShortSecrets lists them.
+func (c *Config) Secrets() []string {
+	var secrets []string
+	collectSecrets(reflect.ValueOf(*c), "", func(key, value string) {
+		if len(value) >= MinRedactedLength {
+			secrets = append(secrets, value)
+		}
+	})
+
+	for _, s := range append([]string{}, secrets...) {
+		// A secret in a URL is escaped, so it may look different there.
+		if escaped := url.QueryEscape(s); escaped != s {
+			secrets = append(secrets, escaped)
+		}
+		// The user info of the URL is ":" followed by the escaped password.
+		if escaped := url.UserPassword("", s).String()[1:]; escaped != s {
+			secrets = append(secrets, escaped)
+		}
+	}
+
+	return secrets
+}
+
+// ShortSecrets returns the keys of the secrets that are set but are shorter
+// than MinRedactedLength, which Secrets leaves out, so that a warning can
+// say that they are not redacted.
+func (c *Config) ShortSecrets() []string {
+	var keys []string
+	collectSecrets(reflect.ValueOf(*c), "", func(key, value string) {
+		if len(value) < MinRedactedLength {
+			keys = append(keys, key)
+		}
+	})
+	return keys
+}
+
+// collectSecrets calls fn with the key and the value of every field of a
+// struct that has a secret tag and is set. prefix is the key of the struct,
+// such as "database.", or "" for the Config itself.
+func collectSecrets(v reflect.Value, prefix string, fn func(key, value string)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		key := prefix + field.Tag.Get("mapstructure")
+		switch {
+		case fv.Kind() == reflect.Struct:
+			collectSecrets(fv, key+".", fn)
+		case field.Tag.Get("secret") == "true" && fv.String() != "":
+			fn(key, fv.String())
+		}
+	}
+}
+
+// RedactingWriter returns a writer that writes to w with every secret
+// replaced by "[redacted]". Secrets shorter than MinRedactedLength are left
+// alone. It is meant for the logs, which are written a
+// line at a time, so that a secret that was logged by mistake, for example
+// in an error of the database driver, doesn't end up in them.
+func RedactingWriter(w io.Writer, secrets []string) io.Writer {
+	// The replacer tries the secrets in order, so the longest come first;
+	// otherwise a secret that contains another one would only be redacted
+	// in part.
+	secrets = append([]string{}, secrets...)
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+
+	var pairs []string
+	for _, s := range secrets {
+		if len(s) >= MinRedactedLength {
+			pairs = append(pairs, s, redacted)
+		}
+	}
+
+	return &redactingWriter{w: w, replacer: strings.NewReplacer(pairs...)}
+}
+
+// redactingWriter is the writer returned by RedactingWriter.
+type redactingWriter struct {
+	w        io.Writer
+	replacer *strings.Replacer
+}
+
+// Write writes p to the underlying writer with the secrets replaced. It
+// returns len(p) on success, since the caller doesn't know that fewer or
+// more bytes were written.
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, r.replacer.Replace(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,290 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidationError is returned by Load when the config is invalid. It lists
+// all the problems at once, so that they can be fixed in one go rather
+// than one restart at a time.
+type ValidationError struct {
+	Problems []string
+}
+
+// Error returns the problems, one per line.
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// validate is the validator of the config. It reports fields by their key,
+// for example "server.port", rather than by their Go name.
+//
+// For more information on the validator, see:
+// https://pkg.go.dev/github.com/go-playground/validator/v10
+var validate = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("mapstructure")
+	})
+	return v
+}()
+
+// placeholderSecrets are HS256 secrets that have been published, such as
+// the one config.yaml used to ship. They are long enough to pass the min
+// rule, but anyone can sign tokens with them. Shorter placeholders, such as
+// "secret" or "changeme", are already rejected by the min rule.
+var placeholderSecrets = []string{
+	"change-me-this-is-not-a-secret-at-all",
+}
+
+// validate checks the config against the rules in the validate tags of the
+// Config struct, and a few rules that involve settings in different parts
+// of it. It returns the problems it finds.
+func (c *Config) validate() []string {
+	var problems []string
+
+	var fieldErrs validator.ValidationErrors
+	if err := validate.Struct(c); errors.As(err, &fieldErrs) {
+		for _, fe := range fieldErrs {
+			problems = append(problems, fieldKey(fe)+": "+fieldMessage(fe))
+		}
+	} else if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	// The url rule only checks that there is a scheme, which may be any.
+	if u, err := url.Parse(c.Server.PublicURL); err == nil && u.Scheme != "" {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			problems = append(problems, "server.public_url: must be an http or https URL")
+		} else if u.RawQuery != "" || u.Fragment != "" {
+			problems = append(problems, "server.public_url: must not have a query or a fragment")
+		}
+	}
+
+	// Configs that were copied from an old config.yaml must not sign tokens
+	// with its secret.
+	for _, placeholder := range placeholderSecrets {
+		if c.Auth.HMACSecret == placeholder {
+			problems = append(problems, "auth.hmac_secret: is a published placeholder; set a random secret, for example from \"openssl rand -base64 48\"")
+		}
+	}
+
+	if c.Server.TLS.RedirectPort != 0 && c.Server.TLS.RedirectPort == c.Server.Port {
+		problems = append(problems, "server.tls.redirect_port: must be different from server.port")
+	}
+
+	return problems
+}
+
+// fieldKey returns the key of an invalid field, for example "server.port".
+//
+// fe.Namespace returns the path including the name of the struct, for
+// example "Config.server.port", so we remove the first part.
+func fieldKey(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+// siblingKey returns the key of the field named goName in the same section
+// as fe, for the messages of the rules that refer to another field.
+func siblingKey(fe validator.FieldError, goName string) string {
+	key := fieldKey(fe)
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		return key[:i+1] + snakeCase(goName)
+	}
+	return snakeCase(goName)
+}
+
+// fieldMessage returns a message for a failed rule that says what is
+// expected.
+func fieldMessage(fe validator.FieldError) string {
+	param := fe.Param()
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_if":
+		// The parameter is the other field and its value: "Driver postgres".
+		parts := strings.SplitN(param, " ", 2)
+		if len(parts) == 2 {
+			return fmt.Sprintf("is required when %s is %s", siblingKey(fe, parts[0]), parts[1])
+		}
+		return "is required"
+	case "required_with":
+		return "is required when " + siblingKey(fe, param) + " is set"
+	case "excluded_without":
+		return "can only be set together with " + siblingKey(fe, param)
+	case "min":
+		if fe.Kind() == reflect.String {
+			return "must be at least " + param + " characters long"
+		}
+		return "must be at least " + param
+	case "max":
+		return "must be at most " + param
+	case "gt":
+		return "must be greater than " + param
+	case "gte":
+		return "must not be negative"
+	case "gtfield":
+		return "must be greater than " + siblingKey(fe, param)
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(param, " ", ", ")
+	case "url":
+		return fmt.Sprintf("%q is not a URL, such as https://blog.example.com", fe.Value())
+	case "file":
+		return fmt.Sprintf("%q is not an existing file", fe.Value())
+	default:
+		return "is invalid (failed on the '" + fe.Tag() + "' rule)"
+	}
+}
+
+// snakeCase converts a Go field name to the style of the keys:
+// "SigningMethod" becomes "signing_method", and "AccessTokenTTL" becomes
+// "access_token_ttl".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// unknownKeys returns a problem for every key of a config file that isn't
+// a setting, which is usually a typo that would otherwise be ignored.
+func unknownKeys(file string, keys []string, known map[string]bool) []string {
+	var problems []string
+	for _, key := range keys {
+		if known[key] || isSection(key, known) {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("%s: unknown key in %s%s", key, file, suggestion(key, known)))
+	}
+	return problems
+}
+
+// isSection reports whether key is the name of a section, such as
+// "server.tls", which is what an empty section in a file looks like.
+func isSection(key string, known map[string]bool) bool {
+	for k := range known {
+		if strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownEnv returns a problem for every environment variable with the
+// prefix of the config that doesn't set a setting.
+func unknownEnv(known map[string]bool) []string {
+	names := map[string]string{}
+	for key := range known {
+		names[envName(key)] = key
+	}
+
+	var problems []string
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, EnvPrefix+"_") || name == EnvPrefix+"_CONFIG" || name == EnvPrefix+"_PROFILE" {
+			continue
+		}
+		if _, ok := names[name]; ok {
+			continue
+		}
+
+		// Suggest the setting whose variable is closest to the name.
+		key := strings.ToLower(strings.TrimPrefix(name, EnvPrefix+"_"))
+		var hint string
+		if best, ok := closest(key, names, func(env string) string {
+			return strings.ToLower(strings.TrimPrefix(env, EnvPrefix+"_"))
+		}); ok {
+			hint = fmt.Sprintf(" (did you mean %s?)", best)
+		}
+		problems = append(problems, fmt.Sprintf("%s: unknown environment variable%s", name, hint))
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// suggestion returns " (did you mean x?)" with the setting closest to an
+// unknown key, or an empty string if none is close.
+func suggestion(key string, known map[string]bool) string {
+	if best, ok := closest(key, known, func(k string) string { return k }); ok {
+		return fmt.Sprintf(" (did you mean %s?)", best)
+	}
+	return ""
+}
+
+// closest returns the candidate whose normalized form is closest to s, if
+// it is at most a few edits away. Candidates are compared in sorted order,
+// so the result doesn't depend on the order of the map.
+func closest[V any](s string, candidates map[string]V, normalize func(string) string) (string, bool) {
+	names := make([]string, 0, len(candidates))
+	for name := range candidates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", 3
+	for _, name := range names {
+		if d := editDistance(s, normalize(name)); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best, best != ""
+}
+
+// editDistance returns the Levenshtein distance between a and b: the
+// number of characters that must be inserted, deleted or replaced to turn
+// one into the other.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+// minInt returns the smallest of its arguments.
+func minInt(first int, rest ...int) int {
+	m := first
+	for _, n := range rest {
+		if n < m {
+			m = n
+		}
+	}
+	return m
+}
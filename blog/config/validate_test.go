@@ -0,0 +1,35 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// The test profile is in memory, so the config needs no database.
+func TestValidateHMACSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		want   string // A part of the error, or "" if the secret is valid.
+	}{
+		{"empty", "", "auth.hmac_secret: is required when auth.signing_method is HS256"},
+		{"short", "secret", "auth.hmac_secret: must be at least 32 characters long"},
+		{"31 characters", strings.Repeat("x", 31), "auth.hmac_secret: must be at least 32 characters long"},
+		{"32 characters", strings.Repeat("x", 32), ""},
+		{"placeholder", "change-me-this-is-not-a-secret-at-all", "auth.hmac_secret: is a published placeholder"},
+		{"random with change-me", "change-me-0123456789abcdef0123456789abcdef", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Load([]string{"--config", "config.yaml", "--profile", "test", "--auth.hmac_secret=" + tt.secret})
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Load with secret %q: %v", tt.secret, err)
+			case tt.want != "" && err == nil:
+				t.Errorf("Load with secret %q succeeded, want an error", tt.secret)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("Load with secret %q: %v, want an error containing %q", tt.secret, err, tt.want)
+			}
+		})
+	}
+}
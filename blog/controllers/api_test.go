@@ -0,0 +1,343 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"blog/auth"
+	"blog/controllers"
+	"blog/forms"
+	"blog/models"
+	"blog/policy"
+	"blog/server"
+	"blog/web"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the request log of gin out of the test output.
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	os.Exit(m.Run())
+}
+
+// testAPI is the whole router of the blog, with the memory stores, as the
+// server serves it.
+type testAPI struct {
+	t      *testing.T
+	router http.Handler
+	users  *models.MemoryUserModel
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+
+	users := models.NewMemoryUserModel()
+	blogs := models.NewMemoryBlogModel(users)
+	refreshTokens := models.NewMemoryTokenModel()
+
+	tokens, err := auth.NewTokenManager(auth.TokenConfig{
+		SigningMethod:   "HS256",
+		HMACSecret:      "test-only-secret-that-is-long-enough",
+		Issuer:          "blog",
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewTokenManager: %v", err)
+	}
+
+	site, err := web.NewSite(blogs, users, tokens, refreshTokens, "http://blog.test", false)
+	if err != nil {
+		t.Fatalf("NewSite: %v", err)
+	}
+
+	router := server.NewRouter(
+		controllers.NewBlogController(blogs),
+		controllers.NewUserController(users, refreshTokens, tokens),
+		site,
+	)
+	return &testAPI{t: t, router: router, users: users}
+}
+
+// do sends a request with body as JSON, and an access token if token isn't
+// empty, and checks that the response has the wanted status code. It
+// decodes the response into out if out isn't nil.
+func (a *testAPI) do(method, path, token string, body any, want int, out any) {
+	a.t.Helper()
+
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatalf("%s %s: %v", method, path, err)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+
+	if rec.Code != want {
+		a.t.Fatalf("%s %s: status %d, want %d: %s", method, path, rec.Code, want, rec.Body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			a.t.Fatalf("%s %s: decoding %s: %v", method, path, rec.Body, err)
+		}
+	}
+}
+
+// user registers a user with the given role and logs in. It returns the
+// tokens.
+func (a *testAPI) user(name string, role policy.Role) forms.TokenResponse {
+	a.t.Helper()
+
+	email := name + "@example.com"
+	var user forms.User
+	a.do("POST", "/auth/register", "", forms.RegisterRequest{Email: email, Name: name, Password: "correct horse"}, http.StatusCreated, &user)
+	if role != policy.RoleAuthor {
+		if _, err := a.users.SetUserRole(user.ID, role); err != nil {
+			a.t.Fatalf("SetUserRole: %v", err)
+		}
+	}
+
+	var tokens forms.TokenResponse
+	a.do("POST", "/auth/login", "", forms.LoginRequest{Email: email, Password: "correct horse"}, http.StatusOK, &tokens)
+	return tokens
+}
+
+// createBlog creates a blog and returns its ID. The memory store numbers
+// blogs from 1, and the API doesn't return the ID of a new blog.
+func (a *testAPI) createBlog(token string, req forms.CreateBlogRequest) int {
+	a.t.Helper()
+
+	a.do("POST", "/blogs", token, req, http.StatusOK, nil)
+
+	var page forms.ListBlogsResponse
+	a.do("GET", "/blogs?sort=created_at&order=desc&limit=1&status="+statusOf(req), token, nil, http.StatusOK, &page)
+	if len(page.Data) != 1 {
+		a.t.Fatalf("new blog %q not listed", req.Title)
+	}
+	return page.Data[0].ID
+}
+
+func statusOf(req forms.CreateBlogRequest) string {
+	if req.Status == "" {
+		return forms.StatusDraft
+	}
+	return req.Status
+}
+
+func blogPath(id int) string {
+	return "/blogs/" + strconv.Itoa(id)
+}
+
+func TestBlogCRUD(t *testing.T) {
+	api := newTestAPI(t)
+	ann := api.user("ann", policy.RoleAuthor).AccessToken
+	bob := api.user("bob", policy.RoleAuthor).AccessToken
+	eve := api.user("eve", policy.RoleEditor).AccessToken
+
+	id := api.createBlog(ann, forms.CreateBlogRequest{Title: "Hello", Content: "World"})
+	path := blogPath(id)
+
+	var blog forms.GetBlogByIDResponse
+	api.do("GET", path, ann, nil, http.StatusOK, &blog)
+	if blog.Title != "Hello" || blog.Content != "World" || blog.Status != forms.StatusDraft {
+		t.Errorf("GET %s = %q, %q, %s; want Hello, World, draft", path, blog.Title, blog.Content, blog.Status)
+	}
+
+	// Drafts are only visible to those who may edit them. To the others,
+	// they don't exist.
+	update := forms.UpdateBlogRequest{Title: "Hello again", Content: "World"}
+	api.do("GET", path, "", nil, http.StatusNotFound, nil)
+	api.do("GET", path, bob, nil, http.StatusNotFound, nil)
+	api.do("PUT", path, bob, update, http.StatusNotFound, nil)
+	api.do("GET", path, eve, nil, http.StatusOK, nil)
+
+	api.do("POST", path+"/publish", ann, nil, http.StatusOK, nil)
+	api.do("GET", path, bob, nil, http.StatusOK, nil)
+	api.do("PUT", path, bob, update, http.StatusForbidden, nil)
+	api.do("PUT", path, ann, update, http.StatusOK, nil)
+	api.do("GET", path, ann, nil, http.StatusOK, &blog)
+	if blog.Title != "Hello again" {
+		t.Errorf("title after update = %q, want %q", blog.Title, "Hello again")
+	}
+
+	api.do("PUT", path, ann, map[string]string{"title": "No content"}, http.StatusUnprocessableEntity, nil)
+	api.do("GET", "/blogs/abc", ann, nil, http.StatusBadRequest, nil)
+	api.do("GET", "/blogs/999", ann, nil, http.StatusNotFound, nil)
+
+	api.do("DELETE", path, bob, nil, http.StatusForbidden, nil)
+	api.do("DELETE", path, ann, nil, http.StatusOK, nil)
+	api.do("GET", path, ann, nil, http.StatusNotFound, nil)
+
+	// Deleted blogs can be restored from the trash.
+	api.do("POST", path+"/restore", ann, nil, http.StatusOK, nil)
+	api.do("GET", path, ann, nil, http.StatusOK, nil)
+}
+
+func TestAuth(t *testing.T) {
+	api := newTestAPI(t)
+	tokens := api.user("ann", policy.RoleAuthor)
+	blog := forms.CreateBlogRequest{Title: "Hello", Content: "World"}
+
+	api.do("POST", "/blogs", "", blog, http.StatusUnauthorized, nil)
+	api.do("POST", "/blogs", "not-a-token", blog, http.StatusUnauthorized, nil)
+	api.do("POST", "/blogs", tokens.AccessToken, blog, http.StatusOK, nil)
+
+	api.do("POST", "/auth/register", "", forms.RegisterRequest{Email: "ann@example.com", Name: "Ann", Password: "another horse"}, http.StatusConflict, nil)
+	api.do("POST", "/auth/register", "", forms.RegisterRequest{Email: "short@example.com", Name: "Short", Password: "short"}, http.StatusUnprocessableEntity, nil)
+	api.do("POST", "/auth/login", "", forms.LoginRequest{Email: "ann@example.com", Password: "wrong horse"}, http.StatusUnauthorized, nil)
+	api.do("POST", "/auth/login", "", forms.LoginRequest{Email: "nobody@example.com", Password: "correct horse"}, http.StatusUnauthorized, nil)
+
+	// A refresh token can be used once. Using it again revokes the tokens
+	// that were issued in exchange, since it must have been stolen.
+	var refreshed forms.TokenResponse
+	api.do("POST", "/auth/refresh", "", forms.RefreshRequest{RefreshToken: tokens.RefreshToken}, http.StatusOK, &refreshed)
+	if refreshed.RefreshToken == tokens.RefreshToken {
+		t.Error("refresh returned the same refresh token")
+	}
+	api.do("POST", "/blogs", refreshed.AccessToken, blog, http.StatusOK, nil)
+	api.do("POST", "/auth/refresh", "", forms.RefreshRequest{RefreshToken: tokens.RefreshToken}, http.StatusUnauthorized, nil)
+	api.do("POST", "/auth/refresh", "", forms.RefreshRequest{RefreshToken: refreshed.RefreshToken}, http.StatusUnauthorized, nil)
+
+	// Logging out revokes the refresh token.
+	tokens = api.user("bob", policy.RoleAuthor)
+	api.do("POST", "/auth/logout", "", forms.RefreshRequest{RefreshToken: tokens.RefreshToken}, http.StatusNoContent, nil)
+	api.do("POST", "/auth/refresh", "", forms.RefreshRequest{RefreshToken: tokens.RefreshToken}, http.StatusUnauthorized, nil)
+
+	// Only admins may change roles.
+	admin := api.user("root", policy.RoleAdmin).AccessToken
+	role := forms.SetRoleRequest{Role: string(policy.RoleEditor)}
+	api.do("PUT", "/users/1/role", tokens.AccessToken, role, http.StatusForbidden, nil)
+	api.do("PUT", "/users/1/role", admin, role, http.StatusOK, nil)
+}
+
+func TestLifecycle(t *testing.T) {
+	api := newTestAPI(t)
+	ann := api.user("ann", policy.RoleAuthor).AccessToken
+	bob := api.user("bob", policy.RoleAuthor).AccessToken
+
+	id := api.createBlog(ann, forms.CreateBlogRequest{Title: "Hello", Content: "World"})
+	path := blogPath(id)
+
+	// Others can't see the draft, let alone publish it.
+	var blog forms.Blog
+	api.do("POST", path+"/publish", bob, nil, http.StatusNotFound, nil)
+	api.do("POST", path+"/publish", ann, nil, http.StatusOK, &blog)
+	if blog.Status != forms.StatusPublished || blog.PublishedAt == nil {
+		t.Errorf("after publish: status %s, published_at %v", blog.Status, blog.PublishedAt)
+	}
+
+	// Published blogs are visible to everyone.
+	api.do("GET", path, "", nil, http.StatusOK, nil)
+	var page forms.ListBlogsResponse
+	api.do("GET", "/blogs", "", nil, http.StatusOK, &page)
+	if page.Pagination.Total != 1 {
+		t.Errorf("anonymous list has %d blogs, want 1", page.Pagination.Total)
+	}
+
+	api.do("POST", path+"/unpublish", ann, nil, http.StatusOK, &blog)
+	if blog.Status != forms.StatusDraft {
+		t.Errorf("after unpublish: status %s, want %s", blog.Status, forms.StatusDraft)
+	}
+	api.do("GET", path, "", nil, http.StatusNotFound, nil)
+
+	past := forms.ScheduleBlogRequest{PublishAt: time.Now().Add(-time.Hour)}
+	api.do("POST", path+"/schedule", ann, past, http.StatusUnprocessableEntity, nil)
+	future := forms.ScheduleBlogRequest{PublishAt: time.Now().Add(time.Hour)}
+	api.do("POST", path+"/schedule", ann, future, http.StatusOK, &blog)
+	if blog.Status != forms.StatusScheduled || blog.PublishAt == nil {
+		t.Errorf("after schedule: status %s, publish_at %v", blog.Status, blog.PublishAt)
+	}
+	api.do("GET", path, "", nil, http.StatusNotFound, nil)
+
+	// A scheduled blog can be scheduled again, for another time.
+	later := forms.ScheduleBlogRequest{PublishAt: future.PublishAt.Add(time.Hour)}
+	api.do("POST", path+"/schedule", ann, later, http.StatusOK, &blog)
+	if blog.PublishAt == nil || !blog.PublishAt.Equal(later.PublishAt) {
+		t.Errorf("after rescheduling: publish_at %v, want %v", blog.PublishAt, later.PublishAt)
+	}
+
+	api.do("POST", path+"/publish", ann, nil, http.StatusOK, nil)
+	api.do("POST", path+"/unpublish", bob, nil, http.StatusForbidden, nil)
+	api.do("POST", path+"/archive", ann, nil, http.StatusOK, &blog)
+	if blog.Status != forms.StatusArchived {
+		t.Errorf("after archive: status %s, want %s", blog.Status, forms.StatusArchived)
+	}
+}
+
+func TestCommentThread(t *testing.T) {
+	api := newTestAPI(t)
+	ann := api.user("ann", policy.RoleAuthor).AccessToken
+	cat := api.user("cat", policy.RoleCommenter).AccessToken
+	eve := api.user("eve", policy.RoleEditor).AccessToken
+
+	id := api.createBlog(ann, forms.CreateBlogRequest{Title: "Hello", Content: "World", Status: forms.StatusPublished})
+	comments := blogPath(id) + "/comments"
+
+	api.do("POST", comments, "", forms.CreateCommentRequest{Content: "First"}, http.StatusUnauthorized, nil)
+	api.do("POST", comments, cat, forms.CreateCommentRequest{Content: "First"}, http.StatusOK, nil)
+
+	var page forms.ListCommentsResponse
+	api.do("GET", comments, "", nil, http.StatusOK, &page)
+	if len(page.Data) != 1 {
+		t.Fatalf("blog has %d comments, want 1", len(page.Data))
+	}
+	first := page.Data[0]
+	firstPath := comments + "/" + strconv.Itoa(first.ID)
+
+	api.do("POST", firstPath+"/replies", ann, forms.CreateCommentRequest{Content: "Reply"}, http.StatusOK, nil)
+	api.do("GET", comments, "", nil, http.StatusOK, &page)
+	if len(page.Data) != 2 {
+		t.Fatalf("blog has %d comments, want 2", len(page.Data))
+	}
+	reply := page.Data[1]
+	if reply.ParentID == nil || *reply.ParentID != first.ID || reply.Depth != first.Depth+1 {
+		t.Errorf("reply has parent %v and depth %d, want %d and %d", reply.ParentID, reply.Depth, first.ID, first.Depth+1)
+	}
+
+	replyPath := comments + "/" + strconv.Itoa(reply.ID)
+	api.do("POST", replyPath+"/replies", cat, forms.CreateCommentRequest{Content: "Reply to the reply"}, http.StatusOK, nil)
+	api.do("POST", comments+"/999/replies", cat, forms.CreateCommentRequest{Content: "Lost"}, http.StatusNotFound, nil)
+
+	// Only the authors of comments may change them. Editors may delete
+	// them.
+	edit := forms.UpdateCommentRequest{Content: "Edited"}
+	api.do("PUT", firstPath, ann, edit, http.StatusForbidden, nil)
+	api.do("PUT", firstPath, eve, edit, http.StatusForbidden, nil)
+	api.do("PUT", firstPath, cat, edit, http.StatusOK, nil)
+	api.do("DELETE", firstPath, ann, nil, http.StatusForbidden, nil)
+
+	// Deleting a comment hides its replies, and restoring it shows them
+	// again.
+	api.do("DELETE", firstPath, cat, nil, http.StatusOK, nil)
+	api.do("GET", comments, "", nil, http.StatusOK, &page)
+	if len(page.Data) != 0 {
+		t.Errorf("blog has %d comments after deleting the thread, want 0", len(page.Data))
+	}
+	api.do("POST", firstPath+"/restore", cat, nil, http.StatusOK, nil)
+	api.do("GET", comments, "", nil, http.StatusOK, &page)
+	if len(page.Data) != 3 {
+		t.Errorf("blog has %d comments after restoring the thread, want 3", len(page.Data))
+	}
+	if len(page.Data) > 0 && page.Data[0].Content != "Edited" {
+		t.Errorf("first comment is %q, want %q", page.Data[0].Content, "Edited")
+	}
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"strings"
+
+	"blog/auth"
+	"blog/forms"
+	"blog/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// claimsKey is the key under which the claims of the access token are stored
+// in the gin context.
+const claimsKey = "auth_claims"
+
+// RequireAuth is a middleware that only lets requests with a valid access
+// token through. Other requests get a 401 Unauthorized response.
+//
+// The access token is sent in the Authorization header:
+//
+//	Authorization: Bearer <access_token>
+//
+// The claims of the token are stored in the gin context, where the handlers
+// can get them with currentUser.
+func (c *UserController) RequireAuth(ctx *gin.Context) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		// The WWW-Authenticate header tells the client how to authenticate.
+		//
+		// For more information on the WWW-Authenticate header, see:
+		// https://www.rfc-editor.org/rfc/rfc6750#section-3
+		ctx.Header("WWW-Authenticate", `Bearer realm="blog"`)
+		writeProblem(ctx, problemUnauthenticated, "This request needs an access token in the Authorization header.", nil)
+		return
+	}
+
+	if !c.verifyToken(ctx, token) {
+		return
+	}
+
+	// ctx.Next runs the remaining handlers.
+	ctx.Next()
+}
+
+// OptionalAuth is a middleware for routes that anyone can use, but that show
+// more to logged-in users, such as their own drafts.
+//
+// Requests without an Authorization header are let through as anonymous.
+// Requests with an invalid access token get a 401 Unauthorized response, so
+// that clients find out that their token has expired.
+func (c *UserController) OptionalAuth(ctx *gin.Context) {
+	if token, ok := bearerToken(ctx); ok && !c.verifyToken(ctx, token) {
+		return
+	}
+
+	ctx.Next()
+}
+
+// verifyToken verifies an access token and stores its claims in the gin
+// context. If the token is invalid, it writes a 401 Unauthorized response and
+// returns false.
+func (c *UserController) verifyToken(ctx *gin.Context, token string) bool {
+	claims, err := c.tokens.VerifyAccessToken(token)
+	if err != nil {
+		ctx.Header("WWW-Authenticate", `Bearer realm="blog", error="invalid_token"`)
+		writeProblem(ctx, problemInvalidToken, "The access token is invalid or has expired.", nil)
+		return false
+	}
+
+	ctx.Set(claimsKey, claims)
+	return true
+}
+
+// bearerToken returns the token from the Authorization header.
+func bearerToken(ctx *gin.Context) (string, bool) {
+	header := ctx.GetHeader("Authorization")
+
+	// The scheme is case-insensitive, so "bearer" works too.
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
+
+// currentUser returns the claims of the access token of the request, as
+// stored by RequireAuth. It returns false if the request is anonymous.
+func currentUser(ctx *gin.Context) (auth.Claims, bool) {
+	value, ok := ctx.Get(claimsKey)
+	if !ok {
+		return auth.Claims{}, false
+	}
+
+	claims, ok := value.(auth.Claims)
+	return claims, ok
+}
+
+// currentPolicyUser returns the user making the request, as used by the
+// policy package. It returns the zero policy.User if the request is anonymous.
+func currentPolicyUser(ctx *gin.Context) policy.User {
+	claims, ok := currentUser(ctx)
+	if !ok {
+		return policy.User{}
+	}
+	return policy.User{ID: claims.UserID(), Role: policy.Role(claims.Role)}
+}
+
+// authorize checks whether the user making the request may do the action to
+// a resource owned by the user with ID ownerID. If not, it writes a 403
+// Forbidden response and returns false.
+//
+// The rules are declared in blog/policy/policy.go.
+func authorize(ctx *gin.Context, action policy.Action, ownerID int) bool {
+	if policy.Allowed(currentPolicyUser(ctx), action, ownerID) {
+		return true
+	}
+
+	writeProblem(ctx, problemForbidden, "You are not allowed to do this.", nil)
+	return false
+}
+
+// canView reports whether the user making the request may see a blog with
+// the given status and author. Everybody may see published blogs.
+func canView(ctx *gin.Context, status string, authorID int) bool {
+	return status == forms.StatusPublished || policy.Allowed(currentPolicyUser(ctx), policy.ViewDraft, authorID)
+}
+
+// currentUserID returns the ID of the user making the request, or 0 if the
+// request is anonymous.
+func currentUserID(ctx *gin.Context) int {
+	claims, ok := currentUser(ctx)
+	if !ok {
+		return 0
+	}
+	return claims.UserID()
+}
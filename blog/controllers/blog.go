@@ -7,22 +7,27 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
-	"strconv"
+	"net/url"
 
 	"blog/forms"
 	"blog/models"
+	"blog/policy"
 
 	"github.com/gin-gonic/gin"
 )
 
 // BlogController is a controller for the blog resource.
+//
+// It depends on the models.BlogStore interface, so it works with any storage
+// backend, for example models.BlogModel or models.MemoryBlogModel.
 type BlogController struct {
-	blogModel *models.BlogModel
+	blogModel models.BlogStore
 }
 
 // NewBlogController creates a new BlogController.
-func NewBlogController(blogModel *models.BlogModel) *BlogController {
+func NewBlogController(blogModel models.BlogStore) *BlogController {
 	return &BlogController{
 		blogModel: blogModel,
 	}
@@ -42,38 +47,54 @@ func (c *BlogController) Health(ctx *gin.Context) {
 
 // CreateBlog creates a new blog.
 func (c *BlogController) CreateBlog(ctx *gin.Context) {
+	// Commenters may not write blogs. authorize writes a 403 Forbidden
+	// response if the user may not do this.
+	//
+	// For more information on authorize, see:
+	// blog/controllers/auth.go
+	if !authorize(ctx, policy.CreateBlog, 0) {
+		return
+	}
+
 	// Create a new instance of the CreateBlogRequest struct.
 	var req forms.CreateBlogRequest
-	// ctx.BindJSON is a helper function provided by Gin to bind the request body
-	// to a Go struct.
+	// ctx.ShouldBindJSON is a helper function provided by Gin to bind the
+	// request body to a Go struct.
 	//
 	// It takes a pointer to a struct as an argument. The struct must be a
 	// pointer, otherwise the request will fail with a 500 Internal Server Error
 	// response.
 	//
-	// For more information on ctx.BindJSON, see:
-	// https://godoc.org/github.com/gin-gonic/gin#Context.BindJSON
-	if err := ctx.BindJSON(&req); err != nil {
-		// If the request fails, we return a 400 Bad Request response.
+	// Unlike ctx.BindJSON, it doesn't write a response on failure, so we can
+	// write our own error body.
+	//
+	// For more information on ctx.ShouldBindJSON, see:
+	// https://godoc.org/github.com/gin-gonic/gin#Context.ShouldBindJSON
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		// If the request fails, we return a 422 Unprocessable Entity response
+		// listing the invalid fields, or a 400 Bad Request response if the body
+		// is not valid JSON.
 		//
 		// For more information on HTTP status codes, see:
 		// https://en.wikipedia.org/wiki/List_of_HTTP_status_codes
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"message": "Failed to create blog: " + err.Error(),
-		})
+		respondBindError(ctx, err)
 		return
 	}
 
 	// Call the CreateBlog method on the BlogModel, passing in the request data.
-	// If the method returns an error, we return a 500 Internal Server Error
-	// response.
+	// If the method returns an error, respondError picks the status code that
+	// matches the kind of error, or 500 Internal Server Error.
+	//
+	// For more information on respondError, see:
+	// blog/controllers/errors.go
+	//
+	// The author is the user that the access token was issued to. The
+	// RequireAuth middleware makes sure there is one.
 	//
 	// For more information on c.blogModel.CreateBlog, see:
 	// blog/models/blog.go
-	if err := c.blogModel.CreateBlog(req); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Failed to create blog: " + err.Error(),
-		})
+	if err := c.blogModel.CreateBlog(currentUserID(ctx), req); err != nil {
+		respondError(ctx, "Failed to create blog", err)
 		return
 	}
 
@@ -81,94 +102,183 @@ func (c *BlogController) CreateBlog(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "Blog created successfully"})
 }
 
-// GetAllBlogs returns a list of all blogs.
+// GetAllBlogs returns a page of blogs.
+//
+// The query string selects the page, the sort order and the filters.
+// For more information on the query string, see forms.ListBlogsQuery in:
+// blog/forms/blog.go
 func (c *BlogController) GetAllBlogs(ctx *gin.Context) {
-	// Call the GetAllBlogs method on the BlogModel.
-	blogs, err := c.blogModel.GetAllBlogs()
+	// ctx.ShouldBindQuery binds the query string to a Go struct, in the same
+	// way as ctx.ShouldBindJSON binds the request body.
+	var query forms.ListBlogsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	// Anonymous callers only see published blogs. Logged-in users can also
+	// list their own drafts with ?status=draft, and editors everybody's.
+	user := currentPolicyUser(ctx)
+	query.ViewAll = policy.Allowed(user, policy.ViewDraft, 0)
+	if policy.Allowed(user, policy.ViewDraft, user.ID) {
+		query.ViewerID = user.ID
+	}
+
+	// Call the GetAllBlogs method on the BlogModel, passing in the query.
+	page, err := c.blogModel.GetAllBlogs(query)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Failed to get all blogs: " + err.Error(),
-		})
+		respondError(ctx, "Failed to get all blogs", err)
 		return
 	}
+	for i := range page.Data {
+		selectFormat(query.Format, &page.Data[i].Content, &page.Data[i].ContentHTML)
+	}
+
+	// The Link header lets clients follow the pages without building URLs.
+	setLinkHeader(ctx, page.Pagination)
 
-	ctx.JSON(http.StatusOK, blogs)
+	ctx.JSON(http.StatusOK, page)
 }
 
 // GetBlogByID returns a single blog.
 func (c *BlogController) GetBlogByID(ctx *gin.Context) {
-	// ctx.Param is a helper function provided by Gin to get a URL parameter.
-	//
-	// For more information on ctx.Param, see:
-	// https://godoc.org/github.com/gin-gonic/gin#Context.Param
-	idString := ctx.Param("id")
-	id, err := strconv.Atoi(idString)
+	// parseIDParam writes a 400 Bad Request response if the ID is invalid.
+	// For more information on parseIDParam, see:
+	// blog/controllers/errors.go
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	c.respondBlog(ctx, id)
+}
+
+// GetBlogBySlug returns a single blog, like GetBlogByID, but finds it by its
+// slug. For example:
+//
+//	GET /blogs/by-slug/hello-world
+//
+// Slugs change with the title. An old slug is answered with a 301 Moved
+// Permanently redirect to the current one, so that old links keep working.
+func (c *BlogController) GetBlogBySlug(ctx *gin.Context) {
+	s := ctx.Param("slug")
+
+	id, current, err := c.blogModel.GetBlogIDBySlug(s)
+	if err != nil {
+		respondError(ctx, "Failed to get blog", err)
+		return
+	}
+
+	// Check that the caller may see the blog before redirecting, so that
+	// the redirect doesn't reveal a draft either.
+	info, err := c.blogModel.GetBlogInfo(id)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"message": "Failed to get blog: " + err.Error(),
-		})
+		respondError(ctx, "Failed to get blog", err)
+		return
+	}
+	if info.Deleted || !canView(ctx, info.Status, info.AuthorID) {
+		writeProblem(ctx, problemNotFound, fmt.Sprintf("blog %q not found", s), nil)
+		return
+	}
+
+	if current != s {
+		location := "/blogs/by-slug/" + url.PathEscape(current)
+		if ctx.Request.URL.RawQuery != "" {
+			location += "?" + ctx.Request.URL.RawQuery
+		}
+		ctx.Redirect(http.StatusMovedPermanently, location)
+		return
+	}
+
+	c.respondBlog(ctx, id)
+}
+
+// respondBlog writes the blog with the given ID, with the first page of its
+// comments, in the format asked for by the query string.
+func (c *BlogController) respondBlog(ctx *gin.Context, id int) {
+	var query forms.FormatQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindError(ctx, err)
 		return
 	}
 
 	// Call the GetBlogByID method on the BlogModel, passing in the ID.
 	blog, err := c.blogModel.GetBlogByID(id)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Failed to get blog: " + err.Error(),
-		})
+		respondError(ctx, "Failed to get blog", err)
+		return
+	}
+
+	// A blog that the caller may not see is reported as not found, so that
+	// the response doesn't reveal that there is a draft with this ID.
+	authorID := 0
+	if blog.Author != nil {
+		authorID = blog.Author.ID
+	}
+	if !canView(ctx, blog.Status, authorID) {
+		writeProblem(ctx, problemNotFound, fmt.Sprintf("blog %d not found", id), nil)
 		return
 	}
 
+	selectFormat(query.Format, &blog.Content, &blog.ContentHTML)
+
 	ctx.JSON(http.StatusOK, blog)
 }
 
+// selectFormat clears the content field that the format doesn't ask for:
+// the HTML for markdown, and the Markdown source for html. Both are kept
+// for both, or when no format is given.
+func selectFormat(format string, content, contentHTML *string) {
+	switch format {
+	case forms.FormatMarkdown:
+		*contentHTML = ""
+	case forms.FormatHTML:
+		*content = ""
+	}
+}
+
 // UpdateBlog updates a blog.
 func (c *BlogController) UpdateBlog(ctx *gin.Context) {
-	idString := ctx.Param("id")
-	id, err := strconv.Atoi(idString)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"message": "Failed to update blog: " + err.Error(),
-		})
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if !c.authorizeBlog(ctx, policy.UpdateBlog, id) {
 		return
 	}
 
 	var req forms.UpdateBlogRequest
-	if err := ctx.BindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"message": "Failed to update blog: " + err.Error(),
-		})
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
 		return
 	}
 
-	// Call the UpdateBlog method on the BlogModel, passing in the ID and
-	// request data.
-	if err := c.blogModel.UpdateBlog(id, req); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Failed to update blog: " + err.Error(),
-		})
+	// Call the UpdateBlog method on the BlogModel, passing in the ID, the
+	// ID of the editor and the request data.
+	if err := c.blogModel.UpdateBlog(id, currentUserID(ctx), req); err != nil {
+		respondError(ctx, "Failed to update blog", err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "Blog updated successfully"})
 }
 
-// DeleteBlog deletes a blog.
+// DeleteBlog moves a blog to the trash. It can be restored until it is
+// purged.
 func (c *BlogController) DeleteBlog(ctx *gin.Context) {
-	idString := ctx.Param("id")
-	id, err := strconv.Atoi(idString)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"message": "Failed to delete blog: " + err.Error(),
-		})
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if !c.authorizeBlog(ctx, policy.DeleteBlog, id) {
 		return
 	}
 
 	// Call the DeleteBlog method on the BlogModel, passing in the ID.
 	if err := c.blogModel.DeleteBlog(id); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Failed to delete blog: " + err.Error(),
-		})
+		respondError(ctx, "Failed to delete blog", err)
 		return
 	}
 
@@ -177,31 +287,83 @@ func (c *BlogController) DeleteBlog(ctx *gin.Context) {
 
 // CreateComment creates a new comment.
 func (c *BlogController) CreateComment(ctx *gin.Context) {
-	blogIDString := ctx.Param("id")
-	blogID, err := strconv.Atoi(blogIDString)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"message": "Failed to create comment: " + err.Error(),
-		})
+	blogID, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if !authorize(ctx, policy.CreateComment, 0) {
+		return
+	}
+	if _, ok := c.getVisibleBlog(ctx, blogID); !ok {
 		return
 	}
 
 	var req forms.CreateCommentRequest
-	if err := ctx.BindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"message": "Failed to create comment: " + err.Error(),
-		})
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
 		return
 	}
 
-	// Call the CreateComment method on the BlogModel, passing in the blog ID
-	// and request data.
-	if err := c.blogModel.CreateComment(blogID, req); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Failed to create comment: " + err.Error(),
-		})
+	// Call the CreateComment method on the BlogModel, passing in the blog ID,
+	// the author ID and request data.
+	if err := c.blogModel.CreateComment(blogID, currentUserID(ctx), req); err != nil {
+		respondError(ctx, "Failed to create comment", err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "Comment created successfully"})
 }
+
+// authorizeBlog checks whether the user making the request may do the action
+// to the blog with the given ID, which depends on who wrote the blog.
+// If not, it writes an error response and returns false.
+func (c *BlogController) authorizeBlog(ctx *gin.Context, action policy.Action, id int) bool {
+	info, ok := c.getVisibleBlog(ctx, id)
+	if !ok {
+		return false
+	}
+
+	return authorize(ctx, action, info.AuthorID)
+}
+
+// getVisibleBlog returns who wrote a blog and its status. If the blog doesn't
+// exist, is in the trash, or the user making the request may not see it, it
+// writes a 404 Not Found response and returns false.
+func (c *BlogController) getVisibleBlog(ctx *gin.Context, id int) (models.BlogInfo, bool) {
+	info, err := c.blogModel.GetBlogInfo(id)
+	if err != nil {
+		respondError(ctx, "Failed to get blog", err)
+		return models.BlogInfo{}, false
+	}
+
+	if info.Deleted || !canView(ctx, info.Status, info.AuthorID) {
+		writeProblem(ctx, problemNotFound, fmt.Sprintf("blog %d not found", id), nil)
+		return models.BlogInfo{}, false
+	}
+
+	return info, true
+}
+
+// Search returns the blogs that match a full-text search.
+//
+// For more information on the query string, see forms.SearchQuery in:
+// blog/forms/search.go
+func (c *BlogController) Search(ctx *gin.Context) {
+	var query forms.SearchQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	// Call the Search method on the BlogModel, passing in the query.
+	results, err := c.blogModel.Search(query)
+	if err != nil {
+		respondError(ctx, "Failed to search blogs", err)
+		return
+	}
+
+	setLinkHeader(ctx, results.Pagination)
+
+	ctx.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"net/http"
+
+	"blog/forms"
+	"blog/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListCategories returns all the categories as a flattened tree: every
+// category is followed by its subcategories.
+func (c *BlogController) ListCategories(ctx *gin.Context) {
+	categories, err := c.blogModel.ListCategories()
+	if err != nil {
+		respondError(ctx, "Failed to list categories", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, categories)
+}
+
+// GetCategory returns a single category.
+func (c *BlogController) GetCategory(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	category, err := c.blogModel.GetCategory(id)
+	if err != nil {
+		respondError(ctx, "Failed to get category", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, category)
+}
+
+// CreateCategory creates a category and returns it. Only editors and admins
+// may manage categories.
+func (c *BlogController) CreateCategory(ctx *gin.Context) {
+	if !authorize(ctx, policy.ManageTaxonomy, 0) {
+		return
+	}
+
+	var req forms.CategoryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	category, err := c.blogModel.CreateCategory(req)
+	if err != nil {
+		respondError(ctx, "Failed to create category", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, category)
+}
+
+// UpdateCategory renames a category or moves it below another parent, and
+// returns it.
+func (c *BlogController) UpdateCategory(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if !authorize(ctx, policy.ManageTaxonomy, 0) {
+		return
+	}
+
+	var req forms.CategoryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	category, err := c.blogModel.UpdateCategory(id, req)
+	if err != nil {
+		respondError(ctx, "Failed to update category", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory deletes a category that has no subcategories. Its blogs
+// are left without a category.
+func (c *BlogController) DeleteCategory(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if !authorize(ctx, policy.ManageTaxonomy, 0) {
+		return
+	}
+
+	if err := c.blogModel.DeleteCategory(id); err != nil {
+		respondError(ctx, "Failed to delete category", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"blog/forms"
+	"blog/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListComments returns a page of the comments of a blog.
+//
+// For more information on the query string, see forms.ListCommentsQuery in:
+// blog/forms/comment.go
+func (c *BlogController) ListComments(ctx *gin.Context) {
+	blogID, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var query forms.ListCommentsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	if _, ok := c.getVisibleBlog(ctx, blogID); !ok {
+		return
+	}
+
+	page, err := c.blogModel.ListComments(blogID, query)
+	if err != nil {
+		respondError(ctx, "Failed to get comments", err)
+		return
+	}
+
+	setLinkHeader(ctx, page.Pagination)
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// ReplyToComment creates a reply to a comment.
+//
+// Replies can be nested up to forms.MaxCommentDepth levels deep. A deeper
+// reply is rejected with 422 Unprocessable Entity.
+func (c *BlogController) ReplyToComment(ctx *gin.Context) {
+	blogID, parentID, ok := parseCommentParams(ctx)
+	if !ok {
+		return
+	}
+
+	if !authorize(ctx, policy.CreateComment, 0) {
+		return
+	}
+	if _, ok := c.getVisibleBlog(ctx, blogID); !ok {
+		return
+	}
+
+	var req forms.CreateCommentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	if err := c.blogModel.ReplyToComment(blogID, parentID, currentUserID(ctx), req); err != nil {
+		respondError(ctx, "Failed to create reply", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Reply created successfully"})
+}
+
+// UpdateComment updates a comment.
+func (c *BlogController) UpdateComment(ctx *gin.Context) {
+	blogID, commentID, ok := parseCommentParams(ctx)
+	if !ok {
+		return
+	}
+
+	if !c.authorizeComment(ctx, policy.UpdateComment, blogID, commentID) {
+		return
+	}
+
+	var req forms.UpdateCommentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	if err := c.blogModel.UpdateComment(blogID, commentID, req); err != nil {
+		respondError(ctx, "Failed to update comment", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Comment updated successfully"})
+}
+
+// DeleteComment moves a comment to the trash. Its replies are hidden until
+// it is restored.
+func (c *BlogController) DeleteComment(ctx *gin.Context) {
+	blogID, commentID, ok := parseCommentParams(ctx)
+	if !ok {
+		return
+	}
+
+	if !c.authorizeComment(ctx, policy.DeleteComment, blogID, commentID) {
+		return
+	}
+
+	if err := c.blogModel.DeleteComment(blogID, commentID); err != nil {
+		respondError(ctx, "Failed to delete comment", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// parseCommentParams reads the blog ID and comment ID from the URL of a
+// comment, for example /blogs/1/comments/2.
+func parseCommentParams(ctx *gin.Context) (blogID, commentID int, ok bool) {
+	if blogID, ok = parseIDParam(ctx, "id"); !ok {
+		return 0, 0, false
+	}
+	if commentID, ok = parseIDParam(ctx, "commentId"); !ok {
+		return 0, 0, false
+	}
+	return blogID, commentID, true
+}
+
+// authorizeComment checks whether the user making the request may do the
+// action to a comment, which depends on who wrote the comment. Comments that
+// are hidden because they, or their blog, are in the trash are reported as
+// 404 Not Found. If not allowed, it writes an error response and returns
+// false.
+func (c *BlogController) authorizeComment(ctx *gin.Context, action policy.Action, blogID, commentID int) bool {
+	info, err := c.blogModel.GetCommentInfo(blogID, commentID)
+	if err != nil {
+		respondError(ctx, "Failed to get comment", err)
+		return false
+	}
+
+	if info.Hidden {
+		writeProblem(ctx, problemNotFound, fmt.Sprintf("comment %d not found", commentID), nil)
+		return false
+	}
+
+	return authorize(ctx, action, info.AuthorID)
+}
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"blog/forms"
+	"blog/middleware"
+	"blog/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ProblemContentType is the content type of error responses.
+const ProblemContentType = "application/problem+json"
+
+// problemType describes one kind of problem: the type URI, the title and the
+// HTTP status code of the problem details response.
+type problemType struct {
+	uri    string
+	title  string
+	status int
+}
+
+// The kinds of problems that the API reports.
+var (
+	problemBadRequest = problemType{"urn:blog:problem:bad-request", "Bad request", http.StatusBadRequest}
+	problemNotFound   = problemType{"urn:blog:problem:not-found", "Resource not found", http.StatusNotFound}
+	problemNoMethod   = problemType{"urn:blog:problem:method-not-allowed", "Method not allowed", http.StatusMethodNotAllowed}
+	problemConflict   = problemType{"urn:blog:problem:conflict", "Conflict", http.StatusConflict}
+	problemValidation = problemType{"urn:blog:problem:validation", "Validation failed", http.StatusUnprocessableEntity}
+	problemConstraint = problemType{"urn:blog:problem:constraint", "Constraint violation", http.StatusUnprocessableEntity}
+	problemInternal   = problemType{"about:blank", "Internal Server Error", http.StatusInternalServerError}
+
+	problemInvalidCredentials = problemType{"urn:blog:problem:invalid-credentials", "Invalid credentials", http.StatusUnauthorized}
+	problemInvalidToken       = problemType{"urn:blog:problem:invalid-token", "Invalid token", http.StatusUnauthorized}
+	problemUnauthenticated    = problemType{"urn:blog:problem:unauthenticated", "Authentication required", http.StatusUnauthorized}
+	problemForbidden          = problemType{"urn:blog:problem:forbidden", "Forbidden", http.StatusForbidden}
+)
+
+// errorKinds maps the kinds of errors returned by the models to problem types.
+//
+// Keeping the mapping in one place means that every handler reports the same
+// kind of error in the same way.
+var errorKinds = []struct {
+	kind    error
+	problem problemType
+}{
+	{models.ErrNotFound, problemNotFound},
+	{models.ErrConflict, problemConflict},
+	{models.ErrValidation, problemValidation},
+	{models.ErrConstraint, problemConstraint},
+}
+
+// init configures the validator used by gin's binding.
+//
+// It makes the validator report fields by their JSON name ("title") rather
+// than by their Go name ("Title"), so that the field errors match the request
+// body that the client sent. It also registers the custom validation rules
+// used by the binding tags in the forms package.
+//
+// For more information on RegisterTagNameFunc, see:
+// https://pkg.go.dev/github.com/go-playground/validator/v10#Validate.RegisterTagNameFunc
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	// The "language" rule accepts the languages supported by full-text search.
+	v.RegisterValidation("language", func(fl validator.FieldLevel) bool {
+		return forms.Languages[fl.Field().String()]
+	})
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form", "uri"} {
+			name := strings.Split(field.Tag.Get(tag), ",")[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+}
+
+// respondError writes a problem details response for an error returned by
+// the models.
+//
+// Typed model errors are reported with their own status code and message, for
+// example 404 Not Found with "blog 1 not found". Any other error is logged
+// together with action and the request ID, and reported as a 500 Internal
+// Server Error without any details, so that internal errors (such as SQL
+// errors) never leak to clients.
+func respondError(ctx *gin.Context, action string, err error) {
+	for _, k := range errorKinds {
+		if !errors.Is(err, k.kind) {
+			continue
+		}
+
+		// errors.As gives us the *models.Error so we can use its message,
+		// which is safe to show to clients.
+		detail := k.problem.title
+		var modelErr *models.Error
+		if errors.As(err, &modelErr) {
+			detail = modelErr.Message
+		}
+
+		writeProblem(ctx, k.problem, detail, nil)
+		return
+	}
+
+	log.Printf("[%s] %s: %v", middleware.GetRequestID(ctx), action, err)
+	writeProblem(ctx, problemInternal, "An unexpected error occurred. Please try again later.", nil)
+}
+
+// respondBindError writes a problem details response for an error returned
+// by ctx.ShouldBindJSON (or one of the other ShouldBind methods).
+//
+// Validation failures are reported as 422 Unprocessable Entity with one entry
+// per invalid field. A body that isn't valid JSON is reported as 400 Bad
+// Request.
+func respondBindError(ctx *gin.Context, err error) {
+	var validationErrs validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var numErr *strconv.NumError
+	var timeErr *time.ParseError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := make([]forms.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, forms.FieldError{
+				Field:   fieldName(fe),
+				Message: fieldMessage(fe),
+			})
+		}
+		writeProblem(ctx, problemValidation, "The request contains invalid fields.", fields)
+
+	case errors.As(err, &typeErr):
+		writeProblem(ctx, problemValidation, "The request contains invalid fields.", []forms.FieldError{{
+			Field:   typeErr.Field,
+			Message: "must be a " + typeErr.Type.String(),
+		}})
+
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		writeProblem(ctx, problemBadRequest, "The request body is not valid JSON.", nil)
+
+	case errors.As(err, &numErr):
+		writeProblem(ctx, problemBadRequest, fmt.Sprintf("%q is not a valid number.", numErr.Num), nil)
+
+	case errors.As(err, &timeErr):
+		writeProblem(ctx, problemBadRequest, fmt.Sprintf("%q is not a valid time. Use the RFC 3339 format, for example 2006-01-02T15:04:05Z.", timeErr.Value), nil)
+
+	default:
+		writeProblem(ctx, problemBadRequest, "The request could not be read.", nil)
+	}
+}
+
+// parseIDParam reads a positive integer ID from the URL parameter with the
+// given name. If the parameter isn't a valid ID it writes a 400 Bad Request
+// response and returns false.
+func parseIDParam(ctx *gin.Context, name string) (int, bool) {
+	// ctx.Param is a helper function provided by Gin to get a URL parameter.
+	//
+	// For more information on ctx.Param, see:
+	// https://godoc.org/github.com/gin-gonic/gin#Context.Param
+	value := ctx.Param(name)
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 1 {
+		writeProblem(ctx, problemBadRequest, fmt.Sprintf("%q is not a valid %s.", value, name), nil)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// NoRoute writes a 404 Not Found problem for requests that match no route.
+func NoRoute(ctx *gin.Context) {
+	writeProblem(ctx, problemNotFound, "No route matches "+ctx.Request.URL.Path+".", nil)
+}
+
+// NoMethod writes a 405 Method Not Allowed problem for requests to a route
+// that doesn't support the request method.
+func NoMethod(ctx *gin.Context) {
+	writeProblem(ctx, problemNoMethod, ctx.Request.Method+" is not allowed on "+ctx.Request.URL.Path+".", nil)
+}
+
+// Recovery writes a 500 Internal Server Error problem when a handler panics.
+// It is used with gin.CustomRecovery, which logs the panic and stack trace.
+func Recovery(ctx *gin.Context, recovered any) {
+	writeProblem(ctx, problemInternal, "An unexpected error occurred. Please try again later.", nil)
+}
+
+// writeProblem writes a problem details response and stops any remaining
+// handlers from running.
+func writeProblem(ctx *gin.Context, p problemType, detail string, fields []forms.FieldError) {
+	// Set the content type before writing the body, otherwise gin would use
+	// application/json.
+	ctx.Header("Content-Type", ProblemContentType)
+	ctx.AbortWithStatusJSON(p.status, forms.Problem{
+		Type:      p.uri,
+		Title:     p.title,
+		Status:    p.status,
+		Detail:    detail,
+		Instance:  ctx.Request.URL.Path,
+		RequestID: middleware.GetRequestID(ctx),
+		Errors:    fields,
+	})
+}
+
+// fieldName returns the JSON path of an invalid field, for example "title".
+//
+// fe.Namespace returns the path including the name of the request struct,
+// for example "CreateBlogRequest.title", so we remove the first part.
+func fieldName(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+// fieldMessage returns a human-readable message for a failed validation.
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + fe.Param() + " characters long"
+	case "max":
+		return "must be at most " + fe.Param() + " characters long"
+	case "gte":
+		return "must be greater than or equal to " + fe.Param()
+	case "lte":
+		return "must be less than or equal to " + fe.Param()
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "email":
+		return "must be a valid email address"
+	case "language":
+		return "must be a supported language"
+	default:
+		return "is invalid (failed on the '" + fe.Tag() + "' rule)"
+	}
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"blog/forms"
+	"blog/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PublishBlog publishes a draft, scheduled or archived blog.
+func (c *BlogController) PublishBlog(ctx *gin.Context) {
+	c.changeStatus(ctx, "publish", c.blogModel.PublishBlog)
+}
+
+// UnpublishBlog turns a published or scheduled blog back into a draft.
+func (c *BlogController) UnpublishBlog(ctx *gin.Context) {
+	c.changeStatus(ctx, "unpublish", c.blogModel.UnpublishBlog)
+}
+
+// ArchiveBlog archives a published blog.
+func (c *BlogController) ArchiveBlog(ctx *gin.Context) {
+	c.changeStatus(ctx, "archive", c.blogModel.ArchiveBlog)
+}
+
+// ScheduleBlog schedules a draft to be published automatically at a later
+// time. The scheduler publishes it when it is due.
+func (c *BlogController) ScheduleBlog(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if !c.authorizeBlog(ctx, policy.PublishBlog, id) {
+		return
+	}
+
+	var req forms.ScheduleBlogRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	if !req.PublishAt.After(time.Now()) {
+		writeProblem(ctx, problemValidation, "The request contains invalid fields.", []forms.FieldError{{
+			Field:   "publish_at",
+			Message: "must be in the future",
+		}})
+		return
+	}
+
+	if err := c.blogModel.ScheduleBlog(id, req.PublishAt); err != nil {
+		respondError(ctx, "Failed to schedule blog", err)
+		return
+	}
+
+	blog, err := c.blogModel.GetBlogByID(id)
+	if err != nil {
+		respondError(ctx, "Failed to get blog", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, blog.Blog)
+}
+
+// changeStatus checks that the user may change the status of the blog, and
+// then calls change. A change that isn't possible from the current status,
+// such as archiving a draft, is reported as 409 Conflict.
+func (c *BlogController) changeStatus(ctx *gin.Context, verb string, change func(id int) error) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if !c.authorizeBlog(ctx, policy.PublishBlog, id) {
+		return
+	}
+
+	if err := change(id); err != nil {
+		respondError(ctx, "Failed to "+verb+" blog", err)
+		return
+	}
+
+	blog, err := c.blogModel.GetBlogByID(id)
+	if err != nil {
+		respondError(ctx, "Failed to get blog", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, blog.Blog)
+}
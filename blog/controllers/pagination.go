@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"blog/forms"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setLinkHeader sets the Link header of a paginated response.
+//
+// The links point to the same URL as the request, with all filters kept and
+// only the page changed. For example:
+//
+//	Link: </blogs?limit=20&offset=20>; rel="next", </blogs?limit=20&offset=0>; rel="first"
+//
+// In cursor mode (the request had a cursor) the next link uses next_cursor,
+// and there are no prev and last links, because a cursor only points forward.
+//
+// For more information on the Link header, see:
+// https://www.rfc-editor.org/rfc/rfc8288
+func setLinkHeader(ctx *gin.Context, p forms.Pagination) {
+	query := ctx.Request.URL.Query()
+	cursorMode := query.Get("cursor") != ""
+
+	var links []string
+	link := func(rel string, set func(q url.Values)) {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Del("cursor")
+		q.Del("offset")
+		q.Set("limit", strconv.Itoa(p.Limit))
+		set(q)
+
+		u := url.URL{Path: ctx.Request.URL.Path, RawQuery: q.Encode()}
+		links = append(links, fmt.Sprintf("<%s>; rel=%q", u.String(), rel))
+	}
+
+	if p.HasMore {
+		link("next", func(q url.Values) {
+			if cursorMode {
+				q.Set("cursor", p.NextCursor)
+			} else {
+				q.Set("offset", strconv.Itoa(p.Offset+p.Limit))
+			}
+		})
+	}
+	if !cursorMode && p.Offset > 0 {
+		link("prev", func(q url.Values) {
+			prev := p.Offset - p.Limit
+			if prev < 0 {
+				prev = 0
+			}
+			q.Set("offset", strconv.Itoa(prev))
+		})
+	}
+	link("first", func(q url.Values) {})
+	if !cursorMode && p.Total > 0 {
+		link("last", func(q url.Values) {
+			q.Set("offset", strconv.Itoa((p.Total-1)/p.Limit*p.Limit))
+		})
+	}
+
+	ctx.Header("Link", strings.Join(links, ", "))
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"net/http"
+
+	"blog/diff"
+	"blog/forms"
+	"blog/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListRevisions lists the revisions of a blog, newest first.
+//
+// The history of a blog is shown to the users who may update it.
+func (c *BlogController) ListRevisions(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if !c.authorizeBlog(ctx, policy.UpdateBlog, id) {
+		return
+	}
+
+	var query forms.ListRevisionsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	revisions, err := c.blogModel.ListRevisions(id, query)
+	if err != nil {
+		respondError(ctx, "Failed to list revisions", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, revisions)
+}
+
+// GetRevision returns a single revision of a blog.
+func (c *BlogController) GetRevision(ctx *gin.Context) {
+	id, number, ok := c.parseRevisionParams(ctx)
+	if !ok {
+		return
+	}
+
+	revision, err := c.blogModel.GetRevision(id, number)
+	if err != nil {
+		respondError(ctx, "Failed to get revision", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, revision)
+}
+
+// DiffRevisions compares two revisions of a blog. For example:
+//
+//	GET /blogs/1/revisions/diff?from=2&to=5&mode=word
+//
+// The title and content are compared line by line, or word by word.
+func (c *BlogController) DiffRevisions(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if !c.authorizeBlog(ctx, policy.UpdateBlog, id) {
+		return
+	}
+
+	var query forms.DiffQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+	if query.Mode == "" {
+		query.Mode = "line"
+	}
+
+	from, err := c.blogModel.GetRevision(id, query.From)
+	if err != nil {
+		respondError(ctx, "Failed to get revision", err)
+		return
+	}
+	to, err := c.blogModel.GetRevision(id, query.To)
+	if err != nil {
+		respondError(ctx, "Failed to get revision", err)
+		return
+	}
+
+	compare := diff.Lines
+	if query.Mode == "word" {
+		compare = diff.Words
+	}
+
+	ctx.JSON(http.StatusOK, forms.DiffResponse{
+		From:    query.From,
+		To:      query.To,
+		Mode:    query.Mode,
+		Title:   compare(from.Title, to.Title),
+		Content: compare(from.Content, to.Content),
+	})
+}
+
+// RestoreRevision makes an old revision the current version of a blog. The
+// restored version is saved as a new revision, and the updated blog is
+// returned.
+func (c *BlogController) RestoreRevision(ctx *gin.Context) {
+	id, number, ok := c.parseRevisionParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.blogModel.RestoreRevision(id, number, currentUserID(ctx)); err != nil {
+		respondError(ctx, "Failed to restore revision", err)
+		return
+	}
+
+	blog, err := c.blogModel.GetBlogByID(id)
+	if err != nil {
+		respondError(ctx, "Failed to get blog", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, blog.Blog)
+}
+
+// parseRevisionParams reads the blog ID and revision number from the URL and
+// checks that the user may see the history of the blog. If not, it writes
+// an error response and returns false.
+func (c *BlogController) parseRevisionParams(ctx *gin.Context) (int, int, bool) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return 0, 0, false
+	}
+
+	number, ok := parseIDParam(ctx, "revision")
+	if !ok {
+		return 0, 0, false
+	}
+
+	if !c.authorizeBlog(ctx, policy.UpdateBlog, id) {
+		return 0, 0, false
+	}
+
+	return id, number, true
+}
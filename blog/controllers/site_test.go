@@ -0,0 +1,178 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"blog/forms"
+	"blog/policy"
+)
+
+// get sends a GET request to the site with the given cookies.
+func (a *testAPI) get(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// post sends a form to the site with the given cookies, and the CSRF token
+// that goes with them.
+func (a *testAPI) post(path string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	a.t.Helper()
+
+	csrf := cookie(a.get("/login"), "blog_csrf")
+	if csrf == nil {
+		a.t.Fatal("GET /login set no CSRF cookie")
+	}
+	form.Set("csrf_token", csrf.Value)
+
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(csrf)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// cookie returns the cookie with the given name that a response sets, or
+// nil.
+func cookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// login logs a user in on the site and returns the session and refresh
+// cookies.
+func (a *testAPI) login(name string) (session, refresh *http.Cookie) {
+	a.t.Helper()
+
+	rec := a.post("/login", url.Values{"email": {name + "@example.com"}, "password": {"correct horse"}})
+	if rec.Code != http.StatusSeeOther {
+		a.t.Fatalf("POST /login: status %d, want %d: %s", rec.Code, http.StatusSeeOther, rec.Body)
+	}
+	session, refresh = cookie(rec, "blog_session"), cookie(rec, "blog_refresh")
+	if session == nil || refresh == nil {
+		a.t.Fatal("POST /login didn't set the session and refresh cookies")
+	}
+	return session, refresh
+}
+
+// loggedIn reports whether a page was rendered for the user.
+func loggedIn(rec *httptest.ResponseRecorder, name string) bool {
+	return rec.Code == http.StatusOK && strings.Contains(rec.Body.String(), "<span>"+name+"</span>")
+}
+
+func TestSiteSession(t *testing.T) {
+	api := newTestAPI(t)
+	api.user("ann", policy.RoleAuthor)
+
+	session, refresh := api.login("ann")
+	if rec := api.get("/", session); !loggedIn(rec, "ann") {
+		t.Fatalf("GET / with the session cookie isn't logged in: %d", rec.Code)
+	}
+
+	// Without an access token, the refresh cookie gets a new one. The
+	// browser sends the same refresh cookie with all the requests it makes
+	// before it sees the new access token, which must all work, and keep
+	// working.
+	for i := 0; i < 3; i++ {
+		rec := api.get("/", refresh)
+		if !loggedIn(rec, "ann") {
+			t.Fatalf("GET / %d with the refresh cookie isn't logged in: %d", i+1, rec.Code)
+		}
+		if cookie(rec, "blog_session") == nil {
+			t.Errorf("GET / %d with the refresh cookie set no session cookie", i+1)
+		}
+		if c := cookie(rec, "blog_refresh"); c != nil {
+			t.Errorf("GET / %d with the refresh cookie changed it to %q", i+1, c.Value)
+		}
+	}
+
+	// Logging out revokes the refresh token, so it can't get a new access
+	// token any more, and its cookies are deleted.
+	if rec := api.post("/logout", url.Values{}, refresh); rec.Code != http.StatusSeeOther {
+		t.Fatalf("POST /logout: status %d, want %d", rec.Code, http.StatusSeeOther)
+	}
+	rec := api.get("/", refresh)
+	if loggedIn(rec, "ann") {
+		t.Fatal("GET / with a revoked refresh cookie is logged in")
+	}
+	if c := cookie(rec, "blog_refresh"); c == nil || c.MaxAge >= 0 {
+		t.Errorf("GET / with a revoked refresh cookie didn't delete it")
+	}
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+	api := newTestAPI(t)
+	ann := api.user("ann", policy.RoleAuthor).AccessToken
+	first := api.createBlog(ann, forms.CreateBlogRequest{Title: "First", Content: "One", Status: forms.StatusPublished})
+	second := api.createBlog(ann, forms.CreateBlogRequest{Title: "Second", Content: "Two", Status: forms.StatusPublished})
+
+	rec := api.get("/feed.atom")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /feed.atom: status %d, want %d", rec.Code, http.StatusOK)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("GET /feed.atom has no ETag")
+	}
+	if lm := rec.Header().Get("Last-Modified"); lm != "" {
+		t.Errorf("GET /feed.atom has Last-Modified %s, want none", lm)
+	}
+	if !strings.Contains(rec.Body.String(), "<title>Second</title>") {
+		t.Errorf("GET /feed.atom doesn't list Second:\n%s", rec.Body)
+	}
+
+	conditional := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/feed.atom", nil)
+		req.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		api.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := conditional("If-None-Match", etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("GET /feed.atom with its ETag: status %d with %d bytes, want %d without a body", rec.Code, rec.Body.Len(), http.StatusNotModified)
+	}
+	// Other formats of the same feed have their own ETag.
+	if other := api.get("/feed.rss").Header().Get("ETag"); other == etag {
+		t.Error("the RSS and Atom feeds have the same ETag")
+	}
+	// If-Modified-Since alone is ignored, since there is no Last-Modified.
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if rec := conditional("If-Modified-Since", future); rec.Code != http.StatusOK {
+		t.Errorf("GET /feed.atom with If-Modified-Since: status %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// Removing a post changes the ETag, even though no post of the feed was
+	// updated, so readers don't keep it.
+	api.do("POST", blogPath(second)+"/unpublish", ann, nil, http.StatusOK, nil)
+	rec = conditional("If-None-Match", etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /feed.atom after unpublishing: status %d, want %d", rec.Code, http.StatusOK)
+	}
+	if strings.Contains(rec.Body.String(), "<title>Second</title>") {
+		t.Error("GET /feed.atom still lists Second after it was unpublished")
+	}
+	etag = rec.Header().Get("ETag")
+
+	api.do("DELETE", blogPath(first), ann, nil, http.StatusOK, nil)
+	if rec := conditional("If-None-Match", etag); rec.Code != http.StatusOK {
+		t.Errorf("GET /feed.atom after deleting: status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"net/http"
+
+	"blog/forms"
+	"blog/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTags returns a page of the tags, sorted by name.
+func (c *BlogController) ListTags(ctx *gin.Context) {
+	var query forms.ListTagsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	page, err := c.blogModel.ListTags(query)
+	if err != nil {
+		respondError(ctx, "Failed to list tags", err)
+		return
+	}
+
+	setLinkHeader(ctx, page.Pagination)
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// TagCloud returns the tags of the published blogs with the number of blogs
+// that have them, the most used first. For example:
+//
+//	GET /tags/cloud?limit=20
+func (c *BlogController) TagCloud(ctx *gin.Context) {
+	var query forms.TagCloudQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	cloud, err := c.blogModel.TagCloud(query)
+	if err != nil {
+		respondError(ctx, "Failed to get tag cloud", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, cloud)
+}
+
+// GetTag returns a single tag.
+func (c *BlogController) GetTag(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	tag, err := c.blogModel.GetTag(id)
+	if err != nil {
+		respondError(ctx, "Failed to get tag", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tag)
+}
+
+// CreateTag creates a tag and returns it. Only editors and admins may manage
+// tags; authors create tags by adding them to their blogs.
+func (c *BlogController) CreateTag(ctx *gin.Context) {
+	if !authorize(ctx, policy.ManageTaxonomy, 0) {
+		return
+	}
+
+	var req forms.TagRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	tag, err := c.blogModel.CreateTag(req)
+	if err != nil {
+		respondError(ctx, "Failed to create tag", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tag)
+}
+
+// UpdateTag renames a tag and returns it.
+func (c *BlogController) UpdateTag(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if !authorize(ctx, policy.ManageTaxonomy, 0) {
+		return
+	}
+
+	var req forms.TagRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	tag, err := c.blogModel.UpdateTag(id, req)
+	if err != nil {
+		respondError(ctx, "Failed to update tag", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag deletes a tag and removes it from its blogs.
+func (c *BlogController) DeleteTag(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if !authorize(ctx, policy.ManageTaxonomy, 0) {
+		return
+	}
+
+	if err := c.blogModel.DeleteTag(id); err != nil {
+		respondError(ctx, "Failed to delete tag", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
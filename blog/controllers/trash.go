@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"blog/forms"
+	"blog/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTrashedBlogs lists the blogs in the trash, most recently deleted first.
+//
+// Users see the blogs they may restore: their own, or all of them for those
+// who may delete anybody's blogs.
+func (c *BlogController) ListTrashedBlogs(ctx *gin.Context) {
+	query, ok := trashQuery(ctx, policy.DeleteBlog)
+	if !ok {
+		return
+	}
+
+	page, err := c.blogModel.ListTrashedBlogs(query)
+	if err != nil {
+		respondError(ctx, "Failed to list deleted blogs", err)
+		return
+	}
+
+	setLinkHeader(ctx, page.Pagination)
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// ListTrashedComments lists the comments in the trash, most recently deleted
+// first, in the same way as ListTrashedBlogs.
+func (c *BlogController) ListTrashedComments(ctx *gin.Context) {
+	query, ok := trashQuery(ctx, policy.DeleteComment)
+	if !ok {
+		return
+	}
+
+	page, err := c.blogModel.ListTrashedComments(query)
+	if err != nil {
+		respondError(ctx, "Failed to list deleted comments", err)
+		return
+	}
+
+	setLinkHeader(ctx, page.Pagination)
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// RestoreBlog takes a blog out of the trash and returns it. Whoever may
+// delete a blog may restore it.
+func (c *BlogController) RestoreBlog(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	info, err := c.blogModel.GetBlogInfo(id)
+	if err != nil {
+		respondError(ctx, "Failed to get blog", err)
+		return
+	}
+	if !info.Deleted {
+		writeProblem(ctx, problemNotFound, fmt.Sprintf("blog %d is not in the trash", id), nil)
+		return
+	}
+	if !authorize(ctx, policy.DeleteBlog, info.AuthorID) {
+		return
+	}
+
+	if err := c.blogModel.RestoreBlog(id); err != nil {
+		respondError(ctx, "Failed to restore blog", err)
+		return
+	}
+
+	blog, err := c.blogModel.GetBlogByID(id)
+	if err != nil {
+		respondError(ctx, "Failed to get blog", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, blog.Blog)
+}
+
+// RestoreComment takes a comment out of the trash, together with its
+// replies. Whoever may delete a comment may restore it. The blog of the
+// comment must not be in the trash.
+func (c *BlogController) RestoreComment(ctx *gin.Context) {
+	blogID, commentID, ok := parseCommentParams(ctx)
+	if !ok {
+		return
+	}
+
+	if _, ok := c.getVisibleBlog(ctx, blogID); !ok {
+		return
+	}
+
+	info, err := c.blogModel.GetCommentInfo(blogID, commentID)
+	if err != nil {
+		respondError(ctx, "Failed to get comment", err)
+		return
+	}
+	if !info.Deleted {
+		writeProblem(ctx, problemNotFound, fmt.Sprintf("comment %d is not in the trash", commentID), nil)
+		return
+	}
+	if !authorize(ctx, policy.DeleteComment, info.AuthorID) {
+		return
+	}
+
+	if err := c.blogModel.RestoreComment(blogID, commentID); err != nil {
+		respondError(ctx, "Failed to restore comment", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Comment restored successfully"})
+}
+
+// trashQuery binds the query string of a trash listing. Users who may do the
+// action to anybody's items see all of them, and other users only their own.
+func trashQuery(ctx *gin.Context, action policy.Action) (forms.TrashQuery, bool) {
+	var query forms.TrashQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindError(ctx, err)
+		return forms.TrashQuery{}, false
+	}
+
+	user := currentPolicyUser(ctx)
+	query.ViewAll = policy.Allowed(user, action, 0)
+	query.ViewerID = user.ID
+
+	return query, true
+}
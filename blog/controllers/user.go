@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"blog/auth"
+	"blog/forms"
+	"blog/models"
+	"blog/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserController is a controller for user accounts and their tokens.
+type UserController struct {
+	userModel  models.UserStore
+	tokenModel models.TokenStore
+	tokens     *auth.TokenManager
+}
+
+// NewUserController creates a new UserController.
+func NewUserController(userModel models.UserStore, tokenModel models.TokenStore, tokens *auth.TokenManager) *UserController {
+	return &UserController{
+		userModel:  userModel,
+		tokenModel: tokenModel,
+		tokens:     tokens,
+	}
+}
+
+// Register creates a new user account.
+func (c *UserController) Register(ctx *gin.Context) {
+	var req forms.RegisterRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	// Hash the password. We never store the password itself.
+	// For more information on auth.HashPassword, see:
+	// blog/auth/password.go
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		respondError(ctx, "Failed to register", err)
+		return
+	}
+
+	// Call the CreateUser method on the UserModel. If the email address is
+	// already taken, it returns an ErrConflict error, which respondError
+	// reports as 409 Conflict.
+	user, err := c.userModel.CreateUser(req, hash)
+	if err != nil {
+		respondError(ctx, "Failed to register", err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, user)
+}
+
+// Login checks an email address and password, and returns a new access token
+// and refresh token.
+func (c *UserController) Login(ctx *gin.Context) {
+	var req forms.LoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	user, err := c.authenticate(req)
+	if err != nil {
+		// We don't say whether the email address or the password is wrong,
+		// so that the response can't be used to find out which email
+		// addresses have an account.
+		if errors.Is(err, errInvalidCredentials) {
+			writeProblem(ctx, problemInvalidCredentials, "The email address or password is incorrect.", nil)
+			return
+		}
+		respondError(ctx, "Failed to log in", err)
+		return
+	}
+
+	// Every login starts a new family of refresh tokens.
+	family, err := auth.NewTokenFamily()
+	if err != nil {
+		respondError(ctx, "Failed to log in", err)
+		return
+	}
+
+	refreshToken, hash, err := c.tokens.NewRefreshToken()
+	if err != nil {
+		respondError(ctx, "Failed to log in", err)
+		return
+	}
+
+	if err := c.tokenModel.CreateRefreshToken(models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  family,
+		Hash:      hash,
+		ExpiresAt: time.Now().Add(c.tokens.RefreshTokenTTL()),
+	}); err != nil {
+		respondError(ctx, "Failed to log in", err)
+		return
+	}
+
+	c.respondTokens(ctx, user, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token.
+//
+// The old refresh token is revoked. If it is used again, all the tokens that
+// came from the same login are revoked, because the token has probably been
+// stolen.
+func (c *UserController) Refresh(ctx *gin.Context) {
+	var req forms.RefreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	refreshToken, hash, err := c.tokens.NewRefreshToken()
+	if err != nil {
+		respondError(ctx, "Failed to refresh token", err)
+		return
+	}
+
+	next, err := c.tokenModel.RotateRefreshToken(auth.HashRefreshToken(req.RefreshToken), models.RefreshToken{
+		Hash:      hash,
+		ExpiresAt: time.Now().Add(c.tokens.RefreshTokenTTL()),
+	})
+	if errors.Is(err, models.ErrNotFound) || errors.Is(err, models.ErrConflict) {
+		writeProblem(ctx, problemInvalidToken, "The refresh token is invalid, expired or has been revoked.", nil)
+		return
+	}
+	if err != nil {
+		respondError(ctx, "Failed to refresh token", err)
+		return
+	}
+
+	user, err := c.userModel.GetUserByID(next.UserID)
+	if err != nil {
+		respondError(ctx, "Failed to refresh token", err)
+		return
+	}
+
+	c.respondTokens(ctx, user, refreshToken)
+}
+
+// Logout revokes a refresh token, and all the tokens that came from the same
+// login. Access tokens stay valid until they expire, which is why they are
+// short-lived.
+func (c *UserController) Logout(ctx *gin.Context) {
+	var req forms.RefreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	err := c.tokenModel.RevokeRefreshTokenFamily(auth.HashRefreshToken(req.RefreshToken))
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		respondError(ctx, "Failed to log out", err)
+		return
+	}
+
+	// Logging out with an unknown token also succeeds, so that a client can
+	// always clear its state.
+	ctx.Status(http.StatusNoContent)
+}
+
+// SetRole changes the role of a user. Only admins may do this.
+//
+// The new role shows up in the user's access tokens when they are refreshed.
+func (c *UserController) SetRole(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if !authorize(ctx, policy.ManageUsers, 0) {
+		return
+	}
+
+	var req forms.SetRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	// An admin who takes away their own role could lock everybody out, so
+	// another admin has to do it.
+	if id == currentUserID(ctx) {
+		writeProblem(ctx, problemForbidden, "You cannot change your own role.", nil)
+		return
+	}
+
+	user, err := c.userModel.SetUserRole(id, policy.Role(req.Role))
+	if err != nil {
+		respondError(ctx, "Failed to set role", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// respondTokens issues an access token for the user and writes it together
+// with the refresh token.
+func (c *UserController) respondTokens(ctx *gin.Context, user forms.User, refreshToken string) {
+	accessToken, _, err := c.tokens.IssueAccessToken(user.ID, user.Name, user.Role)
+	if err != nil {
+		respondError(ctx, "Failed to issue token", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, forms.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(c.tokens.AccessTokenTTL().Seconds()),
+		RefreshToken: refreshToken,
+		User:         user,
+	})
+}
+
+// errInvalidCredentials is returned by authenticate when the email address
+// or password is wrong.
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// authenticate returns the user with the given email address and password.
+func (c *UserController) authenticate(req forms.LoginRequest) (forms.User, error) {
+	user, hash, err := c.userModel.GetUserByEmail(req.Email)
+	if errors.Is(err, models.ErrNotFound) {
+		// Check a dummy password, so that a login for an unknown email
+		// address takes as long as one with a wrong password.
+		auth.CheckNoPassword(req.Password)
+		return forms.User{}, errInvalidCredentials
+	}
+	if err != nil {
+		return forms.User{}, err
+	}
+
+	ok, err := auth.CheckPassword(hash, req.Password)
+	if err != nil {
+		return forms.User{}, err
+	}
+	if !ok {
+		return forms.User{}, errInvalidCredentials
+	}
+
+	return user, nil
+}
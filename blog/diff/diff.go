@@ -0,0 +1,235 @@
+// Package diff compares two texts line by line or word by word.
+//
+// It uses the Myers diff algorithm, which finds the smallest set of
+// insertions and deletions that turns one text into the other. It is the
+// algorithm used by git diff.
+//
+// For more information on the algorithm, see:
+// http://www.xmailserver.org/diff2.pdf
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kind is the kind of an Op.
+type Kind string
+
+// The kinds of Op.
+const (
+	Equal  Kind = "equal"
+	Insert Kind = "insert"
+	Delete Kind = "delete"
+)
+
+// Op is one step of a diff: a piece of text that is in both texts, only in
+// the new text, or only in the old text.
+type Op struct {
+	Kind Kind   `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxEdits bounds the work done by the algorithm, and the memory it uses,
+// which both grow with the square of the number of differences: at the
+// limit, the trace holds about a million ints. Texts that differ by more
+// than this many lines or words are reported as completely replaced.
+const maxEdits = 1000
+
+// Lines compares two texts line by line. Every line keeps its newline, so
+// joining the texts of the Equal and Insert ops gives the new text, and
+// joining the Equal and Delete ops gives the old one.
+func Lines(a, b string) []Op {
+	return Tokens(splitLines(a), splitLines(b))
+}
+
+// Words compares two texts word by word. Whitespace and punctuation are
+// tokens of their own, so a changed word doesn't take its neighbours along.
+func Words(a, b string) []Op {
+	return Tokens(splitWords(a), splitWords(b))
+}
+
+// Tokens compares two lists of tokens. Neighbouring tokens of the same kind
+// are merged into one Op.
+func Tokens(a, b []string) []Op {
+	// Most edits only change the middle of a text. Taking the common
+	// beginning and end off first makes the algorithm much faster for them.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	ops = appendOps(ops, Equal, a[:prefix])
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	ops = appendOps(ops, Equal, a[len(a)-suffix:])
+
+	return merge(ops)
+}
+
+// myers returns the ops that turn a into b.
+//
+// The algorithm looks for the shortest path through a grid where moving
+// right deletes a token of a, moving down inserts a token of b, and moving
+// diagonally keeps a token that is in both. v[k] is the furthest x reached on
+// diagonal k (where k = x - y) with d edits. The states of v are kept in trace
+// so that the path can be followed back from the end. Step d only reaches the
+// diagonals -d to d, so only those, and one on either side, are kept:
+// trace[d][d+1+k] is v[k] before step d.
+func myers(a, b []string) []Op {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return append(appendOps(nil, Delete, a), appendOps(nil, Insert, b)...)
+	}
+
+	limit := n + m
+	if limit > maxEdits {
+		limit = maxEdits
+	}
+
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // Move down: insert.
+			} else {
+				x = v[offset+k-1] + 1 // Move right: delete.
+			}
+			y := x - k
+
+			// Follow the diagonal as long as the tokens are equal.
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+
+	// The texts are too different. Report them as replaced.
+	return append(appendOps(nil, Delete, a), appendOps(nil, Insert, b)...)
+}
+
+// backtrack follows the path found by myers back from the end, and returns
+// its ops in order.
+func backtrack(a, b []string, trace [][]int) []Op {
+	x, y := len(a), len(b)
+
+	var reversed []Op
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		offset := d + 1
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, Op{Equal, a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, Op{Insert, b[prevY]})
+			} else {
+				reversed = append(reversed, Op{Delete, a[prevX]})
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	ops := make([]Op, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// appendOps appends one op of the given kind per token.
+func appendOps(ops []Op, kind Kind, tokens []string) []Op {
+	for _, t := range tokens {
+		ops = append(ops, Op{kind, t})
+	}
+	return ops
+}
+
+// merge joins neighbouring ops of the same kind.
+func merge(ops []Op) []Op {
+	merged := make([]Op, 0, len(ops))
+	for _, op := range ops {
+		if n := len(merged); n > 0 && merged[n-1].Kind == op.Kind {
+			merged[n-1].Text += op.Text
+			continue
+		}
+		merged = append(merged, op)
+	}
+	return merged
+}
+
+// splitLines splits a text into lines, keeping the newlines.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+
+	// A text that ends with a newline has an empty string after it.
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// splitWords splits a text into words, runs of whitespace, and single
+// punctuation characters.
+func splitWords(s string) []string {
+	var tokens []string
+	start := 0
+	runes := []rune(s)
+	for i := 1; i <= len(runes); i++ {
+		if i == len(runes) || class(runes[i]) != class(runes[i-1]) || class(runes[i]) == punctuation {
+			tokens = append(tokens, string(runes[start:i]))
+			start = i
+		}
+	}
+	return tokens
+}
+
+// The classes of characters used by splitWords.
+const (
+	letter = iota
+	space
+	punctuation
+)
+
+// class returns the class of a character.
+func class(r rune) int {
+	switch {
+	case unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' || r == '\'':
+		return letter
+	case unicode.IsSpace(r):
+		return space
+	default:
+		return punctuation
+	}
+}
@@ -0,0 +1,241 @@
+package diff
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// checkOps checks that ops turn a into b: the Equal and Delete ops give a,
+// the Equal and Insert ops give b, and neighbouring ops are merged.
+func checkOps(t *testing.T, a, b string, ops []Op) {
+	t.Helper()
+
+	var old, new strings.Builder
+	for i, op := range ops {
+		if op.Text == "" {
+			t.Errorf("op %d is empty", i)
+		}
+		if i > 0 && ops[i-1].Kind == op.Kind {
+			t.Errorf("ops %d and %d are both %s", i-1, i, op.Kind)
+		}
+
+		switch op.Kind {
+		case Equal:
+			old.WriteString(op.Text)
+			new.WriteString(op.Text)
+		case Delete:
+			old.WriteString(op.Text)
+		case Insert:
+			new.WriteString(op.Text)
+		default:
+			t.Errorf("op %d has unknown kind %q", i, op.Kind)
+		}
+	}
+
+	if old.String() != a {
+		t.Errorf("equal+delete = %q; want %q", old.String(), a)
+	}
+	if new.String() != b {
+		t.Errorf("equal+insert = %q; want %q", new.String(), b)
+	}
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Op
+	}{
+		{"both empty", "", "", []Op{}},
+		{"from empty", "", "one\ntwo\n", []Op{{Insert, "one\ntwo\n"}}},
+		{"to empty", "one\ntwo\n", "", []Op{{Delete, "one\ntwo\n"}}},
+		{"equal", "one\ntwo\n", "one\ntwo\n", []Op{{Equal, "one\ntwo\n"}}},
+		{
+			"changed line",
+			"one\ntwo\nthree\n", "one\n2\nthree\n",
+			[]Op{{Equal, "one\n"}, {Delete, "two\n"}, {Insert, "2\n"}, {Equal, "three\n"}},
+		},
+		{
+			"inserted line",
+			"one\nthree\n", "one\ntwo\nthree\n",
+			[]Op{{Equal, "one\n"}, {Insert, "two\n"}, {Equal, "three\n"}},
+		},
+		{
+			"deleted line",
+			"one\ntwo\nthree\n", "one\nthree\n",
+			[]Op{{Equal, "one\n"}, {Delete, "two\n"}, {Equal, "three\n"}},
+		},
+		{
+			"no newline at the end",
+			"one\ntwo", "one\ntwo\n",
+			[]Op{{Equal, "one\n"}, {Delete, "two"}, {Insert, "two\n"}},
+		},
+		{
+			"moved line",
+			"a\nb\nc\n", "b\nc\na\n",
+			[]Op{{Delete, "a\n"}, {Equal, "b\nc\n"}, {Insert, "a\n"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.a, tt.b)
+			checkOps(t, tt.a, tt.b, got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines(%q, %q) = %v; want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Op
+	}{
+		{"both empty", "", "", []Op{}},
+		{"from empty", "", "Hello, world!", []Op{{Insert, "Hello, world!"}}},
+		{"to empty", "Hello, world!", "", []Op{{Delete, "Hello, world!"}}},
+		{
+			"changed word",
+			"the quick brown fox", "the slow brown fox",
+			[]Op{{Equal, "the "}, {Delete, "quick"}, {Insert, "slow"}, {Equal, " brown fox"}},
+		},
+		{
+			// The comma is a token of its own, so the word before it
+			// is kept.
+			"punctuation",
+			"Hello world", "Hello, world",
+			[]Op{{Equal, "Hello"}, {Insert, ","}, {Equal, " world"}},
+		},
+		{
+			"apostrophes belong to the word",
+			"it's fine", "it is fine",
+			[]Op{{Delete, "it's"}, {Insert, "it is"}, {Equal, " fine"}},
+		},
+		{
+			"unicode",
+			"größer als", "kleiner als",
+			[]Op{{Delete, "größer"}, {Insert, "kleiner"}, {Equal, " als"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Words(tt.a, tt.b)
+			checkOps(t, tt.a, tt.b, got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Words(%q, %q) = %v; want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"word", []string{"word"}},
+		{"two  words", []string{"two", "  ", "words"}},
+		{"a.b", []string{"a", ".", "b"}},
+		{"!!", []string{"!", "!"}},
+		{" x_1 ", []string{" ", "x_1", " "}},
+	}
+
+	for _, tt := range tests {
+		if got := splitWords(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitWords(%q) = %q; want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+// TestReconstruct checks that the ops give back both texts for pairs of
+// texts that share some lines, in different places.
+func TestReconstruct(t *testing.T) {
+	texts := []string{
+		"",
+		"a\n",
+		"a\nb\nc\nd\ne\n",
+		"e\nd\nc\nb\na\n",
+		"a\nx\nc\ny\ne\nz\n",
+		"x\nx\nx\na\n",
+		"a\nb\na\nb\na\nb",
+	}
+
+	for _, a := range texts {
+		for _, b := range texts {
+			checkOps(t, a, b, Lines(a, b))
+			checkOps(t, a, b, Words(a, b))
+		}
+	}
+}
+
+func TestTooManyEdits(t *testing.T) {
+	// Two texts without a line in common, longer than maxEdits, are
+	// reported as replaced rather than diffed.
+	var a, b strings.Builder
+	for i := 0; i < maxEdits; i++ {
+		a.WriteString("a\n")
+		b.WriteString("b\n")
+	}
+
+	got := Lines(a.String(), b.String())
+	want := []Op{{Delete, a.String()}, {Insert, b.String()}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines of completely different texts = %d ops; want delete and insert", len(got))
+	}
+}
+
+// TestMinimal checks on random texts that the diff is as small as possible:
+// the number of deleted and inserted tokens must match the longest common
+// subsequence, computed the slow way.
+func TestMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []string {
+		tokens := make([]string, rng.Intn(30))
+		for i := range tokens {
+			tokens[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return tokens
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := random(), random()
+
+		edits := 0
+		for _, op := range Tokens(a, b) {
+			if op.Kind != Equal {
+				edits += len(op.Text)
+			}
+		}
+
+		if want := len(a) + len(b) - 2*lcs(a, b); edits != want {
+			t.Fatalf("Tokens(%q, %q) has %d edits; want %d", a, b, edits, want)
+		}
+	}
+}
+
+// lcs returns the length of the longest common subsequence of a and b.
+func lcs(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		curr := make([]int, len(b)+1)
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				curr[j+1] = prev[j] + 1
+			case prev[j+1] > curr[j]:
+				curr[j+1] = prev[j+1]
+			default:
+				curr[j+1] = curr[j]
+			}
+		}
+		prev = curr
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,202 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	published = time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600))
+	updated   = time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)
+)
+
+// testFeed has an item with everything, and one without author and tags.
+var testFeed = Feed{
+	Title:       "Blog",
+	Description: "The latest posts",
+	URL:         "https://blog.example.com/feed.rss",
+	Link:        "https://blog.example.com/",
+	Updated:     updated,
+	Items: []Item{
+		{
+			ID:          "https://blog.example.com/blogs/2",
+			Title:       "Fish & <chips>",
+			Link:        "https://blog.example.com/posts/fish-chips",
+			ContentHTML: `<p>Hello <a href="https://go.dev">Go</a> & friends</p>`,
+			Author:      "Ann",
+			Tags:        []string{"Go", "Food"},
+			Published:   published,
+			Updated:     updated,
+		},
+		{
+			ID:        "https://blog.example.com/blogs/1",
+			Title:     "First",
+			Link:      "https://blog.example.com/posts/first",
+			Published: published,
+			Updated:   published,
+		},
+	},
+}
+
+func TestRSS(t *testing.T) {
+	data, err := RSS(testFeed)
+	if err != nil {
+		t.Fatalf("RSS: %v", err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Errorf("RSS doesn't start with the XML header: %.60s", data)
+	}
+
+	var doc rss
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("RSS isn't valid XML: %v\n%s", err, data)
+	}
+	ch := doc.Channel
+	if doc.Version != "2.0" || ch.Title != "Blog" || ch.Description != testFeed.Description {
+		t.Errorf("channel = %+v", ch)
+	}
+	// The decoder can't tell link from atom:link, so they are checked in
+	// the XML.
+	for _, want := range []string{
+		"<link>https://blog.example.com/</link>",
+		`<atom:link href="https://blog.example.com/feed.rss" rel="self" type="application/rss+xml"></atom:link>`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("RSS doesn't contain %s", want)
+		}
+	}
+	if ch.LastBuildDate != "Sat, 02 Mar 2024 10:00:00 +0000" {
+		t.Errorf("lastBuildDate = %q", ch.LastBuildDate)
+	}
+	if len(ch.Items) != 2 {
+		t.Fatalf("RSS has %d items, want 2", len(ch.Items))
+	}
+
+	item := ch.Items[0]
+	if item.Title != "Fish & <chips>" || item.Description != testFeed.Items[0].ContentHTML {
+		t.Errorf("item title and description = %q, %q; want them unchanged", item.Title, item.Description)
+	}
+	if item.GUID.Value != testFeed.Items[0].ID || item.GUID.IsPermaLink {
+		t.Errorf("guid = %+v, want the ID, not a permalink", item.GUID)
+	}
+	// The publication time is written in UTC.
+	if item.PubDate != "Fri, 01 Mar 2024 08:30:00 +0000" {
+		t.Errorf("pubDate = %q", item.PubDate)
+	}
+	if len(item.Categories) != 2 || item.Categories[0] != "Go" {
+		t.Errorf("categories = %q, want Go and Food", item.Categories)
+	}
+
+	// The content is escaped, not pasted in.
+	if strings.Contains(string(data), "<p>") {
+		t.Error("RSS has unescaped HTML")
+	}
+	// Without an author, there is no dc:creator.
+	if n := strings.Count(string(data), "<dc:creator>"); n != 1 {
+		t.Errorf("RSS has %d dc:creator elements, want 1", n)
+	}
+}
+
+func TestAtom(t *testing.T) {
+	data, err := Atom(testFeed)
+	if err != nil {
+		t.Fatalf("Atom: %v", err)
+	}
+
+	var doc atomFeed
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Atom isn't valid XML: %v\n%s", err, data)
+	}
+	if doc.ID != testFeed.URL || doc.Updated != "2024-03-02T10:00:00Z" || len(doc.Links) != 2 {
+		t.Errorf("feed = %+v", doc)
+	}
+	if len(doc.Entries) != 2 {
+		t.Fatalf("Atom has %d entries, want 2", len(doc.Entries))
+	}
+
+	entry := doc.Entries[0]
+	if entry.ID != testFeed.Items[0].ID || entry.Title != "Fish & <chips>" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if entry.Published != "2024-03-01T08:30:00Z" || entry.Updated != "2024-03-02T10:00:00Z" {
+		t.Errorf("entry published and updated = %s, %s", entry.Published, entry.Updated)
+	}
+	if entry.Content.Type != "html" || entry.Content.Value != testFeed.Items[0].ContentHTML {
+		t.Errorf("entry content = %+v", entry.Content)
+	}
+	if entry.Author == nil || entry.Author.Name != "Ann" {
+		t.Errorf("entry author = %+v, want Ann", entry.Author)
+	}
+
+	// An entry without an author has the author of the feed.
+	if doc.Entries[1].Author != nil || doc.Author == nil || doc.Author.Name != "Blog" {
+		t.Errorf("authors of the feed and the second entry = %+v, %+v; want Blog and none", doc.Author, doc.Entries[1].Author)
+	}
+	if strings.Contains(string(data), "<p>") {
+		t.Error("Atom has unescaped HTML")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data, err := JSON(testFeed)
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+
+	var doc jsonFeed
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("JSON isn't valid: %v\n%s", err, data)
+	}
+	if doc.Version != "https://jsonfeed.org/version/1.1" || doc.FeedURL != testFeed.URL || doc.HomePageURL != testFeed.Link {
+		t.Errorf("feed = %+v", doc)
+	}
+	if len(doc.Items) != 2 {
+		t.Fatalf("JSON has %d items, want 2", len(doc.Items))
+	}
+
+	item := doc.Items[0]
+	if item.ContentHTML != testFeed.Items[0].ContentHTML || item.Title != "Fish & <chips>" {
+		t.Errorf("item = %+v", item)
+	}
+	if item.DatePublished != "2024-03-01T08:30:00Z" || item.DateModified != "2024-03-02T10:00:00Z" {
+		t.Errorf("item dates = %s, %s", item.DatePublished, item.DateModified)
+	}
+	if len(item.Authors) != 1 || item.Authors[0].Name != "Ann" {
+		t.Errorf("item authors = %+v, want Ann", item.Authors)
+	}
+	if doc.Items[1].Authors != nil || doc.Items[1].Tags != nil {
+		t.Errorf("second item = %+v, want no authors and tags", doc.Items[1])
+	}
+
+	// The HTML is readable rather than escaped as \u003c and so on.
+	if !strings.Contains(string(data), `"content_html": "<p>Hello`) {
+		t.Errorf("JSON escapes the HTML:\n%s", data)
+	}
+}
+
+// TestEmpty checks that a feed without posts is still a valid feed.
+func TestEmpty(t *testing.T) {
+	f := testFeed
+	f.Items = nil
+
+	data, err := JSON(f)
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	if !strings.Contains(string(data), `"items": []`) {
+		t.Errorf("empty JSON feed has no items array:\n%s", data)
+	}
+
+	for name, write := range map[string]func(Feed) ([]byte, error){"RSS": RSS, "Atom": Atom} {
+		data, err := write(f)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := xml.Unmarshal(data, new(struct{})); err != nil {
+			t.Errorf("empty %s feed isn't valid XML: %v", name, err)
+		}
+	}
+}
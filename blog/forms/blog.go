@@ -20,12 +20,39 @@ import "time"
 type CreateBlogRequest struct {
 	Title   string `json:"title" binding:"required"`
 	Content string `json:"content" binding:"required"`
+
+	// Language is used to stem the words of the blog for full-text search.
+	// It defaults to DefaultLanguage.
+	Language string `json:"language" binding:"omitempty,language"`
+
+	// Status is either draft (the default) or published. A draft can be
+	// published later with POST /blogs/:id/publish.
+	Status string `json:"status" binding:"omitempty,oneof=draft published"`
+
+	// Tags are the names of the tags of the blog. Tags that don't exist yet
+	// are created.
+	Tags []string `json:"tags" binding:"omitempty,max=10,dive,required,max=50"`
+
+	// CategoryID is the ID of the category of the blog, if any.
+	CategoryID *int `json:"category_id" binding:"omitempty,gte=1"`
 }
 
+// The statuses of a blog.
+//
+// A blog starts as a draft, or is published right away. Drafts can be
+// scheduled to be published later. Published blogs can be unpublished (back
+// to draft) or archived. Only published blogs are shown to anonymous readers.
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
 // GetAllBlogsResponse represents a response containing a list of blogs.
 //
-// It contains the ID, title, content, created_at, updated_at and comments
-// fields.
+// It contains the ID, title, content, created_at, updated_at, comments and
+// author fields.
 //
 // The comments field is the number of comments that the blog has.
 //
@@ -35,12 +62,115 @@ type CreateBlogRequest struct {
 // For more information on JSON struct tags, see:
 // https://golang.org/pkg/encoding/json/#Marshal
 type GetAllBlogsResponse struct {
-	ID        int       `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Comments  int       `json:"comments"`
+	ID          int       `json:"id"`
+	Slug        string    `json:"slug"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content,omitempty"`
+	ContentHTML string    `json:"content_html,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Comments    int       `json:"comments"`
+	Author      *Author   `json:"author"`
+
+	Status      string     `json:"status"`
+	PublishedAt *time.Time `json:"published_at"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+
+	Category *BlogCategory `json:"category"`
+	Tags     []Tag         `json:"tags"`
+}
+
+// ListBlogsQuery represents the query string of a request to list blogs.
+//
+// The form struct tags tell gin which query parameter fills which field, and
+// the binding tags validate them. For example:
+//
+//	GET /blogs?limit=10&sort=title&order=asc&title=go
+//
+// Limit and Offset select a page by position. Cursor selects the page after
+// the one that returned it as next_cursor, which stays correct even when new
+// blogs are created in the meantime. A cursor can only be used when sorting by
+// created_at, and not together with an offset.
+//
+// The time filters use the RFC 3339 format, for example 2023-01-31T12:00:00Z.
+//
+// Format selects the content fields of the blogs; see FormatQuery.
+//
+// Tag and Category are slugs. Tag only returns the blogs with that tag, and
+// Category the blogs in that category or in one of its subcategories.
+//
+// Status defaults to published. Other statuses only return the blogs that
+// the caller may see, which the controller sets in ViewerID and ViewAll:
+// ViewAll is true for editors and admins, who see every blog, and ViewerID is
+// the ID of the caller, who sees their own blogs.
+//
+// The content of the blogs is cut to a short preview, unless FullContent is
+// set, which the feeds do.
+//
+// For more information on binding query strings, see:
+// https://gin-gonic.com/docs/examples/only-bind-query-string/
+type ListBlogsQuery struct {
+	Limit  int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Offset int    `form:"offset" binding:"omitempty,gte=0"`
+	Cursor string `form:"cursor"`
+
+	Sort  string `form:"sort" binding:"omitempty,oneof=created_at updated_at published_at title comments"`
+	Order string `form:"order" binding:"omitempty,oneof=asc desc"`
+
+	CreatedAfter  time.Time `form:"created_after"`
+	CreatedBefore time.Time `form:"created_before"`
+	UpdatedAfter  time.Time `form:"updated_after"`
+	UpdatedBefore time.Time `form:"updated_before"`
+	Title         string    `form:"title" binding:"omitempty,max=200"`
+	Status        string    `form:"status" binding:"omitempty,oneof=draft scheduled published archived"`
+	Tag           string    `form:"tag" binding:"omitempty,max=100"`
+	Category      string    `form:"category" binding:"omitempty,max=100"`
+	Format        string    `form:"format" binding:"omitempty,oneof=markdown html both"`
+
+	ViewerID    int  `form:"-"`
+	ViewAll     bool `form:"-"`
+	FullContent bool `form:"-"`
+}
+
+// The defaults for ListBlogsQuery.
+const (
+	DefaultLimit = 20
+	DefaultSort  = "created_at"
+	DefaultOrder = "desc"
+)
+
+// Normalize fills in the defaults for the fields that were not set.
+func (q *ListBlogsQuery) Normalize() {
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Sort == "" {
+		q.Sort = DefaultSort
+	}
+	if q.Order == "" {
+		q.Order = DefaultOrder
+	}
+	if q.Status == "" {
+		q.Status = StatusPublished
+	}
+}
+
+// ListBlogsResponse represents a page of blogs.
+type ListBlogsResponse struct {
+	Data       []GetAllBlogsResponse `json:"data"`
+	Pagination Pagination            `json:"pagination"`
+}
+
+// Pagination describes where a page is in the full list of results.
+//
+// Total is the number of results that match the filters, across all pages.
+// NextCursor is empty on the last page.
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	Total      int    `json:"total"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // GetBlogByIDResponse represents a response containing a single blog.
@@ -48,7 +178,9 @@ type GetAllBlogsResponse struct {
 // It embeds the Blog struct, which means that it has all the same fields as
 // the Blog struct.
 //
-// It also has a Comments field, which is a slice of Comment structs.
+// It also has a Comments field, which is a slice of Comment structs. It only
+// contains the first page of comments; CommentsPagination says how many there
+// are in total. The other pages can be fetched with GET /blogs/:id/comments.
 //
 // This is an example of composition in Go.
 //
@@ -56,7 +188,8 @@ type GetAllBlogsResponse struct {
 // https://golang.org/doc/effective_go.html#embedding
 type GetBlogByIDResponse struct {
 	Blog
-	Comments []Comment `json:"comments"`
+	Comments           []Comment  `json:"comments"`
+	CommentsPagination Pagination `json:"comments_pagination"`
 }
 
 // UpdateBlogRequest represents a request to update a blog.
@@ -65,6 +198,17 @@ type GetBlogByIDResponse struct {
 type UpdateBlogRequest struct {
 	Title   string `json:"title" binding:"required"`
 	Content string `json:"content" binding:"required"`
+
+	// Language is optional. If it is empty, the language is not changed.
+	Language string `json:"language" binding:"omitempty,language"`
+
+	// Tags replaces the tags of the blog, like CreateBlogRequest.Tags. If it
+	// is omitted, the tags are not changed, and an empty list removes them.
+	Tags []string `json:"tags" binding:"omitempty,max=10,dive,required,max=50"`
+
+	// CategoryID moves the blog to another category. If it is omitted, the
+	// category is not changed, and 0 removes the blog from its category.
+	CategoryID *int `json:"category_id" binding:"omitempty,gte=0"`
 }
 
 // CreateCommentRequest represents a request to create a comment.
@@ -76,18 +220,82 @@ type CreateCommentRequest struct {
 
 // Blog represents a blog in the database.
 type Blog struct {
-	ID        int       `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
+	ID int `json:"id"`
+
+	// Slug identifies the blog in URLs, like the ID. It is made from the
+	// title and changes with it; see GET /blogs/by-slug/:slug.
+	Slug string `json:"slug"`
+
+	Title string `json:"title"`
+
+	// Content is the Markdown source of the blog, and ContentHTML the
+	// sanitized HTML rendered from it. Which of them are returned depends
+	// on the format asked for; see FormatQuery.
+	Content     string `json:"content,omitempty"`
+	ContentHTML string `json:"content_html,omitempty"`
+
+	Language  string    `json:"language"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Author is null for blogs without an author.
+	Author *Author `json:"author"`
+
+	// Status is one of the Status constants. PublishedAt is when the blog
+	// was first published, or null if it never was. PublishAt is when a
+	// scheduled blog will be published.
+	Status      string     `json:"status"`
+	PublishedAt *time.Time `json:"published_at"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+
+	// Category is null for blogs without a category. Tags are sorted by
+	// name.
+	Category *BlogCategory `json:"category"`
+	Tags     []Tag         `json:"tags"`
+}
+
+// FormatQuery represents the query string that selects the content fields
+// of the blogs in a response. For example:
+//
+//	GET /blogs/1?format=html
+//
+// Format is markdown for the Markdown source (content), html for the
+// rendered HTML (content_html), or both, which is the default.
+type FormatQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=markdown html both"`
+}
+
+// The formats of FormatQuery.
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatBoth     = "both"
+)
+
+// ScheduleBlogRequest represents a request to publish a draft later.
+//
+// PublishAt uses the RFC 3339 format, for example 2023-01-31T12:00:00Z, and
+// must be in the future.
+type ScheduleBlogRequest struct {
+	PublishAt time.Time `json:"publish_at" binding:"required"`
 }
 
 // Comment represents a comment in the database.
+//
+// ParentID is the ID of the comment that this comment replies to, or null for
+// top-level comments. Depth is 0 for top-level comments, 1 for replies to
+// them, and so on. Path lists the IDs of the comment's ancestors and the
+// comment itself, separated by dots, for example "3.8.12".
 type Comment struct {
 	ID        int       `json:"id"`
 	BlogID    int       `json:"blog_id"`
+	ParentID  *int      `json:"parent_id"`
+	Depth     int       `json:"depth"`
+	Path      string    `json:"path"`
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Author is null for comments without an author.
+	Author *Author `json:"author"`
 }
@@ -0,0 +1,47 @@
+package forms
+
+import "time"
+
+// Category represents a category. Every blog is in at most one category.
+//
+// Categories form a tree. ParentID is the ID of the parent category, or null
+// for top-level categories. Depth is 0 for top-level categories, 1 for their
+// subcategories, and so on. Path lists the slugs of the category's ancestors
+// and the category itself, separated by slashes, for example
+// "programming/go".
+//
+// Slug identifies the category in URLs, for example in
+// GET /blogs?category=programming, which also lists the blogs of its
+// subcategories.
+type Category struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	ParentID  *int      `json:"parent_id"`
+	Depth     int       `json:"depth"`
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BlogCategory is the category of a blog, as it is shown with the blog.
+type BlogCategory struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// CategoryRequest represents a request to create or change a category.
+//
+// ParentID is the ID of the parent category. If it is omitted, the category
+// is a top-level category. A category can't be moved below itself or below
+// one of its subcategories.
+type CategoryRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	ParentID *int   `json:"parent_id" binding:"omitempty,gte=1"`
+}
+
+// ListCategoriesResponse represents all the categories, as a flattened tree:
+// every category is followed by its subcategories, sorted by path.
+type ListCategoriesResponse struct {
+	Data []Category `json:"data"`
+}
@@ -0,0 +1,61 @@
+package forms
+
+// ListCommentsQuery represents the query string of a request to list the
+// comments of a blog. For example:
+//
+//	GET /blogs/1/comments?limit=10&order=desc
+//
+// The pagination works in the same way as for ListBlogsQuery: Limit and
+// Offset select a page by position, and Cursor selects the page after the one
+// that returned it as next_cursor. A cursor can only be used when sorting by
+// created_at.
+//
+// Comments are sorted oldest first by default, so that they read like a
+// conversation.
+//
+// The comments are returned as a flattened tree: the sort order and the
+// pagination apply to the top-level comments, and every top-level comment is
+// followed by all its replies, depth first and oldest first. The depth and
+// path fields of each comment say where it is in the tree.
+type ListCommentsQuery struct {
+	Limit  int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Offset int    `form:"offset" binding:"omitempty,gte=0"`
+	Cursor string `form:"cursor"`
+
+	Sort  string `form:"sort" binding:"omitempty,oneof=created_at updated_at"`
+	Order string `form:"order" binding:"omitempty,oneof=asc desc"`
+}
+
+// DefaultCommentOrder is the default order of comments.
+const DefaultCommentOrder = "asc"
+
+// MaxCommentDepth is how deeply replies can be nested. A reply to a comment
+// at this depth is rejected.
+const MaxCommentDepth = 5
+
+// Normalize fills in the defaults for the fields that were not set.
+func (q *ListCommentsQuery) Normalize() {
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Sort == "" {
+		q.Sort = DefaultSort
+	}
+	if q.Order == "" {
+		q.Order = DefaultCommentOrder
+	}
+}
+
+// ListCommentsResponse represents a page of comments.
+//
+// The pagination counts top-level comments, so a page can contain more than
+// Limit comments when they have replies.
+type ListCommentsResponse struct {
+	Data       []Comment  `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// UpdateCommentRequest represents a request to update a comment.
+type UpdateCommentRequest struct {
+	Content string `json:"content" binding:"required"`
+}
@@ -0,0 +1,33 @@
+package forms
+
+// Problem represents an error response in the "problem details" format
+// described by RFC 7807. It is sent with the application/problem+json content
+// type.
+//
+// Type is a URI that identifies the kind of problem, Title is a short summary
+// of that kind, Status is the HTTP status code, Detail explains this
+// occurrence of the problem and Instance is the path of the request.
+//
+// RequestID is an extension member that matches the X-Request-ID header, so a
+// client can quote it when reporting a problem. Errors is an extension member
+// that lists the invalid fields of a request body.
+//
+// For more information on RFC 7807, see:
+// https://www.rfc-editor.org/rfc/rfc7807
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes a single invalid field of a request.
+//
+// Field is the JSON name of the field, for example "title".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,68 @@
+package forms
+
+import (
+	"time"
+
+	"blog/diff"
+)
+
+// Revision represents one version of a blog.
+//
+// Number counts the versions of a blog from 1, which is the version it was
+// created with. Editor is the user who made the change. RestoredFrom is the
+// number of the revision that this one restored, if any.
+type Revision struct {
+	Number       int       `json:"revision"`
+	BlogID       int       `json:"blog_id"`
+	Title        string    `json:"title"`
+	Content      string    `json:"content"`
+	Language     string    `json:"language"`
+	Editor       *Author   `json:"editor"`
+	RestoredFrom *int      `json:"restored_from"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ListRevisionsQuery represents the query string of a request to list the
+// revisions of a blog. Revisions are listed newest first.
+type ListRevisionsQuery struct {
+	Limit  int `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Offset int `form:"offset" binding:"omitempty,gte=0"`
+}
+
+// Normalize fills in the defaults for the fields that were not set.
+func (q *ListRevisionsQuery) Normalize() {
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+}
+
+// ListRevisionsResponse represents a page of revisions.
+type ListRevisionsResponse struct {
+	Data       []Revision `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// DiffQuery represents the query string of a request to compare two
+// revisions of a blog. For example:
+//
+//	GET /blogs/1/revisions/diff?from=2&to=5&mode=word
+//
+// Mode is line (the default) or word.
+type DiffQuery struct {
+	From int    `form:"from" binding:"required,gte=1"`
+	To   int    `form:"to" binding:"required,gte=1"`
+	Mode string `form:"mode" binding:"omitempty,oneof=line word"`
+}
+
+// DiffResponse represents the differences between two revisions of a blog.
+//
+// Title and Content are lists of operations. Joining the text of the equal
+// and insert operations gives the new version, and joining the equal and
+// delete operations gives the old one.
+type DiffResponse struct {
+	From    int       `json:"from"`
+	To      int       `json:"to"`
+	Mode    string    `json:"mode"`
+	Title   []diff.Op `json:"title"`
+	Content []diff.Op `json:"content"`
+}
@@ -0,0 +1,82 @@
+package forms
+
+import "time"
+
+// DefaultLanguage is the language of blogs that don't specify one.
+const DefaultLanguage = "english"
+
+// Languages are the languages supported by full-text search.
+//
+// Each one is the name of a text search configuration that ships with
+// Postgres. The configuration decides how words are stemmed, for example
+// "english" turns "running" into "run".
+//
+// For more information on text search configurations, see:
+// https://www.postgresql.org/docs/current/textsearch-configuration.html
+var Languages = map[string]bool{
+	"simple":     true, // No stemming, only lower-casing.
+	"danish":     true,
+	"dutch":      true,
+	"english":    true,
+	"finnish":    true,
+	"french":     true,
+	"german":     true,
+	"hungarian":  true,
+	"italian":    true,
+	"norwegian":  true,
+	"portuguese": true,
+	"romanian":   true,
+	"russian":    true,
+	"spanish":    true,
+	"swedish":    true,
+	"turkish":    true,
+}
+
+// SearchQuery represents the query string of a search request.
+//
+// Q is the search text. It supports the same syntax as web search engines:
+// "quoted phrases", OR, and -excluded words.
+//
+// Language is the language used to stem the words of Q. It defaults to
+// DefaultLanguage.
+//
+//	GET /search?q=gopher -rust&lang=english&limit=10
+type SearchQuery struct {
+	Q        string `form:"q" binding:"required,max=200"`
+	Language string `form:"lang" binding:"omitempty,language"`
+	Limit    int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Offset   int    `form:"offset" binding:"omitempty,gte=0"`
+}
+
+// Normalize fills in the defaults for the fields that were not set.
+func (q *SearchQuery) Normalize() {
+	if q.Language == "" {
+		q.Language = DefaultLanguage
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+}
+
+// SearchResult represents a blog that matches a search.
+//
+// TitleHighlight and Snippet are HTML: the text is escaped and the matching
+// words are wrapped in <mark> tags. The snippet comes from the blog content,
+// or from the best matching comment if only comments match.
+//
+// Rank is higher for better matches. Results are sorted by it.
+type SearchResult struct {
+	ID               int       `json:"id"`
+	Title            string    `json:"title"`
+	TitleHighlight   string    `json:"title_highlight"`
+	Snippet          string    `json:"snippet"`
+	Rank             float64   `json:"rank"`
+	MatchingComments int       `json:"matching_comments"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// SearchResponse represents a page of search results.
+type SearchResponse struct {
+	Data       []SearchResult `json:"data"`
+	Pagination Pagination     `json:"pagination"`
+}
@@ -0,0 +1,72 @@
+package forms
+
+// Tag represents a tag. Tags are free-form labels of blogs, and a blog can
+// have any number of them.
+//
+// Slug identifies the tag in URLs, for example in GET /blogs?tag=go. It is
+// made from the name, so names that only differ in case or punctuation, such
+// as "Go" and "go!", are the same tag.
+type Tag struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// TagRequest represents a request to create or rename a tag.
+type TagRequest struct {
+	Name string `json:"name" binding:"required,max=50"`
+}
+
+// ListTagsQuery represents the query string of a request to list the tags.
+// Tags are listed by name.
+type ListTagsQuery struct {
+	Limit  int `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Offset int `form:"offset" binding:"omitempty,gte=0"`
+}
+
+// Normalize fills in the defaults for the fields that were not set.
+func (q *ListTagsQuery) Normalize() {
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+}
+
+// ListTagsResponse represents a page of tags.
+type ListTagsResponse struct {
+	Data       []Tag      `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// TagCloudQuery represents the query string of a request for the tag cloud.
+// For example:
+//
+//	GET /tags/cloud?limit=20
+//
+// Limit is the number of tags to return, the most used first. It defaults
+// to DefaultTagCloudLimit.
+type TagCloudQuery struct {
+	Limit int `form:"limit" binding:"omitempty,gte=1,lte=200"`
+}
+
+// DefaultTagCloudLimit is the default number of tags in the tag cloud.
+const DefaultTagCloudLimit = 50
+
+// Normalize fills in the defaults for the fields that were not set.
+func (q *TagCloudQuery) Normalize() {
+	if q.Limit == 0 {
+		q.Limit = DefaultTagCloudLimit
+	}
+}
+
+// TagCount is a tag in the tag cloud, with the number of published blogs
+// that have it.
+type TagCount struct {
+	Tag
+	Count int `json:"count"`
+}
+
+// TagCloudResponse represents the tag cloud: the tags that published blogs
+// have, the most used first. Tags with the same count are sorted by name.
+type TagCloudResponse struct {
+	Data []TagCount `json:"data"`
+}
@@ -0,0 +1,55 @@
+package forms
+
+import "time"
+
+// TrashQuery represents the query string of a request to list the blogs or
+// comments in the trash. They are listed most recently deleted first.
+//
+// ViewerID and ViewAll are set by the controller, not by the client. Users
+// only see what they deleted themselves, unless ViewAll is true.
+type TrashQuery struct {
+	Limit  int `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Offset int `form:"offset" binding:"omitempty,gte=0"`
+
+	ViewerID int  `form:"-"`
+	ViewAll  bool `form:"-"`
+}
+
+// Normalize fills in the defaults for the fields that were not set.
+func (q *TrashQuery) Normalize() {
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+}
+
+// TrashedBlog represents a blog in the trash.
+type TrashedBlog struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	Author    *Author   `json:"author"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// TrashedComment represents a comment in the trash. Its replies are hidden
+// until it is restored.
+type TrashedComment struct {
+	ID        int       `json:"id"`
+	BlogID    int       `json:"blog_id"`
+	ParentID  *int      `json:"parent_id"`
+	Content   string    `json:"content"`
+	Author    *Author   `json:"author"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// ListTrashedBlogsResponse represents a page of blogs in the trash.
+type ListTrashedBlogsResponse struct {
+	Data       []TrashedBlog `json:"data"`
+	Pagination Pagination    `json:"pagination"`
+}
+
+// ListTrashedCommentsResponse represents a page of comments in the trash.
+type ListTrashedCommentsResponse struct {
+	Data       []TrashedComment `json:"data"`
+	Pagination Pagination       `json:"pagination"`
+}
@@ -0,0 +1,68 @@
+package forms
+
+import "time"
+
+// RegisterRequest represents a request to create a user account.
+//
+// The password must be at least 8 characters long. The maximum keeps the
+// password hashing time bounded.
+type RegisterRequest struct {
+	Email    string `json:"email" binding:"required,email,max=254"`
+	Name     string `json:"name" binding:"required,max=100"`
+	Password string `json:"password" binding:"required,min=8,max=128"`
+}
+
+// LoginRequest represents a request to log in.
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// User represents a user account.
+//
+// It never contains the password hash, so it is safe to send to clients.
+type User struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Author represents the author of a blog or comment.
+//
+// It only contains the public information of the user, so it doesn't expose
+// the email address.
+type Author struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// SetRoleRequest represents a request to change the role of a user.
+// The roles are declared in blog/policy/policy.go.
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin editor author commenter"`
+}
+
+// RefreshRequest represents a request to refresh or revoke a refresh token.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenResponse represents the tokens returned by a login or a refresh.
+//
+// The access token is sent in the Authorization header of later requests:
+//
+//	Authorization: Bearer <access_token>
+//
+// When it expires (after expires_in seconds), the refresh token can be
+// exchanged for a new pair of tokens. Every refresh token can only be used
+// once.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	User         User   `json:"user"`
+}
@@ -10,74 +10,343 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"blog/auth"
 	"blog/config"
 	"blog/controllers"
 	"blog/database"
+	"blog/migrations"
 	"blog/models"
+	"blog/scheduler"
 	"blog/server"
+	"blog/web"
+
+	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// exitCode is the exit status of the program. os.Exit skips deferred
+	// calls, so it is called by the first deferred function, which runs
+	// last, after the others have cleaned up.
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	// Load config
-	// The Load function is defined in blog/config/config.go.
-	// It returns a pointer to a Config.
+	// The Load function is defined in blog/config/load.go.
+	// It reads the config file, the environment variables and the flags, and
+	// returns a pointer to a Config and the arguments after the flags, such
+	// as "migrate up".
 	// For more information on the Config struct, see:
 	// blog/config/config.go
-	cfg := config.Load()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	// Run the config subcommand if it was requested. It reports the problems
+	// of the config itself, so it runs even if the config is invalid.
+	// For example, "go run . --profile prod config check".
+	// The runConfig function is defined in blog/config.go.
+	if command == "config" {
+		flags := os.Args[1 : len(os.Args)-len(args)]
+		if err := runConfig(cfg, err, flags, args[1:]); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	if command != "" && command != "migrate" && command != "users" {
+		log.Fatalf("unknown command %q (expected config, migrate or users)", command)
+	}
+
+	// Remove the secrets of the config from the logs, in case one of them
+	// ends up in an error message. gin writes its request log and its errors
+	// to writers of its own, which are replaced as well.
+	// The RedactingWriter function is defined in blog/config/secrets.go.
+	redact := config.RedactingWriter(os.Stderr, cfg.Secrets())
+	log.SetOutput(redact)
+	gin.DefaultWriter = config.RedactingWriter(os.Stdout, cfg.Secrets())
+	gin.DefaultErrorWriter = redact
+	for _, key := range cfg.ShortSecrets() {
+		log.Printf("warning: %s is shorter than %d characters, so it is not redacted from the logs", key, config.MinRedactedLength)
+	}
 
 	// Get config values
 	port := cfg.ServerPort()
 	dburl := cfg.LoadDBUrl()
 
-	// Init database
-	// The NewDatabase function is defined in blog/database/database.go.
-	// It returns a pointer to a Database.
-	// For more information on the Database struct, see:
-	// blog/database/database.go
-	db := database.NewDatabase()
+	// Init models
+	// BlogStore is an interface defined in blog/models/store.go, and UserStore
+	// is defined in blog/models/user.go.
+	// The driver in the config decides which implementations we use.
+	var blogModel models.BlogStore
+	var userModel models.UserStore
+	var tokenModel models.TokenStore
+
+	if cfg.Database.Driver == "memory" {
+		// The memory driver keeps everything in memory, so there is no
+		// database to connect to or to migrate.
+		// The NewMemoryBlogModel function is defined in blog/models/memory.go.
+		if command == "migrate" || command == "users" {
+			log.Fatalf("the %s command needs the postgres driver", command)
+		}
+		memoryUsers := models.NewMemoryUserModel()
+		blogModel = models.NewMemoryBlogModel(memoryUsers)
+		userModel = memoryUsers
+		tokenModel = models.NewMemoryTokenModel()
+	} else {
+		// Init database
+		// The NewDatabase function is defined in blog/database/database.go.
+		// It returns a pointer to a Database.
+		// For more information on the Database struct, see:
+		// blog/database/database.go
+		db := database.NewDatabase()
+
+		// The InitDB function is defined in blog/database/database.go.
+		// It takes a database URL as an argument.
+		// It returns an error if the database fails to initialize.
+		// If the database fails to initialize, we log the error and exit the program.
+		// The URL in the log has no password; see blog/config/config.go.
+		log.Printf("using database %s", cfg.RedactedDBUrl())
+		if err := db.InitDB(dburl); err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer func() {
+			// Close database connection
+			if err := db.CloseDB(); err != nil {
+				log.Printf("failed to close database: %v", err)
+			}
+		}()
+
+		// Run the migrate subcommand if it was requested.
+		// For example, "go run . migrate status".
+		// The runMigrate function is defined in blog/migrate.go.
+		if command == "migrate" {
+			if err := runMigrate(db.GetDB(), args[1:]); err != nil {
+				log.Fatalf("failed to migrate: %v", err)
+			}
+			return
+		}
+
+		// Apply pending migrations on startup, unless it is disabled in the config.
+		// The Migrator is defined in blog/migrations/migrations.go.
+		if cfg.Database.AutoMigrate {
+			migrator, err := migrations.NewMigrator(db.GetDB())
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			if _, err := migrator.Up(context.Background()); err != nil {
+				log.Fatalf("failed to migrate: %v", err)
+			}
+		}
+
+		// The NewBlogModel function is defined in blog/models/blog.go.
+		// It takes a pointer to a sql.DB as an argument.
+		// It returns a pointer to a BlogModel.
+		// For more information on the BlogModel struct, see:
+		// blog/models/blog.go
+		blogModel = models.NewBlogModel(db.GetDB())
+		userModel = models.NewUserModel(db.GetDB())
+		tokenModel = models.NewTokenModel(db.GetDB())
 
-	// The InitDB function is defined in blog/database/database.go.
-	// It takes a database URL as an argument.
-	// It returns an error if the database fails to initialize.
-	// If the database fails to initialize, we log the error and exit the program.
-	if err := db.InitDB(dburl); err != nil {
-		log.Fatalf("%v", err)
+		// Run the users subcommand if it was requested.
+		// For example, "go run . users set-role ann@example.com admin".
+		// The runUsers function is defined in blog/users.go.
+		if command == "users" {
+			if err := runUsers(userModel, args[1:]); err != nil {
+				log.Fatalf("failed to run users command: %v", err)
+			}
+			return
+		}
 	}
-	defer db.CloseDB() // Close database connection
 
-	// Init models
-	// The NewBlogModel function is defined in blog/models/blog.go.
-	// It takes a pointer to a Database as an argument.
-	// It returns a pointer to a BlogModel.
-	// For more information on the BlogModel struct, see:
-	// blog/models/blog.go
-	blogModel := models.NewBlogModel(db.GetDB())
+	// Bound the memory that password hashing takes. The SetMaxConcurrentHashes
+	// function is defined in blog/auth/password.go.
+	auth.SetMaxConcurrentHashes(cfg.Auth.MaxConcurrentHashes)
+
+	// Init the token manager, which issues and verifies access tokens.
+	// The NewTokenManager function is defined in blog/auth/token.go.
+	// It returns an error if the keys in the config are missing or invalid.
+	tokens, err := auth.NewTokenManager(auth.TokenConfig{
+		SigningMethod:     cfg.Auth.SigningMethod,
+		HMACSecret:        cfg.Auth.HMACSecret,
+		Ed25519PrivateKey: cfg.Auth.Ed25519PrivateKey,
+		Issuer:            cfg.Auth.Issuer,
+		AccessTokenTTL:    cfg.Auth.AccessTokenTTL,
+		RefreshTokenTTL:   cfg.Auth.RefreshTokenTTL,
+	})
+	if err != nil {
+		log.Fatalf("failed to init tokens: %v", err)
+	}
 
 	// Init controllers
 	// The NewBlogController function is defined in blog/controllers/blog.go.
-	// It takes a pointer to a BlogModel as an argument.
+	// It takes a BlogStore as an argument.
 	// It returns a pointer to a BlogController.
 	// For more information on the BlogController struct, see:
 	// blog/controllers/blog.go
 	blogController := controllers.NewBlogController(blogModel)
 
+	// The NewUserController function is defined in blog/controllers/user.go.
+	userController := controllers.NewUserController(userModel, tokenModel, tokens)
+
+	// The NewSite function is defined in blog/web/site.go.
+	// It parses the templates of the public site, so it fails at startup
+	// rather than on the first request if one of them is broken. The feeds
+	// link to the public URL in the config, and its cookies are Secure if
+	// the blog is served over HTTPS.
+	site, err := web.NewSite(blogModel, userModel, tokens, tokenModel, cfg.BaseURL(), cfg.SecureCookies())
+	if err != nil {
+		log.Fatalf("failed to init site: %v", err)
+	}
+
 	// Init router
 	// Create a new router.
 	// The NewRouter function is defined in blog/server/router.go.
-	// It takes a pointer to a BlogController as an argument.
+	// It takes pointers to the controllers and the site as arguments.
 	// It returns a pointer to a gin.Engine.
-	router := server.NewRouter(blogController)
+	router := server.NewRouter(blogController, userController, site)
 
 	// Create a new server.
 	// The NewServer function is defined in blog/server/server.go.
-	// It takes a pointer to a gin.Engine and a port string as arguments.
+	// It takes a pointer to a gin.Engine and the server configuration as
+	// arguments.
 	// It returns a pointer to a Server.
 	// For more information on the Server struct, see:
 	// blog/server/server.go
-	srv := server.NewServer(router, port)
+	srv := server.NewServer(router, server.Config{
+		Addr:              port,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		TLS: server.TLSConfig{
+			CertFile:       cfg.Server.TLS.CertFile,
+			KeyFile:        cfg.Server.TLS.KeyFile,
+			ReloadInterval: cfg.Server.TLS.ReloadInterval,
+			RedirectAddr:   cfg.RedirectPort(),
+		},
+	})
+
+	// Open the port before starting anything else, so that a port that is
+	// already in use stops the program right away.
+	if err := srv.Listen(); err != nil {
+		log.Fatalf("failed to start server: %v", err)
+	}
+
+	// Start the scheduler, which publishes scheduled blogs when they are due.
+	// The NewScheduler function is defined in blog/scheduler/scheduler.go.
+	//
+	// The background jobs don't get the context of the signals below: a
+	// signal would cancel the batch they are working on while the server
+	// is still finishing its requests. They are stopped with Stop instead,
+	// after the server, and finish their batch first.
+	var sched *scheduler.Scheduler
+	if cfg.Scheduler.Interval > 0 {
+		sched = scheduler.NewScheduler(blogModel, scheduler.SystemClock{}, cfg.Scheduler.Interval, cfg.Scheduler.BatchSize)
+		if err := sched.Start(context.Background()); err != nil {
+			log.Fatalf("failed to start scheduler: %v", err)
+		}
+	}
+
+	// Start the purger, which removes blogs and comments that have been in
+	// the trash for longer than the retention period, and the refresh tokens
+	// that have expired. The NewPurger function is defined in
+	// blog/scheduler/purger.go.
+	var purger *scheduler.Purger
+	if cfg.Trash.PurgeInterval > 0 {
+		purger = scheduler.NewPurger(blogModel, tokenModel, scheduler.SystemClock{}, cfg.Trash.PurgeInterval, cfg.Trash.Retention, cfg.Trash.BatchSize)
+		if err := purger.Start(context.Background()); err != nil {
+			log.Fatalf("failed to start purger: %v", err)
+		}
+	}
+
+	// Start the renderer, which caches the HTML of the blogs that were
+	// rendered by an older version of the markdown package. It runs once.
+	// The NewRenderer function is defined in blog/scheduler/renderer.go.
+	renderer := scheduler.NewRenderer(blogModel, cfg.Scheduler.BatchSize)
+	if err := renderer.Start(context.Background()); err != nil {
+		log.Fatalf("failed to start renderer: %v", err)
+	}
+
+	// ctx is cancelled when the program receives SIGINT (Ctrl+C) or SIGTERM,
+	// which is how it is asked to stop.
+	//
+	// For more information on signal.NotifyContext, see:
+	// https://golang.org/pkg/os/signal/#NotifyContext
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Start server
-	srv.Run()
+	// srv.Serve blocks, so it runs in its own goroutine while we wait for a
+	// signal. If it stops by itself, which only happens when something is
+	// badly wrong, we shut down as if we had received a signal.
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve()
+	}()
+	log.Printf("listening on %s", srv.URL())
+
+	failed := false
+	select {
+	case <-ctx.Done():
+		log.Println("shutting down")
+	case err := <-serveErr:
+		log.Printf("server stopped: %v", err)
+		failed = true
+	}
+
+	// Restore the default behaviour of the signals, so that a second Ctrl+C
+	// stops the program at once instead of waiting for the shutdown.
+	stop()
+
+	// Shut down in order: first the server, so that no new work comes in
+	// and the requests in flight can finish; then the scheduler, the purger
+	// and the renderer, which finish the batch they are working on; and
+	// last the database pool, which the deferred CloseDB above closes when
+	// main returns, once nothing uses it any more.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to shut down server gracefully: %v", err)
+	}
+
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), cfg.Scheduler.StopTimeout)
+	defer cancelStop()
+	if sched != nil {
+		if err := sched.Stop(stopCtx); err != nil {
+			log.Printf("failed to stop scheduler: %v", err)
+		}
+	}
+	if purger != nil {
+		if err := purger.Stop(stopCtx); err != nil {
+			log.Printf("failed to stop purger: %v", err)
+		}
+	}
+	if err := renderer.Stop(stopCtx); err != nil {
+		log.Printf("failed to stop renderer: %v", err)
+	}
+
+	if failed {
+		exitCode = 1
+	}
 }
@@ -0,0 +1,65 @@
+// Package markdown renders the content of blogs, which is written in
+// Markdown, to HTML that is safe to show in a browser.
+//
+// It understands CommonMark with the GitHub Flavored Markdown extensions:
+// tables, strikethrough, autolinks and task lists. Authors may also write
+// raw HTML, which is why every rendered page goes through an allowlist
+// sanitizer: only known-safe elements and attributes are kept, so a blog
+// can't run scripts in the browsers of its readers (stored XSS).
+//
+// For more information on CommonMark and GFM, see:
+// https://spec.commonmark.org and https://github.github.com/gfm/
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// Version identifies the way Render renders Markdown. It must be increased
+// whenever the renderer or the sanitizer policy changes, so that HTML
+// cached with an older version is rendered again.
+const Version = 1
+
+// renderer converts Markdown to HTML. WithUnsafe keeps the raw HTML in the
+// source instead of dropping it; the sanitizer removes anything unsafe.
+var renderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+)
+
+// policy is the sanitizer allowlist. It is bluemonday's policy for user
+// generated content, which allows formatting, links, images and tables but
+// no scripts, styles, forms or event handlers, and which adds
+// rel="nofollow" to links. On top of that it allows what the Markdown
+// renderer itself produces: the language class of code blocks and the
+// disabled checkboxes of task lists.
+//
+// For more information on bluemonday policies, see:
+// https://github.com/microcosm-cc/bluemonday
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}()
+
+// Render returns the HTML of a Markdown source, sanitized.
+func Render(source string) string {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		// Writing to a bytes.Buffer never fails, so this doesn't happen
+		// in practice. Showing the source as text is still better than
+		// showing nothing.
+		return "<pre>" + html.EscapeString(source) + "</pre>"
+	}
+
+	return policy.Sanitize(buf.String())
+}
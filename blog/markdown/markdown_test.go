@@ -0,0 +1,88 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"paragraph", "Hello, *world*!", "<p>Hello, <em>world</em>!</p>\n"},
+		{"link", "[Go](https://go.dev)", `<p><a href="https://go.dev" rel="nofollow">Go</a></p>` + "\n"},
+		{"code block", "```go\nfmt.Println()\n```", `<pre><code class="language-go">fmt.Println()` + "\n</code></pre>\n"},
+		{"code block with symbols", "```c++\nx\n```", `<pre><code class="language-c++">x` + "\n</code></pre>\n"},
+		{"task list", "- [x] done\n- [ ] todo", "<ul>\n" +
+			`<li><input checked="" disabled="" type="checkbox"> done</li>` + "\n" +
+			`<li><input disabled="" type="checkbox"> todo</li>` + "\n</ul>\n"},
+		{"table", "| a |\n|---|\n| b |", "<table>\n<thead>\n<tr>\n<th>a</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>b</td>\n</tr>\n</tbody>\n</table>\n"},
+		{"safe raw html", "<em>hi</em>", "<p><em>hi</em></p>\n"},
+		{"escaped text", "1 < 2 & 3 > 2", "<p>1 &lt; 2 &amp; 3 &gt; 2</p>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.source); got != tt.want {
+				t.Errorf("Render(%q) =\n%q\nwant\n%q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRenderSanitizes checks that what could run scripts in the browsers of
+// readers is removed, whether it is written as raw HTML or as Markdown.
+func TestRenderSanitizes(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		// banned must not appear in the HTML, in any case.
+		banned []string
+	}{
+		{"script", "<script>alert(1)</script>", []string{"<script", "alert"}},
+		{"script in paragraph", "Hi <script>alert(1)</script> there", []string{"<script", "alert"}},
+		{"onerror", `<img src="x.png" onerror="alert(1)">`, []string{"onerror", "alert"}},
+		{"onclick", `<a href="https://go.dev" onclick="alert(1)">Go</a>`, []string{"onclick", "alert"}},
+		{"javascript link", `[x](javascript:alert(1))`, []string{"javascript:", "alert"}},
+		{"javascript raw link", `<a href="javascript:alert(1)">x</a>`, []string{"javascript:", "alert"}},
+		{"javascript link in mixed case", `<a href="JaVaScRiPt:alert(1)">x</a>`, []string{"javascript:", "alert"}},
+		{"data link", `[x](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)`, []string{"data:"}},
+		{"data raw link", `<a href="data:text/html,<script>alert(1)</script>">x</a>`, []string{"data:", "<script"}},
+		{"data image", `![x](data:image/svg+xml;base64,PHN2Zz48L3N2Zz4=)`, []string{"data:"}},
+		{"iframe", `<iframe src="https://example.com"></iframe>`, []string{"<iframe"}},
+		{"style", `<style>body { display: none }</style><p style="color: red">x</p>`, []string{"<style", "style="}},
+		{"form", `<form action="https://example.com"><button>Go</button></form>`, []string{"<form", "<button"}},
+		{"text input", `<input type="text" name="password">`, []string{"<input", "password"}},
+		{"input without type", `<input name="password">`, []string{"<input", "password"}},
+		{"checkbox with handler", `<input type="checkbox" onchange="alert(1)">`, []string{"onchange", "alert"}},
+		{"code class", `<code class="language-go evil">x</code>`, []string{"evil", "class="}},
+		{"other class", `<p class="admin">x</p>`, []string{"class="}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.ToLower(Render(tt.source))
+			for _, banned := range tt.banned {
+				if strings.Contains(got, strings.ToLower(banned)) {
+					t.Errorf("Render(%q) = %q, which contains %q", tt.source, got, banned)
+				}
+			}
+		})
+	}
+}
+
+// TestRenderCheckboxes checks that the only inputs that survive are
+// checkboxes, which task lists are made of.
+func TestRenderCheckboxes(t *testing.T) {
+	for _, typ := range []string{"text", "password", "submit", "hidden", "image", "file", "radio", "CHECKBOX ", "checkbox2"} {
+		source := `<input type="` + typ + `">`
+		if got := Render(source); strings.Contains(got, "<input") {
+			t.Errorf("Render(%q) = %q, want no input", source, got)
+		}
+	}
+
+	source := `<input type="checkbox" checked disabled>`
+	if got := Render(source); !strings.Contains(got, `<input type="checkbox" checked="" disabled="">`) {
+		t.Errorf("Render(%q) = %q, want the checkbox", source, got)
+	}
+}
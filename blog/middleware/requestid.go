@@ -0,0 +1,62 @@
+// Package middleware contains the gin middlewares used by the blog service.
+//
+// A middleware is a handler that runs before (and after) the route handlers.
+// It can inspect or modify the request, or stop it early.
+//
+// For more information on gin middlewares, see:
+// https://gin-gonic.com/docs/examples/custom-middleware/
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader is the header that carries the request-correlation ID.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the key under which the request ID is stored in the context.
+const requestIDKey = "request_id"
+
+// validRequestID limits the IDs we accept from clients, so that a client
+// can't put arbitrary text into our logs and responses.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID returns a middleware that gives every request a correlation ID.
+//
+// If the client (or a proxy in front of us) sends an X-Request-ID header, its
+// value is reused. Otherwise a new random ID is generated. The ID is stored in
+// the gin context and sent back in the X-Request-ID response header.
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		ctx.Set(requestIDKey, id)
+		ctx.Header(RequestIDHeader, id)
+
+		// ctx.Next runs the remaining handlers.
+		ctx.Next()
+	}
+}
+
+// GetRequestID returns the request ID stored by the RequestID middleware,
+// or an empty string if there is none.
+func GetRequestID(ctx *gin.Context) string {
+	return ctx.GetString(requestIDKey)
+}
+
+// newRequestID returns a random 128-bit ID encoded as hex.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand only fails if the operating system is broken.
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,88 @@
+// This file contains the migrate subcommand.
+//
+// Usage:
+//
+//	blog migrate up        Apply all pending migrations
+//	blog migrate down [n]  Roll back the last n migrations (default 1)
+//	blog migrate status    Show which migrations have been applied
+//	blog migrate baseline  Adopt tables created before migrations
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"blog/migrations"
+)
+
+// runMigrate runs the migrate subcommand with the given arguments.
+// It returns an error if the arguments are invalid or the migration fails.
+func runMigrate(db *sql.DB, args []string) error {
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	// Default to "up" so that "blog migrate" does the obvious thing.
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+
+		n, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %d migration(s)\n", n)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d %-40s %s\n", s.Version, s.Name, applied)
+		}
+
+	case "baseline":
+		baselined, err := migrator.Baseline(ctx)
+		if err != nil {
+			return err
+		}
+		if baselined {
+			fmt.Println("baselined the existing tables as migration 0001")
+		} else {
+			fmt.Println("migrations are already recorded; nothing to baseline")
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (expected up, down, status or baseline)", command)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrNeedsBaseline is returned by Up when the database already has the blog
+// tables, created before the service had migrations, but no migration has
+// been recorded. Running 0001 on it would fail, so it must be baselined
+// first with Baseline.
+var ErrNeedsBaseline = errors.New(`the database has tables from before migrations; run "blog migrate baseline" first`)
+
+// baselineVersion is the migration that created the tables of the old setup.
+const baselineVersion = 1
+
+// baselineScript brings the tables of the old setup, which had the same
+// columns, up to the schema of migration 0001: the index, the function and
+// the triggers that keep updated_at in sync. Every statement can be run more
+// than once.
+const baselineScript = `
+CREATE TABLE IF NOT EXISTS blogs (
+    id         SERIAL PRIMARY KEY,
+    title      TEXT        NOT NULL,
+    content    TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id         SERIAL PRIMARY KEY,
+    blog_id    INTEGER     NOT NULL REFERENCES blogs (id) ON DELETE CASCADE,
+    content    TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS comments_blog_id_idx ON comments (blog_id);
+
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS blogs_set_updated_at ON blogs;
+CREATE TRIGGER blogs_set_updated_at
+    BEFORE UPDATE ON blogs
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER IF EXISTS comments_set_updated_at ON comments;
+CREATE TRIGGER comments_set_updated_at
+    BEFORE UPDATE ON comments
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+`
+
+// Baseline adopts a database that was set up before the service had
+// migrations. It completes the old tables to the schema of migration 0001
+// and records 0001 as applied, so that Up continues with 0002.
+//
+// It returns false if there was nothing to do, because migrations have
+// already been recorded.
+func (m *Migrator) Baseline(ctx context.Context) (bool, error) {
+	baselined := false
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(done) > 0 {
+			return nil
+		}
+
+		var migration Migration
+		for _, mg := range m.migrations {
+			if mg.Version == baselineVersion {
+				migration = mg
+			}
+		}
+		if migration.Version == 0 {
+			return fmt.Errorf("migration %d is missing", baselineVersion)
+		}
+
+		if err := run(ctx, conn, migration, baselineScript, `
+			INSERT INTO schema_migrations (version, name, checksum)
+			VALUES ($1, $2, $3)
+		`, migration.Version, migration.Name, migration.Checksum); err != nil {
+			return err
+		}
+		baselined = true
+
+		return nil
+	})
+
+	return baselined, err
+}
+
+// hasOldTables reports whether the blogs table exists although no migration
+// has been recorded, which means the database needs to be baselined.
+func hasOldTables(ctx context.Context, conn *sql.Conn, done map[int]applied) (bool, error) {
+	if len(done) > 0 {
+		return false, nil
+	}
+
+	var exists bool
+	if err := conn.QueryRowContext(ctx, `SELECT to_regclass('blogs') IS NOT NULL`).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check for existing tables: %w", err)
+	}
+	return exists, nil
+}
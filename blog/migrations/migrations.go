@@ -0,0 +1,386 @@
+// Package migrations provides versioned schema migrations for the blog service.
+//
+// This file contains the Migrator struct and the Up, Down and Status methods.
+//
+// The SQL files in the sql directory are compiled into the binary with the
+// embed package, so the service never depends on files being present on disk.
+// Every file is named <version>_<name>.<up|down>.sql, for example
+// "0001_create_blogs_and_comments.up.sql".
+//
+// Applied migrations are recorded in the schema_migrations table together with
+// a checksum of their up script. If an applied script is edited afterwards, the
+// checksums no longer match and the Migrator refuses to run, unless the old
+// checksum is listed in superseded.
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// files holds the embedded SQL files.
+//
+// For more information on the embed package, see:
+// https://pkg.go.dev/embed
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockID is the key of the Postgres advisory lock taken while migrating.
+// It makes sure that only one instance of the service migrates at a time.
+//
+// For more information on advisory locks, see:
+// https://www.postgresql.org/docs/current/explicit-locking.html#ADVISORY-LOCKS
+const lockID = 72_616_001
+
+// fileName matches the name of a migration file.
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// ErrChecksumMismatch is returned when an applied migration has been modified.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// superseded holds the checksums of up scripts that were edited after they
+// had been released, by version. An applied migration may have one of them
+// instead of the checksum of its current file.
+//
+// Only scripts whose edits make no difference to a database that already
+// ran them belong here, such as a fix to a backfill of existing rows. Any
+// other change needs a new migration.
+var superseded = map[int][]string{
+	// The backfill of comments.language no longer bumps updated_at.
+	2: {"e5fb50d6a4d5f47297c34fc1299f28b8c1610c5738cf944dee8caa7254d126dd"},
+	// The backfill of blogs.status and published_at no longer bumps
+	// updated_at.
+	8: {"7ade90bd5f3e7b850141a707c78db360a1c28f9e159453fcc356cd310582df9c"},
+	// The backfill of blogs.slug no longer bumps updated_at, and no longer
+	// gives a blog a suffixed slug that another blog has.
+	12: {"b3a0b6fa024e450c20eb8a8cdd425686294bc612f3e1053a7ed661f5f00f3b3b"},
+}
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status describes whether a migration has been applied.
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the embedded migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator creates a new Migrator.
+// It takes a pointer to a sql.DB as an argument.
+// It returns an error if the embedded migration files are invalid.
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// load reads the migration files from fsys and returns them sorted by version.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// applied is a row of the schema_migrations table.
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up applies all pending migrations, in order, each in its own transaction.
+// It returns the number of migrations that were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// Tables from before migrations would make 0001 fail halfway
+		// through with a less helpful error.
+		old, err := hasOldTables(ctx, conn, done)
+		if err != nil {
+			return err
+		}
+		if old {
+			return ErrNeedsBaseline
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			if err := run(ctx, conn, migration, migration.Up, `
+				INSERT INTO schema_migrations (version, name, checksum)
+				VALUES ($1, $2, $3)
+			`, migration.Version, migration.Name, migration.Checksum); err != nil {
+				return err
+			}
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Down rolls back the given number of most recently applied migrations.
+// It returns the number of migrations that were rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+			}
+
+			if err := run(ctx, conn, migration, migration.Down, `
+				DELETE FROM schema_migrations
+				WHERE version = $1
+			`, migration.Version); err != nil {
+				return err
+			}
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Status returns the status of every known migration.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if a, ok := done[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = a.appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// withLock runs fn on a single connection while holding the migration lock.
+//
+// Advisory locks belong to a session, so the lock, the work and the unlock must
+// all happen on the same connection rather than on the pool.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	return fn(conn)
+}
+
+// verify creates the schema_migrations table if needed and returns the
+// applied migrations. It returns an error if an applied migration is unknown
+// or its checksum does not match the embedded file.
+func (m *Migrator) verify(ctx context.Context, conn *sql.Conn) (map[int]applied, error) {
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER     PRIMARY KEY,
+			name       TEXT        NOT NULL,
+			checksum   TEXT        NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT version, checksum, applied_at
+		FROM schema_migrations
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := map[int]applied{}
+	for rows.Next() {
+		var version int
+		var a applied
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan migration: %w", err)
+		}
+		done[version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	if err := checkApplied(m.migrations, done); err != nil {
+		return nil, err
+	}
+
+	return done, nil
+}
+
+// checkApplied returns an error if one of the applied migrations is unknown
+// or its checksum does not match the one of its embedded file.
+func checkApplied(migrations []Migration, done map[int]applied) error {
+	known := map[int]Migration{}
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+	}
+
+	// The versions are checked in order, so that the error is always about
+	// the first migration that is wrong.
+	versions := make([]int, 0, len(done))
+	for version := range done {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	for _, version := range versions {
+		migration, ok := known[version]
+		if !ok {
+			return fmt.Errorf("migration %d is applied but unknown to this binary", version)
+		}
+		if !checksumMatches(migration, done[version].checksum) {
+			return fmt.Errorf("migration %d_%s: %w", version, migration.Name, ErrChecksumMismatch)
+		}
+	}
+
+	return nil
+}
+
+// checksumMatches reports whether checksum is the checksum of the up script
+// of migration, or of an earlier version of it in superseded.
+func checksumMatches(migration Migration, checksum string) bool {
+	if checksum == migration.Checksum {
+		return true
+	}
+	for _, old := range superseded[migration.Version] {
+		if checksum == old {
+			return true
+		}
+	}
+	return false
+}
+
+// run executes script and then the bookkeeping query in a single transaction,
+// so that a failing migration leaves neither a half-applied schema nor a
+// schema_migrations row behind.
+func run(ctx context.Context, conn *sql.Conn, migration Migration, script, bookkeeping string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback is a no-op once the transaction is committed.
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("failed to run migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return tx.Commit()
+}
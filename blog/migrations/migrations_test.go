@@ -0,0 +1,195 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// sqlFiles returns a file system with the given SQL files in the sql
+// directory.
+func sqlFiles(names ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, name := range names {
+		fsys["sql/"+name] = &fstest.MapFile{Data: []byte("-- " + name + "\n")}
+	}
+	return fsys
+}
+
+func checksum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLoad(t *testing.T) {
+	// fs.ReadDir sorts by name, so the versions are given out of order to
+	// check that load sorts them by number: 10 comes after 9.
+	fsys := sqlFiles(
+		"10_add_tags.up.sql",
+		"10_add_tags.down.sql",
+		"1_create_blogs.up.sql",
+		"1_create_blogs.down.sql",
+		"9_add_index.up.sql",
+	)
+
+	migrations, err := load(fsys)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	want := []struct {
+		version int
+		name    string
+		down    bool
+	}{
+		{1, "create_blogs", true},
+		{9, "add_index", false},
+		{10, "add_tags", true},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("load returned %d migrations, want %d", len(migrations), len(want))
+	}
+	for i, w := range want {
+		m := migrations[i]
+		if m.Version != w.version || m.Name != w.name {
+			t.Errorf("migration %d is %d_%s, want %d_%s", i, m.Version, m.Name, w.version, w.name)
+		}
+		if up := fmt.Sprintf("-- %d_%s.up.sql\n", w.version, w.name); m.Up != up {
+			t.Errorf("migration %d_%s has up script %q, want %q", m.Version, m.Name, m.Up, up)
+		}
+		if m.Checksum != checksum(m.Up) {
+			t.Errorf("migration %d_%s has checksum %s, want the checksum of its up script", m.Version, m.Name, m.Checksum)
+		}
+		if (m.Down != "") != w.down {
+			t.Errorf("migration %d_%s has down script %q", m.Version, m.Name, m.Down)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"bad name", []string{"1_Create.up.sql"}, "invalid migration file name"},
+		{"no version", []string{"create_blogs.up.sql"}, "invalid migration file name"},
+		{"not sql", []string{"1_create_blogs.up.txt"}, "invalid migration file name"},
+		{"no direction", []string{"1_create_blogs.sql"}, "invalid migration file name"},
+		{"conflicting names", []string{"1_create_blogs.up.sql", "1_create_posts.down.sql"}, "conflicting names"},
+		{"no up script", []string{"1_create_blogs.down.sql"}, "has no up script"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(sqlFiles(tt.files...))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("load(%q) = %v, want an error containing %q", tt.files, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestEmbedded checks the migrations that are compiled into the binary:
+// they must load, be numbered without gaps from 1, and all have a down
+// script.
+func TestEmbedded(t *testing.T) {
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d_%s should be version %d", m.Version, m.Name, i+1)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %d_%s has no down script", m.Version, m.Name)
+		}
+	}
+}
+
+func TestCheckApplied(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "create_blogs", Checksum: "aaa"},
+		{Version: 2, Name: "add_index", Checksum: "bbb"},
+	}
+
+	tests := []struct {
+		name     string
+		done     map[int]applied
+		mismatch bool
+		want     string
+	}{
+		{"none", map[int]applied{}, false, ""},
+		{"some", map[int]applied{1: {checksum: "aaa"}}, false, ""},
+		{"all", map[int]applied{1: {checksum: "aaa"}, 2: {checksum: "bbb"}}, false, ""},
+		{"edited", map[int]applied{1: {checksum: "aaa"}, 2: {checksum: "ccc"}}, true, "migration 2_add_index: checksum mismatch"},
+		{"first edited", map[int]applied{1: {checksum: "ccc"}, 2: {checksum: "ccc"}}, true, "migration 1_create_blogs: checksum mismatch"},
+		{"unknown", map[int]applied{1: {checksum: "aaa"}, 3: {checksum: "ddd"}}, false, "migration 3 is applied but unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkApplied(migrations, tt.done)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("checkApplied: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("checkApplied = %v, want an error containing %q", err, tt.want)
+			}
+			if errors.Is(err, ErrChecksumMismatch) != tt.mismatch {
+				t.Errorf("errors.Is(%v, ErrChecksumMismatch) = %t, want %t", err, !tt.mismatch, tt.mismatch)
+			}
+		})
+	}
+}
+
+func TestCheckAppliedSuperseded(t *testing.T) {
+	old := superseded
+	superseded = map[int][]string{2: {"old"}}
+	t.Cleanup(func() { superseded = old })
+
+	migrations := []Migration{
+		{Version: 1, Name: "create_blogs", Checksum: "aaa"},
+		{Version: 2, Name: "add_index", Checksum: "bbb"},
+	}
+
+	if err := checkApplied(migrations, map[int]applied{1: {checksum: "aaa"}, 2: {checksum: "old"}}); err != nil {
+		t.Errorf("checkApplied with a superseded checksum: %v", err)
+	}
+	if err := checkApplied(migrations, map[int]applied{1: {checksum: "old"}}); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("checkApplied with the superseded checksum of another migration = %v, want a checksum mismatch", err)
+	}
+}
+
+// TestSuperseded checks that the superseded checksums belong to embedded
+// migrations, and aren't the checksums of their current files, which would
+// mean that the entry is a mistake.
+func TestSuperseded(t *testing.T) {
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	byVersion := map[int]Migration{}
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	for version, checksums := range superseded {
+		m, ok := byVersion[version]
+		if !ok {
+			t.Errorf("superseded has checksums of unknown migration %d", version)
+			continue
+		}
+		for _, c := range checksums {
+			if c == m.Checksum {
+				t.Errorf("superseded has the current checksum of migration %d_%s", m.Version, m.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS blogs;
+DROP FUNCTION IF EXISTS set_updated_at();
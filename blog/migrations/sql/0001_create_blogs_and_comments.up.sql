@@ -0,0 +1,37 @@
+-- The blogs table stores the blog posts.
+CREATE TABLE blogs (
+    id         SERIAL PRIMARY KEY,
+    title      TEXT        NOT NULL,
+    content    TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- The comments table stores the comments of each blog.
+-- Comments are removed together with the blog they belong to.
+CREATE TABLE comments (
+    id         SERIAL PRIMARY KEY,
+    blog_id    INTEGER     NOT NULL REFERENCES blogs (id) ON DELETE CASCADE,
+    content    TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX comments_blog_id_idx ON comments (blog_id);
+
+-- set_updated_at keeps the updated_at column in sync on every UPDATE so the
+-- models don't have to remember to do it.
+CREATE FUNCTION set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER blogs_set_updated_at
+    BEFORE UPDATE ON blogs
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TRIGGER comments_set_updated_at
+    BEFORE UPDATE ON comments
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
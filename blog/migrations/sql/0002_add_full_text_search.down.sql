@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS comments_search_vector_idx;
+DROP INDEX IF EXISTS blogs_search_vector_idx;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE blogs DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS language;
+ALTER TABLE blogs DROP COLUMN IF EXISTS language;
@@ -0,0 +1,32 @@
+-- language is the text search configuration used to stem the words of a blog,
+-- for example 'english' turns "running" into "run". Comments use the language
+-- of their blog.
+ALTER TABLE blogs ADD COLUMN language REGCONFIG NOT NULL DEFAULT 'english';
+ALTER TABLE comments ADD COLUMN language REGCONFIG NOT NULL DEFAULT 'english';
+
+-- Filling in the new column doesn't edit the comments, so the trigger that
+-- sets updated_at is off while it runs.
+ALTER TABLE comments DISABLE TRIGGER comments_set_updated_at;
+
+UPDATE comments AS c
+SET language = b.language
+FROM blogs AS b
+WHERE b.id = c.blog_id;
+
+ALTER TABLE comments ENABLE TRIGGER comments_set_updated_at;
+
+-- search_vector holds the stemmed words of a row. Postgres keeps generated
+-- columns up to date on every INSERT and UPDATE. Words in the title weigh
+-- more (A) than words in the content (B) when ranking.
+ALTER TABLE blogs ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector(language, title), 'A') ||
+    setweight(to_tsvector(language, content), 'B')
+) STORED;
+
+ALTER TABLE comments ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector(language, content)
+) STORED;
+
+-- GIN indexes make the @@ match operator fast.
+CREATE INDEX blogs_search_vector_idx ON blogs USING GIN (search_vector);
+CREATE INDEX comments_search_vector_idx ON comments USING GIN (search_vector);
@@ -0,0 +1,4 @@
+ALTER TABLE comments DROP COLUMN IF EXISTS author_id;
+ALTER TABLE blogs DROP COLUMN IF EXISTS author_id;
+
+DROP TABLE IF EXISTS users;
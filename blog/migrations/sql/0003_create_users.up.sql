@@ -0,0 +1,25 @@
+-- The users table stores the accounts of authors and commenters.
+-- password_hash holds an argon2id (or bcrypt) hash, never the password.
+CREATE TABLE users (
+    id            SERIAL PRIMARY KEY,
+    email         TEXT        NOT NULL,
+    name          TEXT        NOT NULL,
+    password_hash TEXT        NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Email addresses are unique regardless of case.
+CREATE UNIQUE INDEX users_email_key ON users (lower(email));
+
+CREATE TRIGGER users_set_updated_at
+    BEFORE UPDATE ON users
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Blogs and comments written before accounts existed have no author.
+-- If an account is deleted, its blogs and comments stay without an author.
+ALTER TABLE blogs ADD COLUMN author_id INTEGER REFERENCES users (id) ON DELETE SET NULL;
+ALTER TABLE comments ADD COLUMN author_id INTEGER REFERENCES users (id) ON DELETE SET NULL;
+
+CREATE INDEX blogs_author_id_idx ON blogs (author_id);
+CREATE INDEX comments_author_id_idx ON comments (author_id);
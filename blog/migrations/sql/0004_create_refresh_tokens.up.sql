@@ -0,0 +1,18 @@
+-- The refresh_tokens table stores the refresh tokens handed out at login.
+--
+-- token_hash is the SHA-256 hash of the token, never the token itself.
+-- Every refresh revokes the used token and issues a new one in the same
+-- family. If a revoked token is used again, it has probably been stolen, and
+-- the whole family is revoked.
+CREATE TABLE refresh_tokens (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    family_id  TEXT        NOT NULL,
+    token_hash TEXT        NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
@@ -0,0 +1,6 @@
+-- Every user has one role, which decides what they may do.
+-- The roles are declared in blog/policy/policy.go. Existing users become
+-- authors, which is what everybody could do before roles existed.
+ALTER TABLE users
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'author'
+    CONSTRAINT users_role_check CHECK (role IN ('admin', 'editor', 'author', 'commenter'));
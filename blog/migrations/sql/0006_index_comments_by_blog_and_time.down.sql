@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS comments_blog_id_idx ON comments (blog_id);
+DROP INDEX IF EXISTS comments_blog_id_created_at_idx;
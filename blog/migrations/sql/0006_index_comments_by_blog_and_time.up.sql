@@ -0,0 +1,5 @@
+-- Comments are listed per blog, page by page, in (created_at, id) order.
+-- This index serves both the ORDER BY and the keyset condition of a cursor,
+-- and replaces the plain index on blog_id.
+CREATE INDEX comments_blog_id_created_at_idx ON comments (blog_id, created_at, id);
+DROP INDEX IF EXISTS comments_blog_id_idx;
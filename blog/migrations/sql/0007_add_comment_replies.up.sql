@@ -0,0 +1,9 @@
+-- A comment can be a reply to another comment of the same blog.
+-- Top-level comments have no parent. Replies are removed together with the
+-- comment they reply to, so a thread never has holes.
+ALTER TABLE comments
+    ADD COLUMN parent_id INTEGER
+    CONSTRAINT comments_parent_id_fkey REFERENCES comments (id) ON DELETE CASCADE;
+
+-- The recursive queries look up the replies of a comment by parent_id.
+CREATE INDEX comments_parent_id_idx ON comments (parent_id);
@@ -0,0 +1,2 @@
+ALTER TABLE blogs DROP COLUMN IF EXISTS published_at;
+ALTER TABLE blogs DROP COLUMN IF EXISTS status;
@@ -0,0 +1,20 @@
+-- Every blog goes through a lifecycle: it is written as a draft, optionally
+-- scheduled, published and eventually archived. Only published blogs are
+-- shown to readers.
+--
+-- published_at is when the blog was first published. It is kept when a blog
+-- is unpublished or archived, so that publishing it again doesn't make an
+-- old post look new.
+ALTER TABLE blogs
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'draft'
+        CONSTRAINT blogs_status_check CHECK (status IN ('draft', 'scheduled', 'published', 'archived')),
+    ADD COLUMN published_at TIMESTAMPTZ;
+
+-- Blogs written before the lifecycle existed were published when they were
+-- created. That doesn't edit them, so the trigger that sets updated_at is
+-- off while they are filled in.
+ALTER TABLE blogs DISABLE TRIGGER blogs_set_updated_at;
+UPDATE blogs SET status = 'published', published_at = created_at;
+ALTER TABLE blogs ENABLE TRIGGER blogs_set_updated_at;
+
+CREATE INDEX blogs_status_created_at_idx ON blogs (status, created_at, id);
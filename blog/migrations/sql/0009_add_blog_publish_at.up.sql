@@ -0,0 +1,8 @@
+-- A scheduled blog is published automatically at publish_at by the
+-- scheduler (see blog/scheduler). publish_at is only set while a blog is
+-- scheduled.
+ALTER TABLE blogs ADD COLUMN publish_at TIMESTAMPTZ;
+
+-- The scheduler looks for scheduled blogs that are due. The partial index
+-- only contains scheduled blogs, so it stays small.
+CREATE INDEX blogs_publish_at_idx ON blogs (publish_at) WHERE status = 'scheduled';
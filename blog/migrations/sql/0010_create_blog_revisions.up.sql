@@ -0,0 +1,21 @@
+-- Every version of a blog is kept as a revision: the first one when the blog
+-- is created, and a new one on every update. Revisions are numbered from 1
+-- per blog. A revision that restores an older one records which.
+CREATE TABLE blog_revisions (
+    id            SERIAL PRIMARY KEY,
+    blog_id       INTEGER     NOT NULL REFERENCES blogs (id) ON DELETE CASCADE,
+    revision      INTEGER     NOT NULL,
+    title         TEXT        NOT NULL,
+    content       TEXT        NOT NULL,
+    language      REGCONFIG   NOT NULL,
+    editor_id     INTEGER     REFERENCES users (id) ON DELETE SET NULL,
+    restored_from INTEGER,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT blog_revisions_blog_id_revision_key UNIQUE (blog_id, revision)
+);
+
+-- The current version of every existing blog becomes its first revision.
+INSERT INTO blog_revisions (blog_id, revision, title, content, language, editor_id, created_at)
+SELECT id, 1, title, content, language, author_id, updated_at
+FROM blogs;
@@ -0,0 +1,16 @@
+DROP TRIGGER IF EXISTS blogs_set_updated_at ON blogs;
+CREATE TRIGGER blogs_set_updated_at
+    BEFORE UPDATE ON blogs
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER IF EXISTS comments_set_updated_at ON comments;
+CREATE TRIGGER comments_set_updated_at
+    BEFORE UPDATE ON comments
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Blogs and comments in the trash would become visible again, so remove them.
+DELETE FROM comments WHERE deleted_at IS NOT NULL;
+DELETE FROM blogs WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE blogs DROP COLUMN IF EXISTS deleted_at;
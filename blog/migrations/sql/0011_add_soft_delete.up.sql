@@ -0,0 +1,34 @@
+-- Deleted blogs and comments are moved to the trash rather than removed:
+-- deleted_at is set, and every read leaves them out. They can be restored
+-- until the purge job (see blog/scheduler) removes them for good, once they
+-- have been in the trash for longer than the retention period.
+ALTER TABLE blogs ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE comments ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- The purge job removes blogs with DELETE, and relies on the comments being
+-- removed with them. Declare the foreign key again, so that it cascades no
+-- matter how the table was created.
+ALTER TABLE comments
+    DROP CONSTRAINT IF EXISTS comments_blog_id_fkey,
+    ADD CONSTRAINT comments_blog_id_fkey FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE;
+
+-- Moving something to the trash, or restoring it, is not an update of its
+-- content, so it doesn't change updated_at.
+DROP TRIGGER blogs_set_updated_at ON blogs;
+CREATE TRIGGER blogs_set_updated_at
+    BEFORE UPDATE ON blogs
+    FOR EACH ROW
+    WHEN (OLD.deleted_at IS NOT DISTINCT FROM NEW.deleted_at)
+    EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER comments_set_updated_at ON comments;
+CREATE TRIGGER comments_set_updated_at
+    BEFORE UPDATE ON comments
+    FOR EACH ROW
+    WHEN (OLD.deleted_at IS NOT DISTINCT FROM NEW.deleted_at)
+    EXECUTE FUNCTION set_updated_at();
+
+-- The trash listings and the purge job look for deleted rows. The partial
+-- indexes only contain those, so they stay small.
+CREATE INDEX blogs_deleted_at_idx ON blogs (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX comments_deleted_at_idx ON comments (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS blog_slug_history;
+ALTER TABLE blogs DROP COLUMN IF EXISTS slug;
@@ -0,0 +1,52 @@
+-- Every blog has a unique slug, derived from its title, that identifies it
+-- in URLs such as /blogs/by-slug/hello-world. New slugs are made by the
+-- slug package; see blog/slug/slug.go.
+--
+-- The constraint comes first, so that its index finds the slugs that are
+-- taken while the existing blogs get theirs. NULLs don't conflict.
+ALTER TABLE blogs ADD COLUMN slug TEXT CONSTRAINT blogs_slug_key UNIQUE;
+
+-- Give the existing blogs a slug. This is a simpler version of the rules of
+-- the slug package (accents are kept, for example), which is good enough
+-- for blogs that had no slug before. Like the slug package, it adds a
+-- number to a slug that is taken, such as "hello-world-2", and tries the
+-- next number if that is taken too, so that no two blogs end up with the
+-- same slug.
+--
+-- Giving blogs a slug doesn't edit them, so the trigger that sets
+-- updated_at is off while it runs.
+ALTER TABLE blogs DISABLE TRIGGER blogs_set_updated_at;
+
+DO $$
+DECLARE
+    blog      RECORD;
+    base      TEXT;
+    candidate TEXT;
+    n         INTEGER;
+BEGIN
+    FOR blog IN SELECT id, title FROM blogs ORDER BY id LOOP
+        base := COALESCE(NULLIF(LEFT(TRIM(BOTH '-' FROM LOWER(REGEXP_REPLACE(blog.title, '[^[:alnum:]]+', '-', 'g'))), 70), ''), 'blog');
+        candidate := base;
+        n := 1;
+        WHILE EXISTS (SELECT 1 FROM blogs WHERE slug = candidate) LOOP
+            n := n + 1;
+            candidate := base || '-' || n;
+        END LOOP;
+        UPDATE blogs SET slug = candidate WHERE id = blog.id;
+    END LOOP;
+END
+$$;
+
+ALTER TABLE blogs ENABLE TRIGGER blogs_set_updated_at;
+
+ALTER TABLE blogs ALTER COLUMN slug SET NOT NULL;
+
+-- When the title of a blog changes, so does its slug. The old slugs are
+-- kept here, so that links to them can be redirected to the current slug.
+CREATE TABLE blog_slug_history (
+    slug       TEXT PRIMARY KEY,
+    blog_id    INTEGER     NOT NULL REFERENCES blogs (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX blog_slug_history_blog_id_idx ON blog_slug_history (blog_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS blog_tags;
+DROP TABLE IF EXISTS tags;
+ALTER TABLE blogs DROP COLUMN IF EXISTS category_id;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,42 @@
+-- Categories form a tree: every category has at most one parent, and a blog
+-- is in at most one category. Listing the blogs of a category includes the
+-- blogs of its subcategories. A category with subcategories can't be
+-- deleted; deleting one with blogs leaves them without a category.
+CREATE TABLE categories (
+    id         SERIAL PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    slug       TEXT        NOT NULL,
+    parent_id  INTEGER     REFERENCES categories (id) ON DELETE RESTRICT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT categories_slug_key UNIQUE (slug),
+    CONSTRAINT categories_parent_id_check CHECK (parent_id <> id)
+);
+
+CREATE INDEX categories_parent_id_idx ON categories (parent_id);
+
+ALTER TABLE blogs ADD COLUMN category_id INTEGER REFERENCES categories (id) ON DELETE SET NULL;
+
+CREATE INDEX blogs_category_id_idx ON blogs (category_id);
+
+-- Tags are free-form labels, and a blog can have any number of them. Tags
+-- are identified by their slug, so "Go" and "go" are the same tag.
+CREATE TABLE tags (
+    id         SERIAL PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    slug       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT tags_slug_key UNIQUE (slug)
+);
+
+CREATE TABLE blog_tags (
+    blog_id INTEGER NOT NULL REFERENCES blogs (id) ON DELETE CASCADE,
+    tag_id  INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+
+    PRIMARY KEY (blog_id, tag_id)
+);
+
+-- The primary key covers lookups by blog; this index covers lookups by tag,
+-- such as the tag filter of GET /blogs and the tag cloud.
+CREATE INDEX blog_tags_tag_id_idx ON blog_tags (tag_id);
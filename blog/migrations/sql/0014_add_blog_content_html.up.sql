@@ -0,0 +1,9 @@
+-- The content of blogs is Markdown. content_html caches the sanitized HTML
+-- rendered from it by the markdown package (see blog/markdown), so that it
+-- isn't rendered on every read. content_html_version is the
+-- markdown.Version that rendered it. HTML rendered by another version, and
+-- blogs without HTML (such as the ones created before this migration), are
+-- rendered again when they are read, until they are next updated.
+ALTER TABLE blogs
+    ADD COLUMN content_html TEXT,
+    ADD COLUMN content_html_version INTEGER;
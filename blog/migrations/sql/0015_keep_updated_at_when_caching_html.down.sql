@@ -0,0 +1,6 @@
+DROP TRIGGER blogs_set_updated_at ON blogs;
+CREATE TRIGGER blogs_set_updated_at
+    BEFORE UPDATE ON blogs
+    FOR EACH ROW
+    WHEN (OLD.deleted_at IS NOT DISTINCT FROM NEW.deleted_at)
+    EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,14 @@
+-- Rendering the cached HTML of a blog again, after the markdown package has
+-- changed, is not an update of its content, so it doesn't change updated_at
+-- (which would, for example, make every blog look new to feed readers).
+-- BlogModel.RenderStaleHTML sets blog.cache_only for its transaction, and
+-- the trigger skips the rows it updates.
+DROP TRIGGER blogs_set_updated_at ON blogs;
+CREATE TRIGGER blogs_set_updated_at
+    BEFORE UPDATE ON blogs
+    FOR EACH ROW
+    WHEN (
+        OLD.deleted_at IS NOT DISTINCT FROM NEW.deleted_at
+        AND current_setting('blog.cache_only', true) IS DISTINCT FROM 'on'
+    )
+    EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,2 @@
+-- The purge job removes the refresh tokens that have expired, oldest first.
+CREATE INDEX refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
@@ -2,12 +2,19 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 
 	"blog/forms"
+	"blog/markdown"
 )
 
-// BLogModel wraps a sql.DB connection pool.
+// previewLength is the number of characters of content returned by GetAllBlogs.
+const previewLength = 350
+
+// BlogModel wraps a sql.DB connection pool.
+// It is the Postgres implementation of BlogStore.
 type BlogModel struct {
 	db *sql.DB
 }
@@ -18,67 +25,257 @@ func NewBlogModel(db *sql.DB) *BlogModel {
 }
 
 // CreateBlog inserts a new blog into the database.
-func (m *BlogModel) CreateBlog(blog forms.CreateBlogRequest) error {
+// The authorID is the ID of the user who writes the blog.
+func (m *BlogModel) CreateBlog(authorID int, blog forms.CreateBlogRequest) error {
 	// Prepare the SQL statement. This returns a sql.Stmt object, which can be
 	// used to execute the statement multiple times with different data.
 	//
-	// The $1 and $2 placeholders are used to represent the title and content
-	// parameters. This is known as "SQL parameter binding". It's a good idea to
-	// use parameter binding to prevent SQL injection attacks.
+	// The $1 to $5 placeholders are used to represent the title, content,
+	// language, author_id and status parameters. This is known as "SQL
+	// parameter binding". It's a good idea to use parameter binding to
+	// prevent SQL injection attacks.
 	//
 	// For more information on SQL parameter binding, see:
 	// https://www.calhoun.io/inserting-records-into-a-postgresql-database-with-gos-database-sql-package/
+	//
+	// A blog that is published right away gets its published_at from the
+	// database clock, like created_at. $6 is the slug, $7 the category, and
+	// $8 and $9 the rendered HTML and the version that rendered it.
+	//
+	// The WITH clause inserts the blog and passes the new row on to the
+	// second INSERT, which stores it as the first revision. Both happen in
+	// one statement, so there is never a blog without revisions.
 	stmt, err := m.db.Prepare(`
-		INSERT INTO blogs (title, content)
-		VALUES ($1, $2)
+		WITH created AS (
+			INSERT INTO blogs (title, content, language, author_id, status, published_at, slug, category_id, content_html, content_html_version)
+			VALUES ($1, $2, $3::regconfig, $4, $5, CASE WHEN $5 = 'published' THEN now() END, $6, $7, $8, $9)
+			RETURNING id, title, content, language, author_id
+		)
+		INSERT INTO blog_revisions (blog_id, revision, title, content, language, editor_id)
+		SELECT id, 1, title, content, language, author_id
+		FROM created
+		RETURNING blog_id
 	`)
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %v", err)
+		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close() // Remember to close the statement when you're done with it!
 
-	// Execute the statement, passing in the title and content parameters.
-	//
-	// The Exec method returns a sql.Result object, which contains some basic
-	// information about what happened when the statement was executed.
-	//
-	// We don't actually need the sql.Result object in this case, so we simply
-	// discard it with the blank identifier.
-	//
-	// If you want to get the ID of the newly inserted blog, you can use the
-	// LastInsertId method on the sql.Result object.
-	//
-	// For more information on the LastInsertId method, see:
-	// https://golang.org/pkg/database/sql/#Result
-	//
-	// If you want to get the number of rows affected by the statement, you can
-	// use the RowsAffected method on the sql.Result object.
-	//
-	// For more information on the RowsAffected method, see:
-	// https://golang.org/pkg/database/sql/#Result
-	//
-	// If you want to get both the ID of the newly inserted blog and the number
-	// of rows affected by the statement, you can use the Exec method on the
-	// sql.DB object instead of the sql.Stmt object.
-	//
-	// For more information on the Exec method, see:
-	// https://golang.org/pkg/database/sql/#DB
-	if _, err := stmt.Exec(blog.Title, blog.Content); err != nil {
-		return fmt.Errorf("failed to execute statement: %v", err)
+	language := blog.Language
+	if language == "" {
+		language = forms.DefaultLanguage
+	}
+	status := blog.Status
+	if status == "" {
+		status = forms.StatusDraft
 	}
+	contentHTML := markdown.Render(blog.Content)
 
-	return nil
+	// The blog is created in a transaction, which is retried with another
+	// slug if a blog that was created at the same time took this one.
+	return retryOnSlugConflict(func() error {
+		tx, err := m.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback() // Rollback is a no-op once the transaction is committed.
+
+		slug, err := uniqueSlug(tx, blog.Title, 0)
+		if err != nil {
+			return err
+		}
+
+		// Execute the statement, passing in the parameters. tx.Stmt runs the
+		// prepared statement in the transaction.
+		//
+		// The statement returns the ID of the new blog with RETURNING, so
+		// we use QueryRow and Scan rather than Exec, whose sql.Result can't
+		// give us the ID: LastInsertId isn't supported by Postgres.
+		//
+		// For more information on QueryRow, see:
+		// https://golang.org/pkg/database/sql/#Stmt.QueryRow
+		var id int
+		if err := tx.Stmt(stmt).QueryRow(
+			blog.Title, blog.Content, language, nullID(authorID), status, slug, nullOptionalID(blog.CategoryID),
+			contentHTML, markdown.Version,
+		).Scan(&id); err != nil {
+			return blogCategoryError(translate(err, "failed to create blog"), blog.CategoryID)
+		}
+
+		// The tags are added in the same transaction, so that a blog is
+		// never seen without them.
+		if err := setBlogTags(tx, id, blog.Tags); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// cachedHTML holds the content_html and content_html_version columns of a
+// blog.
+type cachedHTML struct {
+	html    sql.NullString
+	version sql.NullInt64
+}
+
+// render returns the cached HTML of the content, or renders it again if it
+// was rendered by another version of the markdown package, or not at all.
+func (c cachedHTML) render(content string) string {
+	if c.html.Valid && c.version.Valid && c.version.Int64 == markdown.Version {
+		return c.html.String
+	}
+	return markdown.Render(content)
+}
+
+// RenderStaleHTML renders the HTML of up to limit blogs whose cached HTML
+// was rendered by an older version of the markdown package, or not at all,
+// and caches it. It returns the number of blogs it rendered.
+//
+// Until then, render renders the HTML of those blogs on every read, so this
+// runs in the background after a release that changes the markdown package
+// (see scheduler.Renderer). Blogs whose HTML was rendered by a newer version
+// are left alone, so that instances of an older release don't undo the work
+// of newer ones. FOR UPDATE SKIP LOCKED lets several instances share the
+// work.
+func (m *BlogModel) RenderStaleHTML(limit int) (int, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback is a no-op once the transaction is committed.
+
+	// This is not an update of the content, so the trigger leaves
+	// updated_at alone; see migration 0015.
+	if _, err := tx.Exec(`SET LOCAL blog.cache_only = 'on'`); err != nil {
+		return 0, fmt.Errorf("failed to render stale HTML: %w", err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, content
+		FROM blogs
+		WHERE content_html_version IS NULL OR content_html_version < $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, markdown.Version, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get blogs with stale HTML: %w", err)
+	}
+
+	ids := []int{}
+	htmls := []string{}
+	for rows.Next() {
+		var id int
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan blog: %w", err)
+		}
+		ids = append(ids, id)
+		htmls = append(htmls, markdown.Render(content))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to get blogs with stale HTML: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE blogs AS b
+		SET content_html = u.html, content_html_version = $3
+		FROM unnest($1::int[], $2::text[]) AS u (id, html)
+		WHERE b.id = u.id
+	`, ids, htmls, markdown.Version); err != nil {
+		return 0, fmt.Errorf("failed to cache rendered HTML: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to cache rendered HTML: %w", err)
+	}
+	return len(ids), nil
+}
+
+// sortColumns maps the sort options of ListBlogsQuery to SQL expressions.
+//
+// Column names can't be passed as parameters like values can, so we only ever
+// put one of these fixed strings into the ORDER BY clause, never user input.
+//
+// Blogs that have never been published sort by their creation time when
+// sorting by published_at.
+var sortColumns = map[string]string{
+	"created_at":   "b.created_at",
+	"updated_at":   "b.updated_at",
+	"published_at": "COALESCE(b.published_at, b.created_at)",
+	"title":        "b.title",
+	"comments":     "comments",
 }
 
-// GetAllBlogs returns all blogs from the database.
-func (m *BlogModel) GetAllBlogs() ([]forms.GetAllBlogsResponse, error) {
+// GetAllBlogs returns a page of blogs from the database.
+//
+// The query selects the page (limit and offset, or cursor), the sort order
+// and the filters. See forms.ListBlogsQuery for details.
+func (m *BlogModel) GetAllBlogs(query forms.ListBlogsQuery) (forms.ListBlogsResponse, error) {
+	query.Normalize()
+
+	// Count the matching blogs. The cursor is not part of the filters, so
+	// that the total doesn't change from page to page.
+	var countQB queryBuilder
+	addBlogFilters(&countQB, query)
+
+	var total int
+	if err := m.db.QueryRow(
+		`SELECT COUNT(*) FROM blogs AS b `+countQB.clause(),
+		countQB.args...,
+	).Scan(&total); err != nil {
+		return forms.ListBlogsResponse{}, fmt.Errorf("failed to count blogs: %w", err)
+	}
+
+	// The content is cut to previewLength characters, unless the whole
+	// content is asked for. Only the whole content has its HTML in the
+	// cache; the HTML of a preview is always rendered.
+	var qb queryBuilder
+	content := "b.content, b.content_html, b.content_html_version"
+	if !query.FullContent {
+		content = fmt.Sprintf("SUBSTRING(b.content FROM 1 FOR %s), NULL, NULL", qb.arg(previewLength))
+	}
+	addBlogFilters(&qb, query)
+
+	// With a cursor, we continue right after the last blog of the previous
+	// page. This is known as "keyset pagination". Unlike OFFSET, Postgres
+	// doesn't have to read and skip all the earlier rows.
+	//
+	// For more information on keyset pagination, see:
+	// https://use-the-index-luke.com/no-offset
+	if query.Cursor != "" {
+		if query.Sort != "created_at" {
+			return forms.ListBlogsResponse{}, newError(ErrValidation, "a cursor can only be used when sorting by created_at")
+		}
+		if query.Offset != 0 {
+			return forms.ListBlogsResponse{}, newError(ErrValidation, "a cursor can't be used together with an offset")
+		}
+
+		c, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return forms.ListBlogsResponse{}, err
+		}
+
+		op := "<"
+		if query.Order == "asc" {
+			op = ">"
+		}
+		qb.where(fmt.Sprintf("(b.created_at, b.id) %s (%s, %s)", op, qb.arg(c.CreatedAt), qb.arg(c.ID)))
+	}
+
 	// We use the LEFT JOIN to ensure that we get a row for every blog, even if
 	// it doesn't have any comments. This is so that we can display the number of
 	// comments for each blog.
 	//
-	// We use the SUBSTRING function to limit the length of the blog content to
-	// 350 characters. This is so that we can display a short preview of the blog
-	// content on the home page.
+	// We use the SUBSTRING function above to limit the length of the blog
+	// content to previewLength characters. This is so that we can display a
+	// short preview of the blog content on the home page.
 	//
 	// We use the COUNT function to count the number of comments for each blog.
 	// This is so that we can display the number of comments for each blog on the
@@ -86,28 +283,59 @@ func (m *BlogModel) GetAllBlogs() ([]forms.GetAllBlogsResponse, error) {
 	//
 	// We use the GROUP BY clause to group the results by blog ID. This is so
 	// that we don't get duplicate rows in the result set.
+	//
+	// We always sort by the ID last, so that blogs with the same sort value
+	// (for example the same title) are always returned in the same order.
+	//
+	// We fetch one more row than requested to find out whether there is a
+	// next page.
+	direction := strings.ToUpper(query.Order)
 	rows, err := m.db.Query(
-		`SELECT
+		fmt.Sprintf(`SELECT
 			b.id,
+			b.slug,
 			b.title,
-			SUBSTRING(b.content FROM 0 FOR 350),
+			%s,
 			b.created_at,
 			b.updated_at,
-			COUNT(c.id) AS comments
+			COUNT(c.id) AS comments,
+			u.id,
+			u.name,
+			b.status,
+			b.published_at,
+			b.publish_at,
+			cat.id,
+			cat.name,
+			cat.slug
 		FROM blogs AS b
-		LEFT JOIN comments AS c ON c.blog_id = b.id
-		GROUP BY b.id`,
+		LEFT JOIN comments AS c ON c.blog_id = b.id AND c.deleted_at IS NULL
+		LEFT JOIN users AS u ON u.id = b.author_id
+		LEFT JOIN categories AS cat ON cat.id = b.category_id
+		%s
+		GROUP BY b.id, u.id, cat.id
+		ORDER BY %s %s, b.id %s
+		LIMIT %s OFFSET %s`,
+			content,
+			qb.clause(),
+			sortColumns[query.Sort], direction, direction,
+			qb.arg(query.Limit+1), qb.arg(query.Offset),
+		),
+		qb.args...,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all blogs: %v", err)
+		return forms.ListBlogsResponse{}, fmt.Errorf("failed to get all blogs: %w", err)
 	}
 	defer rows.Close() // Remember to close the rows when you're done with them!
 
-	// Initialize an empty slice to hold the blogs.
-	var blogs []forms.GetAllBlogsResponse
+	// Initialize an empty slice to hold the blogs. We use make rather than
+	// var, so that an empty page is encoded as [] rather than null.
+	blogs := make([]forms.GetAllBlogsResponse, 0, query.Limit)
 	for rows.Next() {
 		// Initialize a new blog struct.
 		var blog forms.GetAllBlogsResponse
+		var author authorColumns
+		var category categoryColumns
+		var html cachedHTML
 		// Use rows.Scan to copy the values from each field in the row into the
 		// corresponding field in the blog struct.
 		//
@@ -120,19 +348,116 @@ func (m *BlogModel) GetAllBlogs() ([]forms.GetAllBlogsResponse, error) {
 		// returned.
 		if err := rows.Scan(
 			&blog.ID,
+			&blog.Slug,
 			&blog.Title,
 			&blog.Content,
+			&html.html,
+			&html.version,
 			&blog.CreatedAt,
 			&blog.UpdatedAt,
 			&blog.Comments,
+			&author.id,
+			&author.name,
+			&blog.Status,
+			&blog.PublishedAt,
+			&blog.PublishAt,
+			&category.id,
+			&category.name,
+			&category.slug,
 		); err != nil {
-			return nil, fmt.Errorf("failed to scan blog: %v", err)
+			return forms.ListBlogsResponse{}, fmt.Errorf("failed to scan blog: %w", err)
 		}
+		blog.Author = author.author()
+		blog.Category = category.category()
+
+		blog.ContentHTML = html.render(blog.Content)
 
 		blogs = append(blogs, blog)
 	}
+	if err := rows.Err(); err != nil {
+		return forms.ListBlogsResponse{}, fmt.Errorf("failed to get all blogs: %w", err)
+	}
+
+	// Get the tags of all the blogs on the page with one more query, rather
+	// than one query per blog.
+	ids := make([]int, len(blogs))
+	for i, blog := range blogs {
+		ids[i] = blog.ID
+	}
+	tags, err := blogTags(m.db, ids)
+	if err != nil {
+		return forms.ListBlogsResponse{}, err
+	}
+	for i := range blogs {
+		blogs[i].Tags = tags[blogs[i].ID]
+	}
+
+	return newBlogsPage(blogs, query, total), nil
+}
+
+// addBlogFilters adds the filters of a ListBlogsQuery to the WHERE clause.
+//
+// Blogs that aren't published are only included if the viewer may see them:
+// all of them with ViewAll, otherwise only the viewer's own.
+func addBlogFilters(qb *queryBuilder, query forms.ListBlogsQuery) {
+	qb.where("b.deleted_at IS NULL")
+	qb.where("b.status = " + qb.arg(query.Status))
+	if query.Status != forms.StatusPublished && !query.ViewAll {
+		qb.where("b.author_id = " + qb.arg(query.ViewerID))
+	}
 
-	return blogs, nil
+	if !query.CreatedAfter.IsZero() {
+		qb.where("b.created_at >= " + qb.arg(query.CreatedAfter))
+	}
+	if !query.CreatedBefore.IsZero() {
+		qb.where("b.created_at < " + qb.arg(query.CreatedBefore))
+	}
+	if !query.UpdatedAfter.IsZero() {
+		qb.where("b.updated_at >= " + qb.arg(query.UpdatedAfter))
+	}
+	if !query.UpdatedBefore.IsZero() {
+		qb.where("b.updated_at < " + qb.arg(query.UpdatedBefore))
+	}
+	if query.Title != "" {
+		qb.where("b.title ILIKE " + qb.arg(containsPattern(query.Title)))
+	}
+	if query.Tag != "" {
+		qb.where(`EXISTS (
+			SELECT 1
+			FROM blog_tags AS bt
+			JOIN tags AS t ON t.id = bt.tag_id
+			WHERE bt.blog_id = b.id AND t.slug = ` + qb.arg(query.Tag) + `
+		)`)
+	}
+	if query.Category != "" {
+		qb.where("b.category_id IN (" + fmt.Sprintf(categorySubtree, qb.arg(query.Category)) + ")")
+	}
+}
+
+// newBlogsPage returns the page for a list of blogs that was fetched with one
+// extra row, as done by GetAllBlogs.
+func newBlogsPage(blogs []forms.GetAllBlogsResponse, query forms.ListBlogsQuery, total int) forms.ListBlogsResponse {
+	page := forms.ListBlogsResponse{
+		Data: blogs,
+		Pagination: forms.Pagination{
+			Limit:  query.Limit,
+			Offset: query.Offset,
+			Total:  total,
+		},
+	}
+
+	if len(blogs) > query.Limit {
+		page.Data = blogs[:query.Limit]
+		page.Pagination.HasMore = true
+
+		// Cursors are only supported when sorting by created_at.
+		if query.Sort == "created_at" {
+			last := page.Data[len(page.Data)-1]
+			page.Pagination.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+		}
+	}
+
+	return page
 }
 
 // GetBlogByID returns a single blog from the database, based on its ID.
@@ -141,16 +466,30 @@ func (m *BlogModel) GetBlogByID(id int) (forms.GetBlogByIDResponse, error) {
 	// used to execute the statement multiple times with different data.
 	stmt, err := m.db.Prepare(`
 		SELECT
-			id,
-			title,
-			content,
-			created_at,
-			updated_at
-		FROM blogs
-		WHERE id = $1
+			b.id,
+			b.slug,
+			b.title,
+			b.content,
+			b.content_html,
+			b.content_html_version,
+			b.language::text,
+			b.created_at,
+			b.updated_at,
+			u.id,
+			u.name,
+			b.status,
+			b.published_at,
+			b.publish_at,
+			cat.id,
+			cat.name,
+			cat.slug
+		FROM blogs AS b
+		LEFT JOIN users AS u ON u.id = b.author_id
+		LEFT JOIN categories AS cat ON cat.id = b.category_id
+		WHERE b.id = $1 AND b.deleted_at IS NULL
 	`)
 	if err != nil {
-		return forms.GetBlogByIDResponse{}, fmt.Errorf("failed to prepare statement: %v", err)
+		return forms.GetBlogByIDResponse{}, fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close() // Remember to close the statement when you're done with it!
 
@@ -165,112 +504,266 @@ func (m *BlogModel) GetBlogByID(id int) (forms.GetBlogByIDResponse, error) {
 
 	// Initialize a new blog struct.
 	var blog forms.GetBlogByIDResponse
+	var author authorColumns
+	var category categoryColumns
+	var html cachedHTML
 	// Use row.Scan to copy the values from each field in the row into the
 	// corresponding field in the blog struct.
 	if err := row.Scan(
 		&blog.ID,
+		&blog.Slug,
 		&blog.Title,
 		&blog.Content,
+		&html.html,
+		&html.version,
+		&blog.Language,
 		&blog.CreatedAt,
 		&blog.UpdatedAt,
+		&author.id,
+		&author.name,
+		&blog.Status,
+		&blog.PublishedAt,
+		&blog.PublishAt,
+		&category.id,
+		&category.name,
+		&category.slug,
 	); err != nil {
-		return forms.GetBlogByIDResponse{}, fmt.Errorf("failed to scan blog: %v", err)
+		// If no blog has the given ID, Scan returns sql.ErrNoRows, which
+		// translate turns into ErrNotFound.
+		return forms.GetBlogByIDResponse{}, translate(err, "blog %d not found", id)
 	}
+	blog.Author = author.author()
+	blog.Category = category.category()
+	blog.ContentHTML = html.render(blog.Content)
 
-	// Get the comments for the blog.
-	commentRows, err := m.db.Query(`
-		SELECT
-			id,
-			blog_id,
-			content,
-			created_at,
-			updated_at
-		FROM comments
-		WHERE blog_id = $1
-	`, id)
+	tags, err := blogTags(m.db, []int{id})
 	if err != nil {
-		return forms.GetBlogByIDResponse{}, fmt.Errorf("failed to get comments: %v", err)
-	}
-	defer commentRows.Close() // Remember to close the rows when you're done with them!
-
-	for commentRows.Next() {
-		// Initialize a new comment struct.
-		var comment forms.Comment
-		if err := commentRows.Scan(
-			&comment.ID,
-			&comment.BlogID,
-			&comment.Content,
-			&comment.CreatedAt,
-			&comment.UpdatedAt,
-		); err != nil {
-			return forms.GetBlogByIDResponse{}, fmt.Errorf("failed to scan comment: %v", err)
-		}
+		return forms.GetBlogByIDResponse{}, err
+	}
+	blog.Tags = tags[id]
 
-		blog.Comments = append(blog.Comments, comment)
+	// Get the first page of comments. The other pages can be fetched with
+	// ListComments.
+	comments, err := m.listComments(id, forms.ListCommentsQuery{})
+	if err != nil {
+		return forms.GetBlogByIDResponse{}, err
 	}
+	blog.Comments = comments.Data
+	blog.CommentsPagination = comments.Pagination
 
 	return blog, nil
 }
 
+// GetBlogInfo returns who wrote a blog, its status and whether it is in the
+// trash. It is used to check whether a user may see or change the blog.
+//
+// Unlike the other reads, it finds blogs in the trash, so that they can be
+// restored.
+func (m *BlogModel) GetBlogInfo(id int) (BlogInfo, error) {
+	var info BlogInfo
+	var authorID sql.NullInt64
+	if err := m.db.QueryRow(`
+		SELECT author_id, status, deleted_at IS NOT NULL
+		FROM blogs
+		WHERE id = $1
+	`, id).Scan(&authorID, &info.Status, &info.Deleted); err != nil {
+		return BlogInfo{}, translate(err, "blog %d not found", id)
+	}
+	info.AuthorID = int(authorID.Int64)
+
+	return info, nil
+}
+
 // UpdateBlog updates a single blog in the database, based on its ID.
-func (m *BlogModel) UpdateBlog(id int, blog forms.UpdateBlogRequest) error {
-	// Prepare the SQL statement.
+// The editorID is the ID of the user who makes the change.
+//
+// The new version of the blog is stored as a revision. If the new title
+// has another slug, the old slug is kept in the slug history.
+func (m *BlogModel) UpdateBlog(id, editorID int, blog forms.UpdateBlogRequest) error {
+	return retryOnSlugConflict(func() error {
+		// Begin a transaction. If the language changes, the comments of the
+		// blog change language too, and either all updates happen or none
+		// does.
+		//
+		// For more information on transactions, see:
+		// https://go.dev/doc/database/execute-transactions
+		tx, err := m.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback() // Rollback is a no-op once the transaction is committed.
+
+		if err := updateBlog(tx, id, editorID, blog, 0); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// updateBlog updates a blog and stores the new version as a revision, as
+// part of the transaction tx. restoredFrom is the number of the revision that
+// the update restores, or 0.
+func updateBlog(tx *sql.Tx, id, editorID int, blog forms.UpdateBlogRequest, restoredFrom int) error {
+	// FOR UPDATE locks the blog until the transaction ends, so that no other
+	// update can change its slug or take the same revision number in the
+	// meantime.
+	var oldTitle, oldSlug string
+	if err := tx.QueryRow(`
+		SELECT title, slug
+		FROM blogs
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, id).Scan(&oldTitle, &oldSlug); err != nil {
+		return translate(err, "blog %d not found", id)
+	}
+
+	newSlug, err := updatedSlug(tx, id, oldTitle, oldSlug, blog.Title)
+	if err != nil {
+		return err
+	}
+
+	// The $1, $2, $3, $4 and $5 placeholders are used to represent the
+	// title, content, language, slug and id parameters. $6 says whether the
+	// category changes, and $7 is the new one. $8 and $9 are the rendered
+	// HTML and the version that rendered it.
 	//
-	// The $1, $2 and $3 placeholders are used to represent the title, content
-	// and id parameters.
-	stmt, err := m.db.Prepare(`
+	// NULLIF turns an empty language into NULL, and COALESCE then keeps the
+	// current language.
+	result, err := tx.Exec(`
 		UPDATE blogs
-		SET title = $1, content = $2
-		WHERE id = $3
-	`)
+		SET
+			title = $1,
+			content = $2,
+			language = COALESCE(NULLIF($3, '')::regconfig, language),
+			slug = $4,
+			category_id = CASE WHEN $6 THEN $7 ELSE category_id END,
+			content_html = $8,
+			content_html_version = $9
+		WHERE id = $5 AND deleted_at IS NULL
+	`,
+		blog.Title, blog.Content, blog.Language, newSlug, id,
+		blog.CategoryID != nil, nullOptionalID(blog.CategoryID),
+		markdown.Render(blog.Content), markdown.Version,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %v", err)
+		return blogCategoryError(translate(err, "failed to update blog %d", id), blog.CategoryID)
+	}
+
+	// An UPDATE that matches no rows is not an error in SQL, so we use
+	// RowsAffected to find out whether the blog exists.
+	if err := checkRowsAffected(result, "blog %d not found", id); err != nil {
+		return err
+	}
+
+	// The tags are only replaced if the request has them; an empty list
+	// removes them.
+	if blog.Tags != nil {
+		if err := setBlogTags(tx, id, blog.Tags); err != nil {
+			return err
+		}
+	}
+
+	if blog.Language != "" {
+		if _, err := tx.Exec(`
+			UPDATE comments
+			SET language = $1::regconfig
+			WHERE blog_id = $2
+		`, blog.Language, id); err != nil {
+			return translate(err, "failed to update comments of blog %d", id)
+		}
 	}
-	defer stmt.Close() // Remember to close the statement when you're done with it!
 
-	// Execute the statement, passing in the title, content and id parameters.
-	if _, err := stmt.Exec(blog.Title, blog.Content, id); err != nil {
-		return fmt.Errorf("failed to execute statement: %v", err)
+	if _, err := tx.Exec(`
+		INSERT INTO blog_revisions (blog_id, revision, title, content, language, editor_id, restored_from)
+		SELECT
+			b.id,
+			COALESCE((SELECT MAX(r.revision) FROM blog_revisions AS r WHERE r.blog_id = b.id), 0) + 1,
+			b.title,
+			b.content,
+			b.language,
+			$2,
+			$3
+		FROM blogs AS b
+		WHERE b.id = $1
+	`, id, nullID(editorID), nullID(restoredFrom)); err != nil {
+		return translate(err, "failed to save revision of blog %d", id)
 	}
 
 	return nil
 }
 
-// DeleteBlog deletes a single blog from the database, based on its ID.
+// DeleteBlog moves a single blog to the trash, based on its ID.
+//
+// The blog isn't removed from the database: deleted_at is set, and the
+// other queries leave it out. Its comments are left as they are, so they
+// come back when the blog is restored. PurgeDeleted removes it for good.
 func (m *BlogModel) DeleteBlog(id int) error {
 	// Prepare the SQL statement.
 	stmt, err := m.db.Prepare(`
-		DELETE FROM blogs
-		WHERE id = $1
+		UPDATE blogs
+		SET deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL
 	`)
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %v", err)
+		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close() // Remember to close the statement when you're done with it!
 
 	// Execute the statement, passing in the id parameter.
-	if _, err := stmt.Exec(id); err != nil {
-		return fmt.Errorf("failed to execute statement: %v", err)
+	result, err := stmt.Exec(id)
+	if err != nil {
+		return translate(err, "failed to delete blog %d", id)
 	}
 
-	return nil
+	return checkRowsAffected(result, "blog %d not found", id)
 }
 
 // CreateComment inserts a new comment into the database.
-func (m *BlogModel) CreateComment(blogID int, comment forms.CreateCommentRequest) error {
+// The authorID is the ID of the user who writes the comment.
+func (m *BlogModel) CreateComment(blogID, authorID int, comment forms.CreateCommentRequest) error {
 	// Prepare the SQL statement.
+	//
+	// INSERT ... SELECT copies the language of the blog to the comment. If the
+	// blog doesn't exist or is in the trash, the SELECT returns no rows and
+	// nothing is inserted.
 	stmt, err := m.db.Prepare(`
-		INSERT INTO comments (blog_id, content)
-		VALUES ($1, $2)
+		INSERT INTO comments (blog_id, content, language, author_id)
+		SELECT id, $2, language, $3
+		FROM blogs
+		WHERE id = $1 AND deleted_at IS NULL
 	`)
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %v", err)
+		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close() // Remember to close the statement when you're done with it!
 
-	// Execute the statement, passing in the blog_id and content parameters.
-	if _, err := stmt.Exec(blogID, comment.Content); err != nil {
-		return fmt.Errorf("failed to execute statement: %v", err)
+	// Execute the statement, passing in the blog_id, content and author_id
+	// parameters.
+	result, err := stmt.Exec(blogID, comment.Content, nullID(authorID))
+	if err != nil {
+		err = translate(err, "failed to create comment")
+
+		// A violation of the blog_id foreign key means that the blog was
+		// deleted in the meantime.
+		if errors.Is(err, ErrConstraint) && constraintName(err) == "comments_blog_id_fkey" {
+			return &Error{Kind: ErrNotFound, Message: fmt.Sprintf("blog %d not found", blogID), Err: err}
+		}
+		return err
+	}
+
+	return checkRowsAffected(result, "blog %d not found", blogID)
+}
+
+// checkRowsAffected returns an ErrNotFound error with the given message if the
+// statement didn't affect any rows.
+func checkRowsAffected(result sql.Result, format string, args ...any) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if n == 0 {
+		return newError(ErrNotFound, format, args...)
 	}
 
 	return nil
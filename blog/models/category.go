@@ -0,0 +1,248 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"blog/forms"
+	"blog/slug"
+)
+
+// categoryTree selects all categories with their depth and path, walking
+// down from the top-level categories. sort_path lists the slugs of the path
+// in an array, so that a category sorts right before its subcategories
+// ("go" < "go/web" < "go-lang"), which the path itself doesn't do.
+const categoryTree = `
+	WITH RECURSIVE tree AS (
+		SELECT id, name, slug, parent_id, created_at, 0 AS depth, slug AS path, ARRAY[slug] AS sort_path
+		FROM categories
+		WHERE parent_id IS NULL
+		UNION ALL
+		SELECT c.id, c.name, c.slug, c.parent_id, c.created_at, t.depth + 1, t.path || '/' || c.slug, t.sort_path || c.slug
+		FROM categories AS c
+		JOIN tree AS t ON c.parent_id = t.id
+	)
+	SELECT id, name, slug, parent_id, created_at, depth, path
+	FROM tree
+`
+
+// categorySubtree selects the IDs of the category with the slug %s and of
+// all its subcategories.
+const categorySubtree = `
+	WITH RECURSIVE subtree AS (
+		SELECT id FROM categories WHERE slug = %s
+		UNION ALL
+		SELECT c.id FROM categories AS c JOIN subtree AS s ON c.parent_id = s.id
+	)
+	SELECT id FROM subtree
+`
+
+// categoryName trims the name of a category and returns it with its slug.
+// It returns an ErrValidation error if nothing is left of the name.
+func categoryName(name string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "", newError(ErrValidation, "category names can't be blank")
+	}
+	return name, slug.Make(name), nil
+}
+
+// ListCategories returns all the categories as a flattened tree.
+func (m *BlogModel) ListCategories() (forms.ListCategoriesResponse, error) {
+	rows, err := m.db.Query(categoryTree + `ORDER BY sort_path`)
+	if err != nil {
+		return forms.ListCategoriesResponse{}, fmt.Errorf("failed to get categories: %w", err)
+	}
+	defer rows.Close() // Remember to close the rows when you're done with them!
+
+	categories := []forms.Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return forms.ListCategoriesResponse{}, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return forms.ListCategoriesResponse{}, fmt.Errorf("failed to get categories: %w", err)
+	}
+
+	return forms.ListCategoriesResponse{Data: categories}, nil
+}
+
+// GetCategory returns a single category.
+func (m *BlogModel) GetCategory(id int) (forms.Category, error) {
+	category, err := scanCategory(m.db.QueryRow(categoryTree+`WHERE id = $1`, id))
+	if err != nil {
+		return forms.Category{}, translate(err, "category %d not found", id)
+	}
+
+	return category, nil
+}
+
+// CreateCategory creates a category and returns it. It returns an
+// ErrConflict error if a category with the same slug exists, and an
+// ErrConstraint error if the parent doesn't exist.
+func (m *BlogModel) CreateCategory(req forms.CategoryRequest) (forms.Category, error) {
+	name, s, err := categoryName(req.Name)
+	if err != nil {
+		return forms.Category{}, err
+	}
+
+	var id int
+	if err := m.db.QueryRow(`
+		INSERT INTO categories (name, slug, parent_id)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, name, s, nullOptionalID(req.ParentID)).Scan(&id); err != nil {
+		return forms.Category{}, categoryError(err, s, req.ParentID)
+	}
+
+	return m.GetCategory(id)
+}
+
+// UpdateCategory renames a category and moves it below another parent, and
+// returns it. The slug changes with the name.
+//
+// It returns an ErrValidation error if the new parent is the category itself
+// or one of its subcategories, which would make the tree a loop.
+func (m *BlogModel) UpdateCategory(id int, req forms.CategoryRequest) (forms.Category, error) {
+	name, s, err := categoryName(req.Name)
+	if err != nil {
+		return forms.Category{}, err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return forms.Category{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback is a no-op once the transaction is committed.
+
+	if req.ParentID != nil {
+		// The lock keeps other transactions from moving categories until
+		// this one ends, so that two moves can't make a loop together. It
+		// doesn't block reads.
+		if _, err := tx.Exec(`LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return forms.Category{}, fmt.Errorf("failed to lock categories: %w", err)
+		}
+
+		var loop bool
+		if err := tx.QueryRow(`
+			WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id FROM categories WHERE id = $1
+				UNION ALL
+				SELECT c.id, c.parent_id FROM categories AS c JOIN ancestors AS a ON c.id = a.parent_id
+			)
+			SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)
+		`, *req.ParentID, id).Scan(&loop); err != nil {
+			return forms.Category{}, fmt.Errorf("failed to check the parent of category %d: %w", id, err)
+		}
+		if loop {
+			return forms.Category{}, newError(ErrValidation, "category %d can't be moved below itself", id)
+		}
+	}
+
+	result, err := tx.Exec(`
+		UPDATE categories
+		SET name = $1, slug = $2, parent_id = $3
+		WHERE id = $4
+	`, name, s, nullOptionalID(req.ParentID), id)
+	if err != nil {
+		return forms.Category{}, categoryError(err, s, req.ParentID)
+	}
+	if err := checkRowsAffected(result, "category %d not found", id); err != nil {
+		return forms.Category{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return forms.Category{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return m.GetCategory(id)
+}
+
+// DeleteCategory deletes a category. Its blogs are left without a category.
+// It returns an ErrConflict error if the category has subcategories.
+func (m *BlogModel) DeleteCategory(id int) error {
+	result, err := m.db.Exec(`DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		err = translate(err, "failed to delete category %d", id)
+		if constraintName(err) == "categories_parent_id_fkey" {
+			return &Error{Kind: ErrConflict, Message: fmt.Sprintf("category %d has subcategories", id), Err: err}
+		}
+		return err
+	}
+
+	return checkRowsAffected(result, "category %d not found", id)
+}
+
+// categoryError translates an error returned by an INSERT or UPDATE of a
+// category with the slug s and the parent parentID.
+func categoryError(err error, s string, parentID *int) error {
+	err = translate(err, "a category with the slug %q already exists", s)
+	if errors.Is(err, ErrConstraint) && constraintName(err) == "categories_parent_id_fkey" && parentID != nil {
+		return &Error{Kind: ErrConstraint, Message: fmt.Sprintf("category %d not found", *parentID), Err: err}
+	}
+	return err
+}
+
+// blogCategoryError translates an error returned by an INSERT or UPDATE of a
+// blog in the category with the ID categoryID. A violation of the foreign key
+// means that the category doesn't exist.
+func blogCategoryError(err error, categoryID *int) error {
+	if errors.Is(err, ErrConstraint) && constraintName(err) == "blogs_category_id_fkey" && categoryID != nil {
+		return &Error{Kind: ErrConstraint, Message: fmt.Sprintf("category %d not found", *categoryID), Err: err}
+	}
+	return err
+}
+
+// nullOptionalID turns an optional ID into NULL, for the parent_id and
+// category_id columns. 0 counts as no ID, like nullID.
+func nullOptionalID(id *int) sql.NullInt64 {
+	if id == nil {
+		return sql.NullInt64{}
+	}
+	return nullID(*id)
+}
+
+// scanCategory scans a category selected with categoryTree.
+func scanCategory(row rowScanner) (forms.Category, error) {
+	var category forms.Category
+	var parentID sql.NullInt64
+	if err := row.Scan(
+		&category.ID,
+		&category.Name,
+		&category.Slug,
+		&parentID,
+		&category.CreatedAt,
+		&category.Depth,
+		&category.Path,
+	); err != nil {
+		return forms.Category{}, err
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		category.ParentID = &id
+	}
+
+	return category, nil
+}
+
+// categoryColumns holds the columns of the category of a blog, which are
+// NULL for blogs without a category.
+type categoryColumns struct {
+	id   sql.NullInt64
+	name sql.NullString
+	slug sql.NullString
+}
+
+// category returns the scanned category, or nil if there is none.
+func (c categoryColumns) category() *forms.BlogCategory {
+	if !c.id.Valid {
+		return nil
+	}
+	return &forms.BlogCategory{ID: int(c.id.Int64), Name: c.name.String, Slug: c.slug.String}
+}
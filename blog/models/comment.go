@@ -0,0 +1,332 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"blog/forms"
+)
+
+// commentSortColumns maps the sort options of ListCommentsQuery to columns.
+var commentSortColumns = map[string]string{
+	"created_at": "c.created_at",
+	"updated_at": "c.updated_at",
+}
+
+// ListComments returns a page of the comments of a blog.
+// It returns an ErrNotFound error if the blog doesn't exist or is in the
+// trash.
+func (m *BlogModel) ListComments(blogID int, query forms.ListCommentsQuery) (forms.ListCommentsResponse, error) {
+	// Without this check, a blog that doesn't exist would look like a blog
+	// without comments.
+	var exists bool
+	if err := m.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM blogs WHERE id = $1 AND deleted_at IS NULL)`, blogID).Scan(&exists); err != nil {
+		return forms.ListCommentsResponse{}, fmt.Errorf("failed to get blog %d: %w", blogID, err)
+	}
+	if !exists {
+		return forms.ListCommentsResponse{}, newError(ErrNotFound, "blog %d not found", blogID)
+	}
+
+	return m.listComments(blogID, query)
+}
+
+// listComments returns a page of the comments of a blog, without checking
+// whether the blog exists.
+//
+// The page is a flattened tree of comments: the pagination applies to the
+// top-level comments, and every top-level comment is followed by its replies.
+//
+// Comments in the trash are left out, and so are their replies.
+func (m *BlogModel) listComments(blogID int, query forms.ListCommentsQuery) (forms.ListCommentsResponse, error) {
+	query.Normalize()
+
+	// The total only counts top-level comments, like the pagination.
+	var total int
+	if err := m.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM comments
+		WHERE blog_id = $1 AND parent_id IS NULL AND deleted_at IS NULL
+	`, blogID).Scan(&total); err != nil {
+		return forms.ListCommentsResponse{}, fmt.Errorf("failed to count comments: %w", err)
+	}
+
+	var qb queryBuilder
+	qb.where("c.blog_id = " + qb.arg(blogID))
+	qb.where("c.parent_id IS NULL")
+	qb.where("c.deleted_at IS NULL")
+
+	// With a cursor, we continue right after the last top-level comment of
+	// the previous page, in the same way as GetAllBlogs.
+	if query.Cursor != "" {
+		if query.Sort != "created_at" {
+			return forms.ListCommentsResponse{}, newError(ErrValidation, "a cursor can only be used when sorting by created_at")
+		}
+		if query.Offset != 0 {
+			return forms.ListCommentsResponse{}, newError(ErrValidation, "a cursor can't be used together with an offset")
+		}
+
+		c, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return forms.ListCommentsResponse{}, err
+		}
+
+		op := "<"
+		if query.Order == "asc" {
+			op = ">"
+		}
+		qb.where(fmt.Sprintf("(c.created_at, c.id) %s (%s, %s)", op, qb.arg(c.CreatedAt), qb.arg(c.ID)))
+	}
+
+	// The roots CTE selects the page of top-level comments. We fetch one more
+	// than requested to find out whether there is a next page. ROW_NUMBER
+	// remembers their order, because the threads are returned in that order.
+	//
+	// The thread CTE is recursive: it starts with the roots and then adds the
+	// replies of the comments found so far, one level at a time, until there
+	// are no more replies. This gets whole threads in one query rather than
+	// one query per comment.
+	//
+	// The path is an array of IDs from the root down to the comment. Sorting
+	// by it puts every reply right after its parent, and since IDs increase
+	// over time, replies to the same comment are sorted oldest first.
+	//
+	// For more information on recursive queries, see:
+	// https://www.postgresql.org/docs/current/queries-with.html#QUERIES-WITH-RECURSIVE
+	order := fmt.Sprintf("%s %s, c.id %s", commentSortColumns[query.Sort], strings.ToUpper(query.Order), strings.ToUpper(query.Order))
+	rows, err := m.db.Query(
+		fmt.Sprintf(`WITH RECURSIVE roots AS (
+			SELECT c.id, ROW_NUMBER() OVER (ORDER BY %s) AS position
+			FROM comments AS c
+			%s
+			ORDER BY %s
+			LIMIT %s OFFSET %s
+		),
+		thread AS (
+			SELECT r.id, r.position, 0 AS depth, ARRAY[r.id] AS path
+			FROM roots AS r
+			UNION ALL
+			SELECT c.id, t.position, t.depth + 1, t.path || c.id
+			FROM comments AS c
+			JOIN thread AS t ON c.parent_id = t.id
+			WHERE t.depth < %d AND c.deleted_at IS NULL
+		)
+		SELECT
+			c.id,
+			c.blog_id,
+			c.parent_id,
+			t.depth,
+			array_to_string(t.path, '.'),
+			c.content,
+			c.created_at,
+			c.updated_at,
+			u.id,
+			u.name
+		FROM thread AS t
+		JOIN comments AS c ON c.id = t.id
+		LEFT JOIN users AS u ON u.id = c.author_id
+		ORDER BY t.position, t.path`,
+			order,
+			qb.clause(),
+			order,
+			qb.arg(query.Limit+1), qb.arg(query.Offset),
+			forms.MaxCommentDepth,
+		),
+		qb.args...,
+	)
+	if err != nil {
+		return forms.ListCommentsResponse{}, fmt.Errorf("failed to get comments: %w", err)
+	}
+	defer rows.Close() // Remember to close the rows when you're done with them!
+
+	comments := make([]forms.Comment, 0, query.Limit)
+	for rows.Next() {
+		var comment forms.Comment
+		var parentID sql.NullInt64
+		var author authorColumns
+		if err := rows.Scan(
+			&comment.ID,
+			&comment.BlogID,
+			&parentID,
+			&comment.Depth,
+			&comment.Path,
+			&comment.Content,
+			&comment.CreatedAt,
+			&comment.UpdatedAt,
+			&author.id,
+			&author.name,
+		); err != nil {
+			return forms.ListCommentsResponse{}, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			comment.ParentID = &id
+		}
+		comment.Author = author.author()
+
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return forms.ListCommentsResponse{}, fmt.Errorf("failed to get comments: %w", err)
+	}
+
+	return newCommentsPage(comments, query, total), nil
+}
+
+// newCommentsPage returns the page for a flattened tree of comments that was
+// fetched with one extra thread, as done by listComments.
+func newCommentsPage(comments []forms.Comment, query forms.ListCommentsQuery, total int) forms.ListCommentsResponse {
+	page := forms.ListCommentsResponse{
+		Data: comments,
+		Pagination: forms.Pagination{
+			Limit:  query.Limit,
+			Offset: query.Offset,
+			Total:  total,
+		},
+	}
+
+	// Find the extra thread, which starts at the top-level comment after
+	// the first Limit ones, and remember the last top-level comment before it.
+	roots := 0
+	var last forms.Comment
+	for i, comment := range comments {
+		if comment.Depth != 0 {
+			continue
+		}
+
+		roots++
+		if roots > query.Limit {
+			page.Data = comments[:i]
+			page.Pagination.HasMore = true
+
+			// Cursors are only supported when sorting by created_at.
+			if query.Sort == "created_at" {
+				page.Pagination.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+			}
+			break
+		}
+		last = comment
+	}
+
+	return page
+}
+
+// ReplyToComment inserts a reply to a comment of a blog.
+// The authorID is the ID of the user who writes the reply.
+//
+// It returns an ErrNotFound error if the blog has no comment with the ID
+// parentID or the comment is hidden (see CommentInfo), and an ErrValidation
+// error if the reply would be nested deeper than forms.MaxCommentDepth.
+func (m *BlogModel) ReplyToComment(blogID, parentID, authorID int, comment forms.CreateCommentRequest) error {
+	// commentInfo returns the depth of the parent plus one, which is the
+	// depth of the reply.
+	info, depth, err := m.commentInfo(blogID, parentID)
+	if err != nil {
+		return err
+	}
+	if info.Hidden {
+		return newError(ErrNotFound, "comment %d not found", parentID)
+	}
+	if depth > forms.MaxCommentDepth {
+		return newError(ErrValidation, "replies can be nested at most %d levels deep", forms.MaxCommentDepth)
+	}
+
+	// INSERT ... SELECT copies the blog and the language of the parent to the
+	// reply. If the parent was deleted in the meantime, nothing is inserted.
+	result, err := m.db.Exec(`
+		INSERT INTO comments (blog_id, parent_id, content, language, author_id)
+		SELECT blog_id, id, $2, language, $3
+		FROM comments
+		WHERE id = $1 AND deleted_at IS NULL
+	`, parentID, comment.Content, nullID(authorID))
+	if err != nil {
+		err = translate(err, "failed to create reply")
+
+		// A violation of a foreign key means that the parent or the blog was
+		// deleted in the meantime.
+		if errors.Is(err, ErrConstraint) {
+			return &Error{Kind: ErrNotFound, Message: fmt.Sprintf("comment %d not found", parentID), Err: err}
+		}
+		return err
+	}
+
+	return checkRowsAffected(result, "comment %d not found", parentID)
+}
+
+// GetCommentInfo returns who wrote a comment and whether it is in the trash
+// or hidden. It is used to check whether a user may change the comment.
+func (m *BlogModel) GetCommentInfo(blogID, commentID int) (CommentInfo, error) {
+	info, _, err := m.commentInfo(blogID, commentID)
+	return info, err
+}
+
+// commentInfo returns the CommentInfo of a comment and its depth plus one.
+//
+// It walks up the ancestors of the comment with a recursive query. The
+// number of rows is the depth of the comment plus one, and the comment is
+// hidden if any of the rows, or the blog, is in the trash. No rows means
+// that the comment doesn't exist, or belongs to another blog.
+func (m *BlogModel) commentInfo(blogID, commentID int) (CommentInfo, int, error) {
+	var info CommentInfo
+	var authorID sql.NullInt64
+	var rows int
+	if err := m.db.QueryRow(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, author_id, deleted_at, 0 AS depth
+			FROM comments
+			WHERE id = $1 AND blog_id = $2
+			UNION ALL
+			SELECT c.id, c.parent_id, c.author_id, c.deleted_at, a.depth + 1
+			FROM comments AS c
+			JOIN ancestors AS a ON c.id = a.parent_id
+		)
+		SELECT
+			COUNT(*),
+			MAX(author_id) FILTER (WHERE depth = 0),
+			COALESCE(BOOL_OR(deleted_at IS NOT NULL AND depth = 0), false),
+			COALESCE(BOOL_OR(deleted_at IS NOT NULL), false)
+				OR EXISTS (SELECT 1 FROM blogs WHERE id = $2 AND deleted_at IS NOT NULL)
+		FROM ancestors
+	`, commentID, blogID).Scan(&rows, &authorID, &info.Deleted, &info.Hidden); err != nil {
+		return CommentInfo{}, 0, fmt.Errorf("failed to get comment %d: %w", commentID, err)
+	}
+	if rows == 0 {
+		return CommentInfo{}, 0, newError(ErrNotFound, "comment %d not found", commentID)
+	}
+	info.AuthorID = int(authorID.Int64)
+
+	return info, rows, nil
+}
+
+// UpdateComment updates the content of a comment.
+//
+// The blog ID is part of the WHERE clause, so that a comment can only be
+// changed through the blog it belongs to.
+func (m *BlogModel) UpdateComment(blogID, commentID int, comment forms.UpdateCommentRequest) error {
+	result, err := m.db.Exec(`
+		UPDATE comments
+		SET content = $1
+		WHERE id = $2 AND blog_id = $3 AND deleted_at IS NULL
+	`, comment.Content, commentID, blogID)
+	if err != nil {
+		return translate(err, "failed to update comment %d", commentID)
+	}
+
+	return checkRowsAffected(result, "comment %d not found", commentID)
+}
+
+// DeleteComment moves a comment to the trash. Its replies are left as they
+// are, but they are hidden until the comment is restored.
+func (m *BlogModel) DeleteComment(blogID, commentID int) error {
+	result, err := m.db.Exec(`
+		UPDATE comments
+		SET deleted_at = now()
+		WHERE id = $1 AND blog_id = $2 AND deleted_at IS NULL
+	`, commentID, blogID)
+	if err != nil {
+		return translate(err, "failed to delete comment %d", commentID)
+	}
+
+	return checkRowsAffected(result, "comment %d not found", commentID)
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// The kinds of errors returned by the models.
+//
+// Every error returned by a model that is caused by the request (rather than
+// by the database being unavailable, for example) wraps one of these, so the
+// controllers can check for them with errors.Is:
+//
+//	if errors.Is(err, models.ErrNotFound) { ... }
+//
+// For more information on errors.Is, see:
+// https://golang.org/pkg/errors/#Is
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+	ErrConstraint = errors.New("constraint violation")
+)
+
+// Postgres error codes that we translate into the kinds above.
+//
+// For the full list of error codes, see:
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation      = "23505"
+	pgExclusionViolation   = "23P01"
+	pgForeignKeyViolation  = "23503"
+	pgNotNullViolation     = "23502"
+	pgCheckViolation       = "23514"
+	pgStringDataTruncation = "22001"
+	pgInvalidTextRep       = "22P02"
+)
+
+// Error is an error returned by the models.
+//
+// Kind is one of ErrNotFound, ErrConflict, ErrValidation or ErrConstraint.
+// Message is a short description that is safe to show to clients.
+// Err is the underlying error, if any. It is never shown to clients.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Is reports whether the error is of the given kind, so that
+// errors.Is(err, ErrNotFound) works.
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError returns a new Error of the given kind with a formatted message.
+func newError(kind error, format string, args ...any) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// translate turns database errors into typed errors.
+//
+// sql.ErrNoRows becomes ErrNotFound, and the Postgres integrity constraint
+// errors become ErrConflict, ErrConstraint or ErrValidation. Any other error is
+// wrapped with the message and returned as it is.
+func translate(err error, format string, args ...any) error {
+	message := fmt.Sprintf(format, args...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Message: message, Err: err}
+	}
+
+	// The pgx driver returns a *pgconn.PgError for errors reported by Postgres.
+	// errors.As finds it even if it has been wrapped.
+	//
+	// For more information on errors.As, see:
+	// https://golang.org/pkg/errors/#As
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation, pgExclusionViolation:
+			return &Error{Kind: ErrConflict, Message: message, Err: err}
+		case pgForeignKeyViolation:
+			return &Error{Kind: ErrConstraint, Message: message, Err: err}
+		case pgNotNullViolation, pgCheckViolation, pgStringDataTruncation, pgInvalidTextRep:
+			return &Error{Kind: ErrValidation, Message: message, Err: err}
+		}
+	}
+
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// constraintName returns the name of the constraint that a Postgres error is
+// about, for example "comments_blog_id_fkey", or an empty string.
+func constraintName(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"blog/forms"
+)
+
+// statusTransitions lists, for every status a blog can be moved to, the
+// statuses it can be moved from.
+//
+// Keeping the transitions in one table means that the Postgres and memory
+// models always agree on them.
+var statusTransitions = map[string][]string{
+	forms.StatusScheduled: {forms.StatusDraft, forms.StatusScheduled},
+	forms.StatusPublished: {forms.StatusDraft, forms.StatusScheduled, forms.StatusArchived},
+	forms.StatusDraft:     {forms.StatusScheduled, forms.StatusPublished},
+	forms.StatusArchived:  {forms.StatusPublished},
+}
+
+// checkTransition returns an ErrConflict error if a blog can't be moved from
+// one status to another.
+func checkTransition(id int, from, to string) error {
+	for _, allowed := range statusTransitions[to] {
+		if from == allowed {
+			return nil
+		}
+	}
+	return newError(ErrConflict, "blog %d is %s and can't be changed to %s", id, from, to)
+}
+
+// PublishBlog publishes a draft, scheduled or archived blog.
+func (m *BlogModel) PublishBlog(id int) error {
+	return m.changeStatus(id, forms.StatusPublished)
+}
+
+// UnpublishBlog turns a published or scheduled blog back into a draft.
+func (m *BlogModel) UnpublishBlog(id int) error {
+	return m.changeStatus(id, forms.StatusDraft)
+}
+
+// ArchiveBlog archives a published blog.
+func (m *BlogModel) ArchiveBlog(id int) error {
+	return m.changeStatus(id, forms.StatusArchived)
+}
+
+// changeStatus moves a blog to another status.
+//
+// It returns an ErrNotFound error if the blog doesn't exist, and an
+// ErrConflict error if the blog can't be moved from its current status.
+func (m *BlogModel) changeStatus(id int, status string) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback is a no-op once the transaction is committed.
+
+	// FOR UPDATE locks the row until the transaction ends, so that two
+	// requests can't both change the status based on the same old status.
+	var current string
+	if err := tx.QueryRow(`
+		SELECT status
+		FROM blogs
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, id).Scan(&current); err != nil {
+		return translate(err, "blog %d not found", id)
+	}
+
+	if err := checkTransition(id, current, status); err != nil {
+		return err
+	}
+
+	// published_at is only set the first time a blog is published.
+	// publish_at is only used while a blog is scheduled, and changeStatus
+	// never schedules a blog (ScheduleBlog does), so it is cleared.
+	if _, err := tx.Exec(`
+		UPDATE blogs
+		SET status = $1,
+			published_at = CASE WHEN $1 = 'published' THEN COALESCE(published_at, now()) ELSE published_at END,
+			publish_at = NULL
+		WHERE id = $2
+	`, status, id); err != nil {
+		return translate(err, "failed to change the status of blog %d", id)
+	}
+
+	return tx.Commit()
+}
+
+// ScheduleBlog schedules a draft to be published at publishAt. A scheduled
+// blog can be rescheduled.
+//
+// The blog is published by PublishDueBlogs, which the scheduler calls
+// regularly.
+func (m *BlogModel) ScheduleBlog(id int, publishAt time.Time) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback is a no-op once the transaction is committed.
+
+	var current string
+	if err := tx.QueryRow(`
+		SELECT status
+		FROM blogs
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, id).Scan(&current); err != nil {
+		return translate(err, "blog %d not found", id)
+	}
+
+	if err := checkTransition(id, current, forms.StatusScheduled); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE blogs
+		SET status = 'scheduled', publish_at = $1
+		WHERE id = $2
+	`, publishAt, id); err != nil {
+		return translate(err, "failed to schedule blog %d", id)
+	}
+
+	return tx.Commit()
+}
+
+// PublishDueBlogs publishes up to limit scheduled blogs whose publish_at is
+// not after now, and returns their IDs.
+//
+// Several instances of the service can call it at the same time. FOR UPDATE
+// SKIP LOCKED locks the blogs that one instance is publishing, and makes the
+// other instances skip them rather than wait for them, so every blog is
+// published exactly once.
+//
+// For more information on SKIP LOCKED, see:
+// https://www.postgresql.org/docs/current/sql-select.html#SQL-FOR-UPDATE-SHARE
+func (m *BlogModel) PublishDueBlogs(now time.Time, limit int) ([]int, error) {
+	// The blog is published at the time it was scheduled for, rather than at
+	// the time the scheduler got to it.
+	rows, err := m.db.Query(`
+		UPDATE blogs
+		SET status = 'published',
+			published_at = COALESCE(published_at, publish_at),
+			publish_at = NULL
+		WHERE id IN (
+			SELECT id
+			FROM blogs
+			WHERE status = 'scheduled' AND publish_at <= $1 AND deleted_at IS NULL
+			ORDER BY publish_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish due blogs: %w", err)
+	}
+	defer rows.Close() // Remember to close the rows when you're done with them!
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan blog ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to publish due blogs: %w", err)
+	}
+
+	return ids, nil
+}
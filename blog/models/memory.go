@@ -0,0 +1,592 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"blog/forms"
+	"blog/markdown"
+)
+
+// MemoryBlogModel is an in-memory implementation of BlogStore.
+//
+// It keeps all blogs and comments in maps, so nothing survives a restart.
+// It is useful for demos and for testing the controllers without a database.
+//
+// All methods are safe for concurrent use. The sync.RWMutex allows many
+// readers at the same time, but only one writer.
+//
+// For more information on sync.RWMutex, see:
+// https://golang.org/pkg/sync/#RWMutex
+type MemoryBlogModel struct {
+	mu sync.RWMutex
+
+	blogs     map[int]forms.Blog
+	comments  map[int][]forms.Comment  // Comments keyed by blog ID.
+	revisions map[int][]forms.Revision // Revisions keyed by blog ID, oldest first.
+
+	// deletedBlogs and deletedComments hold the deletion times of the blogs
+	// and comments in the trash, keyed by ID, like the deleted_at columns.
+	deletedBlogs    map[int]time.Time
+	deletedComments map[int]time.Time
+
+	// slugHistory maps the old slugs of blogs to their IDs, like the
+	// blog_slug_history table.
+	slugHistory map[string]int
+
+	// tags and categories hold the tags and categories by ID. blogTags
+	// holds the tag IDs of every blog, and blogCategories the category ID of
+	// the blogs that have one.
+	tags           map[int]forms.Tag
+	categories     map[int]forms.Category
+	blogTags       map[int][]int
+	blogCategories map[int]int
+
+	nextBlogID     int
+	nextCommentID  int
+	nextTagID      int
+	nextCategoryID int
+
+	// users is used to look up the authors of blogs and comments.
+	users *MemoryUserModel
+
+	// now returns the current time. It is a field so that it can be replaced.
+	now func() time.Time
+}
+
+// NewMemoryBlogModel returns a new, empty MemoryBlogModel.
+// It takes the MemoryUserModel that holds the authors as an argument.
+func NewMemoryBlogModel(users *MemoryUserModel) *MemoryBlogModel {
+	return &MemoryBlogModel{
+		users:           users,
+		blogs:           map[int]forms.Blog{},
+		comments:        map[int][]forms.Comment{},
+		revisions:       map[int][]forms.Revision{},
+		deletedBlogs:    map[int]time.Time{},
+		deletedComments: map[int]time.Time{},
+		slugHistory:     map[string]int{},
+		tags:            map[int]forms.Tag{},
+		categories:      map[int]forms.Category{},
+		blogTags:        map[int][]int{},
+		blogCategories:  map[int]int{},
+		nextBlogID:      1,
+		nextCommentID:   1,
+		nextTagID:       1,
+		nextCategoryID:  1,
+		now:             time.Now,
+	}
+}
+
+// CreateBlog adds a new blog.
+func (m *MemoryBlogModel) CreateBlog(authorID int, blog forms.CreateBlogRequest) error {
+	names, slugs, err := tagNames(blog.Tags)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkBlogCategory(blog.CategoryID); err != nil {
+		return err
+	}
+
+	language := blog.Language
+	if language == "" {
+		language = forms.DefaultLanguage
+	}
+
+	now := m.now()
+	created := forms.Blog{
+		ID:          m.nextBlogID,
+		Slug:        m.uniqueSlug(blog.Title, 0),
+		Title:       blog.Title,
+		Content:     blog.Content,
+		ContentHTML: markdown.Render(blog.Content),
+		Language:    language,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Author:      m.users.author(authorID),
+		Status:      forms.StatusDraft,
+	}
+	if blog.Status == forms.StatusPublished {
+		created.Status = forms.StatusPublished
+		created.PublishedAt = &now
+	}
+	m.blogs[m.nextBlogID] = created
+	m.addRevision(created, authorID, 0)
+	m.setBlogTags(created.ID, names, slugs)
+	m.setBlogCategory(created.ID, blog.CategoryID)
+	m.nextBlogID++
+
+	return nil
+}
+
+// GetAllBlogs returns a page of blogs, sorted and filtered in the same way
+// as BlogModel.GetAllBlogs.
+func (m *MemoryBlogModel) GetAllBlogs(query forms.ListBlogsQuery) (forms.ListBlogsResponse, error) {
+	query.Normalize()
+
+	var c cursor
+	if query.Cursor != "" {
+		if query.Sort != "created_at" {
+			return forms.ListBlogsResponse{}, newError(ErrValidation, "a cursor can only be used when sorting by created_at")
+		}
+		if query.Offset != 0 {
+			return forms.ListBlogsResponse{}, newError(ErrValidation, "a cursor can't be used together with an offset")
+		}
+
+		var err error
+		if c, err = decodeCursor(query.Cursor); err != nil {
+			return forms.ListBlogsResponse{}, err
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var subtree map[int]bool
+	if query.Category != "" {
+		subtree = m.categorySubtree(query.Category)
+	}
+
+	blogs := make([]forms.GetAllBlogsResponse, 0, len(m.blogs))
+	for _, blog := range m.blogs {
+		if _, deleted := m.deletedBlogs[blog.ID]; deleted || !matchesBlogFilters(blog, query) {
+			continue
+		}
+		if query.Tag != "" && !m.hasTag(blog.ID, query.Tag) {
+			continue
+		}
+		if query.Category != "" && !subtree[m.blogCategories[blog.ID]] {
+			continue
+		}
+
+		content, contentHTML := blog.Content, blog.ContentHTML
+		if !query.FullContent {
+			content = preview(blog.Content)
+			contentHTML = markdown.Render(content)
+		}
+		blogs = append(blogs, forms.GetAllBlogsResponse{
+			ID:          blog.ID,
+			Slug:        blog.Slug,
+			Title:       blog.Title,
+			Content:     content,
+			ContentHTML: contentHTML,
+			CreatedAt:   blog.CreatedAt,
+			UpdatedAt:   blog.UpdatedAt,
+			Comments:    m.countComments(blog.ID),
+			Author:      blog.Author,
+
+			Status:      blog.Status,
+			PublishedAt: blog.PublishedAt,
+			PublishAt:   blog.PublishAt,
+
+			Category: m.blogCategory(blog.ID),
+			Tags:     m.blogTagList(blog.ID),
+		})
+	}
+	total := len(blogs)
+
+	sortBlogs(blogs, query.Sort, query.Order)
+
+	// Skip the blogs up to and including the cursor.
+	if query.Cursor != "" {
+		i := 0
+		for i < len(blogs) && !c.after(blogs[i].CreatedAt, blogs[i].ID, query.Order) {
+			i++
+		}
+		blogs = blogs[i:]
+	}
+
+	// Apply the offset and keep one extra blog, like the SQL query does.
+	if query.Offset >= len(blogs) {
+		blogs = blogs[:0]
+	} else {
+		blogs = blogs[query.Offset:]
+	}
+	if len(blogs) > query.Limit+1 {
+		blogs = blogs[:query.Limit+1]
+	}
+
+	return newBlogsPage(blogs, query, total), nil
+}
+
+// matchesBlogFilters reports whether a blog matches the filters of a query,
+// and whether the viewer may see it, like addBlogFilters.
+func matchesBlogFilters(blog forms.Blog, query forms.ListBlogsQuery) bool {
+	switch {
+	case blog.Status != query.Status:
+		return false
+	case query.Status != forms.StatusPublished && !query.ViewAll && (blog.Author == nil || blog.Author.ID != query.ViewerID):
+		return false
+	case !query.CreatedAfter.IsZero() && blog.CreatedAt.Before(query.CreatedAfter):
+		return false
+	case !query.CreatedBefore.IsZero() && !blog.CreatedAt.Before(query.CreatedBefore):
+		return false
+	case !query.UpdatedAfter.IsZero() && blog.UpdatedAt.Before(query.UpdatedAfter):
+		return false
+	case !query.UpdatedBefore.IsZero() && !blog.UpdatedAt.Before(query.UpdatedBefore):
+		return false
+	case query.Title != "" && !strings.Contains(strings.ToLower(blog.Title), strings.ToLower(query.Title)):
+		return false
+	}
+	return true
+}
+
+// sortBlogs sorts blogs by the given column and order, with the ID as the
+// tie-breaker, in the same way as the ORDER BY of BlogModel.GetAllBlogs.
+func sortBlogs(blogs []forms.GetAllBlogsResponse, column, order string) {
+	// compare returns a negative number if a comes before b in ascending order.
+	compare := func(a, b forms.GetAllBlogsResponse) int {
+		switch column {
+		case "updated_at":
+			return a.UpdatedAt.Compare(b.UpdatedAt)
+		case "published_at":
+			return publishedOrCreated(a).Compare(publishedOrCreated(b))
+		case "title":
+			return strings.Compare(a.Title, b.Title)
+		case "comments":
+			return a.Comments - b.Comments
+		default:
+			return a.CreatedAt.Compare(b.CreatedAt)
+		}
+	}
+
+	sort.Slice(blogs, func(i, j int) bool {
+		c := compare(blogs[i], blogs[j])
+		if c == 0 {
+			c = blogs[i].ID - blogs[j].ID
+		}
+		if order == "asc" {
+			return c < 0
+		}
+		return c > 0
+	})
+}
+
+// publishedOrCreated returns when a blog was published, or when it was
+// created if it has never been published, like the published_at sort
+// column of BlogModel.
+func publishedOrCreated(blog forms.GetAllBlogsResponse) time.Time {
+	if blog.PublishedAt != nil {
+		return *blog.PublishedAt
+	}
+	return blog.CreatedAt
+}
+
+// GetBlogByID returns a single blog and its comments.
+func (m *MemoryBlogModel) GetBlogByID(id int) (forms.GetBlogByIDResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	blog, ok := m.liveBlog(id)
+	if !ok {
+		return forms.GetBlogByIDResponse{}, newError(ErrNotFound, "blog %d not found", id)
+	}
+	blog.Category = m.blogCategory(id)
+	blog.Tags = m.blogTagList(id)
+
+	// Get the first page of comments, like BlogModel.GetBlogByID.
+	comments, err := m.listComments(id, forms.ListCommentsQuery{})
+	if err != nil {
+		return forms.GetBlogByIDResponse{}, err
+	}
+
+	return forms.GetBlogByIDResponse{
+		Blog:               blog,
+		Comments:           comments.Data,
+		CommentsPagination: comments.Pagination,
+	}, nil
+}
+
+// GetBlogInfo returns who wrote a blog and its status.
+func (m *MemoryBlogModel) GetBlogInfo(id int) (BlogInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	blog, ok := m.blogs[id]
+	if !ok {
+		return BlogInfo{}, newError(ErrNotFound, "blog %d not found", id)
+	}
+
+	_, deleted := m.deletedBlogs[id]
+	info := BlogInfo{Status: blog.Status, Deleted: deleted}
+	if blog.Author != nil {
+		info.AuthorID = blog.Author.ID
+	}
+
+	return info, nil
+}
+
+// UpdateBlog updates the title and content of a blog and saves the new
+// version as a revision.
+func (m *MemoryBlogModel) UpdateBlog(id, editorID int, blog forms.UpdateBlogRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateBlog(id, editorID, blog, 0)
+}
+
+// updateBlog updates a blog and saves the new version as a revision.
+// The caller must hold the write lock.
+func (m *MemoryBlogModel) updateBlog(id, editorID int, blog forms.UpdateBlogRequest, restoredFrom int) error {
+	existing, ok := m.liveBlog(id)
+	if !ok {
+		return newError(ErrNotFound, "blog %d not found", id)
+	}
+
+	// Check the tags and the category before changing anything.
+	names, slugs, err := tagNames(blog.Tags)
+	if err != nil {
+		return err
+	}
+	if err := m.checkBlogCategory(blog.CategoryID); err != nil {
+		return err
+	}
+
+	m.updateSlug(&existing, blog.Title)
+	existing.Title = blog.Title
+	existing.Content = blog.Content
+	existing.ContentHTML = markdown.Render(blog.Content)
+	if blog.Language != "" {
+		existing.Language = blog.Language
+	}
+	existing.UpdatedAt = m.now()
+	m.blogs[id] = existing
+	m.addRevision(existing, editorID, restoredFrom)
+	if blog.Tags != nil {
+		m.setBlogTags(id, names, slugs)
+	}
+	m.setBlogCategory(id, blog.CategoryID)
+
+	return nil
+}
+
+// DeleteBlog moves a blog to the trash. Its comments stay where they are
+// and come back when the blog is restored.
+func (m *MemoryBlogModel) DeleteBlog(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.liveBlog(id); !ok {
+		return newError(ErrNotFound, "blog %d not found", id)
+	}
+
+	m.deletedBlogs[id] = m.now()
+
+	return nil
+}
+
+// liveBlog returns a blog that exists and isn't in the trash. The caller
+// must hold the lock.
+func (m *MemoryBlogModel) liveBlog(id int) (forms.Blog, bool) {
+	blog, ok := m.blogs[id]
+	if !ok {
+		return forms.Blog{}, false
+	}
+	if _, deleted := m.deletedBlogs[id]; deleted {
+		return forms.Blog{}, false
+	}
+	return blog, true
+}
+
+// countComments returns the number of comments of a blog that aren't in the
+// trash, like the COUNT in BlogModel.GetAllBlogs. The caller must hold the
+// lock.
+func (m *MemoryBlogModel) countComments(blogID int) int {
+	n := 0
+	for _, comment := range m.comments[blogID] {
+		if _, deleted := m.deletedComments[comment.ID]; !deleted {
+			n++
+		}
+	}
+	return n
+}
+
+// CreateComment adds a new comment to a blog.
+func (m *MemoryBlogModel) CreateComment(blogID, authorID int, comment forms.CreateCommentRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.liveBlog(blogID); !ok {
+		return newError(ErrNotFound, "blog %d not found", blogID)
+	}
+
+	now := m.now()
+	m.comments[blogID] = append(m.comments[blogID], forms.Comment{
+		ID:        m.nextCommentID,
+		BlogID:    blogID,
+		Path:      strconv.Itoa(m.nextCommentID),
+		Content:   comment.Content,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Author:    m.users.author(authorID),
+	})
+	m.nextCommentID++
+
+	return nil
+}
+
+// preview shortens content in the same way as the SUBSTRING in
+// BlogModel.GetAllBlogs, counting characters rather than bytes.
+func preview(content string) string {
+	runes := []rune(content)
+	if len(runes) <= previewLength {
+		return content
+	}
+	return string(runes[:previewLength])
+}
+
+// Search returns the blogs whose title, content or comments contain all the
+// words of the query, best matches first.
+//
+// Unlike BlogModel.Search it doesn't stem words, so "running" doesn't match
+// "run". Words starting with "-" must not appear.
+func (m *MemoryBlogModel) Search(query forms.SearchQuery) (forms.SearchResponse, error) {
+	query.Normalize()
+
+	var include, exclude []string
+	for _, word := range strings.Fields(strings.ToLower(query.Q)) {
+		if strings.HasPrefix(word, "-") {
+			if w := strings.Trim(word[1:], `"`); w != "" {
+				exclude = append(exclude, w)
+			}
+		} else if w := strings.Trim(word, `"`); w != "" && w != "or" {
+			include = append(include, w)
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := []forms.SearchResult{}
+	for _, blog := range m.blogs {
+		// Only published blogs are searched, like in BlogModel.Search.
+		if _, deleted := m.deletedBlogs[blog.ID]; deleted || blog.Status != forms.StatusPublished {
+			continue
+		}
+
+		result := forms.SearchResult{
+			ID:             blog.ID,
+			Title:          blog.Title,
+			TitleHighlight: memoryHighlight(blog.Title, include, false),
+			CreatedAt:      blog.CreatedAt,
+		}
+
+		// Words in the title count twice as much as words in the content.
+		text := blog.Title + " " + blog.Content
+		if matchesWords(text, include, exclude) {
+			result.Rank = float64(2*countWords(blog.Title, include) + countWords(blog.Content, include))
+			result.Snippet = memoryHighlight(blog.Content, include, true)
+		}
+
+		// Comment matches count for half as much as blog matches.
+		for _, comment := range m.comments[blog.ID] {
+			if _, deleted := m.deletedComments[comment.ID]; deleted || !matchesWords(comment.Content, include, exclude) {
+				continue
+			}
+			result.MatchingComments++
+			result.Rank += float64(countWords(comment.Content, include)) / 2
+			if result.Snippet == "" {
+				result.Snippet = memoryHighlight(comment.Content, include, true)
+			}
+		}
+
+		if result.Snippet != "" {
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ID > results[j].ID
+	})
+
+	total := len(results)
+	if query.Offset >= len(results) {
+		results = results[:0]
+	} else {
+		results = results[query.Offset:]
+	}
+	if len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+
+	return newSearchPage(results, query, total), nil
+}
+
+// matchesWords reports whether text contains all the include words and none
+// of the exclude words, ignoring case.
+func matchesWords(text string, include, exclude []string) bool {
+	if len(include) == 0 {
+		return false
+	}
+
+	text = strings.ToLower(text)
+	for _, w := range include {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	for _, w := range exclude {
+		if strings.Contains(text, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// countWords returns how often the words occur in text, ignoring case.
+func countWords(text string, words []string) int {
+	text = strings.ToLower(text)
+	n := 0
+	for _, w := range words {
+		n += strings.Count(text, w)
+	}
+	return n
+}
+
+// memorySnippetWords is the number of words around the first match that
+// memoryHighlight keeps when it shortens a text.
+const memorySnippetWords = 30
+
+// memoryHighlight marks the words in text in the same way as ts_headline
+// does in BlogModel.Search. If shorten is true, only the words around the
+// first match are kept.
+func memoryHighlight(text string, words []string, shorten bool) string {
+	fields := strings.Fields(text)
+
+	first := -1
+	for i, field := range fields {
+		lower := strings.ToLower(field)
+		for _, w := range words {
+			if strings.Contains(lower, w) {
+				fields[i] = markStart + field + markStop
+				if first < 0 {
+					first = i
+				}
+				break
+			}
+		}
+	}
+
+	if shorten && len(fields) > memorySnippetWords {
+		start := first - memorySnippetWords/2
+		if start < 0 {
+			start = 0
+		}
+		end := start + memorySnippetWords
+		if end > len(fields) {
+			end = len(fields)
+		}
+		fields = fields[start:end]
+	}
+
+	return highlight(strings.Join(fields, " "))
+}
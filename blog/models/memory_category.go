@@ -0,0 +1,228 @@
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"blog/forms"
+)
+
+// ListCategories returns all the categories as a flattened tree.
+func (m *MemoryBlogModel) ListCategories() (forms.ListCategoriesResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return forms.ListCategoriesResponse{Data: m.categoryTree()}, nil
+}
+
+// GetCategory returns a single category.
+func (m *MemoryBlogModel) GetCategory(id int) (forms.Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.getCategory(id)
+}
+
+// getCategory returns a single category with its depth and path. The caller
+// must hold the lock.
+func (m *MemoryBlogModel) getCategory(id int) (forms.Category, error) {
+	for _, category := range m.categoryTree() {
+		if category.ID == id {
+			return category, nil
+		}
+	}
+	return forms.Category{}, newError(ErrNotFound, "category %d not found", id)
+}
+
+// CreateCategory creates a category and returns it. It returns an
+// ErrConflict error if a category with the same slug exists, and an
+// ErrConstraint error if the parent doesn't exist.
+func (m *MemoryBlogModel) CreateCategory(req forms.CategoryRequest) (forms.Category, error) {
+	name, s, err := categoryName(req.Name)
+	if err != nil {
+		return forms.Category{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkCategory(0, s, req.ParentID); err != nil {
+		return forms.Category{}, err
+	}
+
+	category := forms.Category{
+		ID:        m.nextCategoryID,
+		Name:      name,
+		Slug:      s,
+		ParentID:  req.ParentID,
+		CreatedAt: m.now(),
+	}
+	m.categories[category.ID] = category
+	m.nextCategoryID++
+
+	return m.getCategory(category.ID)
+}
+
+// UpdateCategory renames a category and moves it below another parent, and
+// returns it. It returns an ErrValidation error if the new parent is the
+// category itself or one of its subcategories.
+func (m *MemoryBlogModel) UpdateCategory(id int, req forms.CategoryRequest) (forms.Category, error) {
+	name, s, err := categoryName(req.Name)
+	if err != nil {
+		return forms.Category{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	category, ok := m.categories[id]
+	if !ok {
+		return forms.Category{}, newError(ErrNotFound, "category %d not found", id)
+	}
+	if err := m.checkCategory(id, s, req.ParentID); err != nil {
+		return forms.Category{}, err
+	}
+
+	// Walk up from the new parent. If we meet the category, the parent is
+	// below it.
+	for ancestor := req.ParentID; ancestor != nil; ancestor = m.categories[*ancestor].ParentID {
+		if *ancestor == id {
+			return forms.Category{}, newError(ErrValidation, "category %d can't be moved below itself", id)
+		}
+	}
+
+	category.Name = name
+	category.Slug = s
+	category.ParentID = req.ParentID
+	m.categories[id] = category
+
+	return m.getCategory(id)
+}
+
+// DeleteCategory deletes a category. Its blogs are left without a category.
+// It returns an ErrConflict error if the category has subcategories.
+func (m *MemoryBlogModel) DeleteCategory(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.categories[id]; !ok {
+		return newError(ErrNotFound, "category %d not found", id)
+	}
+	for _, category := range m.categories {
+		if category.ParentID != nil && *category.ParentID == id {
+			return newError(ErrConflict, "category %d has subcategories", id)
+		}
+	}
+
+	delete(m.categories, id)
+	for blogID, categoryID := range m.blogCategories {
+		if categoryID == id {
+			delete(m.blogCategories, blogID)
+		}
+	}
+
+	return nil
+}
+
+// checkCategory checks that no other category than the one with the ID id
+// has the slug s, and that the parent exists, like the constraints of the
+// categories table. The caller must hold the lock.
+func (m *MemoryBlogModel) checkCategory(id int, s string, parentID *int) error {
+	for _, category := range m.categories {
+		if category.Slug == s && category.ID != id {
+			return newError(ErrConflict, "a category with the slug %q already exists", s)
+		}
+	}
+	if parentID != nil {
+		if _, ok := m.categories[*parentID]; !ok {
+			return newError(ErrConstraint, "category %d not found", *parentID)
+		}
+	}
+	return nil
+}
+
+// categoryTree returns all the categories with their depth and path, sorted
+// in the same way as BlogModel.ListCategories. The caller must hold the
+// lock.
+func (m *MemoryBlogModel) categoryTree() []forms.Category {
+	children := map[int][]forms.Category{}
+	for _, category := range m.categories {
+		parentID := 0
+		if category.ParentID != nil {
+			parentID = *category.ParentID
+		}
+		children[parentID] = append(children[parentID], category)
+	}
+
+	tree := []forms.Category{}
+	var walk func(parentID, depth int, path string)
+	walk = func(parentID, depth int, path string) {
+		level := children[parentID]
+		sort.Slice(level, func(i, j int) bool {
+			return strings.Compare(level[i].Slug, level[j].Slug) < 0
+		})
+		for _, category := range level {
+			category.Depth = depth
+			category.Path = path + category.Slug
+			tree = append(tree, category)
+			walk(category.ID, depth+1, category.Path+"/")
+		}
+	}
+	walk(0, 0, "")
+
+	return tree
+}
+
+// categorySubtree returns the IDs of the category with the given slug and
+// of all its subcategories. The caller must hold the lock.
+func (m *MemoryBlogModel) categorySubtree(s string) map[int]bool {
+	subtree := map[int]bool{}
+	var path string
+	for _, category := range m.categoryTree() {
+		if category.Slug == s {
+			path = category.Path
+		}
+		if path != "" && (category.Path == path || strings.HasPrefix(category.Path, path+"/")) {
+			subtree[category.ID] = true
+		}
+	}
+	return subtree
+}
+
+// blogCategory returns the category of a blog, or nil if it has none. The
+// caller must hold the lock.
+func (m *MemoryBlogModel) blogCategory(blogID int) *forms.BlogCategory {
+	categoryID, ok := m.blogCategories[blogID]
+	if !ok {
+		return nil
+	}
+
+	category := m.categories[categoryID]
+	return &forms.BlogCategory{ID: category.ID, Name: category.Name, Slug: category.Slug}
+}
+
+// checkBlogCategory returns an ErrConstraint error if the category that a
+// blog is moved to doesn't exist, like the foreign key of the category_id
+// column. A nil or 0 ID is always valid. The caller must hold the lock.
+func (m *MemoryBlogModel) checkBlogCategory(categoryID *int) error {
+	if categoryID == nil || *categoryID == 0 {
+		return nil
+	}
+	if _, ok := m.categories[*categoryID]; !ok {
+		return newError(ErrConstraint, "category %d not found", *categoryID)
+	}
+	return nil
+}
+
+// setBlogCategory moves a blog to a category, or out of its category if the
+// ID is 0. The caller must hold the write lock.
+func (m *MemoryBlogModel) setBlogCategory(blogID int, categoryID *int) {
+	if categoryID == nil {
+		return
+	}
+	if *categoryID == 0 {
+		delete(m.blogCategories, blogID)
+		return
+	}
+	m.blogCategories[blogID] = *categoryID
+}
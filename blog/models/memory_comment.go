@@ -0,0 +1,245 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"blog/forms"
+)
+
+// ListComments returns a page of the comments of a blog, sorted in the same
+// way as BlogModel.ListComments.
+func (m *MemoryBlogModel) ListComments(blogID int, query forms.ListCommentsQuery) (forms.ListCommentsResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.liveBlog(blogID); !ok {
+		return forms.ListCommentsResponse{}, newError(ErrNotFound, "blog %d not found", blogID)
+	}
+
+	return m.listComments(blogID, query)
+}
+
+// listComments returns a page of the comments of a blog, without checking
+// whether the blog exists. The caller must hold the lock.
+func (m *MemoryBlogModel) listComments(blogID int, query forms.ListCommentsQuery) (forms.ListCommentsResponse, error) {
+	query.Normalize()
+
+	var c cursor
+	if query.Cursor != "" {
+		if query.Sort != "created_at" {
+			return forms.ListCommentsResponse{}, newError(ErrValidation, "a cursor can only be used when sorting by created_at")
+		}
+		if query.Offset != 0 {
+			return forms.ListCommentsResponse{}, newError(ErrValidation, "a cursor can't be used together with an offset")
+		}
+
+		var err error
+		if c, err = decodeCursor(query.Cursor); err != nil {
+			return forms.ListCommentsResponse{}, err
+		}
+	}
+
+	// Split the comments into top-level comments and replies. The replies
+	// are kept in the order they were added, which is oldest first.
+	//
+	// Comments in the trash are left out. Their replies are left out too,
+	// because addThread below never reaches them.
+	var roots []forms.Comment
+	replies := map[int][]forms.Comment{}
+	for _, comment := range m.comments[blogID] {
+		if _, deleted := m.deletedComments[comment.ID]; deleted {
+			continue
+		}
+		if comment.ParentID == nil {
+			roots = append(roots, comment)
+		} else {
+			replies[*comment.ParentID] = append(replies[*comment.ParentID], comment)
+		}
+	}
+	total := len(roots)
+
+	sortComments(roots, query.Sort, query.Order)
+
+	// Skip the top-level comments up to and including the cursor.
+	if query.Cursor != "" {
+		i := 0
+		for i < len(roots) && !c.after(roots[i].CreatedAt, roots[i].ID, query.Order) {
+			i++
+		}
+		roots = roots[i:]
+	}
+
+	// Apply the offset and keep one extra top-level comment, like the SQL
+	// query does.
+	if query.Offset >= len(roots) {
+		roots = roots[:0]
+	} else {
+		roots = roots[query.Offset:]
+	}
+	if len(roots) > query.Limit+1 {
+		roots = roots[:query.Limit+1]
+	}
+
+	// Add the replies after each top-level comment, depth first, in the same
+	// order as the recursive query of BlogModel.listComments.
+	comments := make([]forms.Comment, 0, len(roots))
+	var addThread func(comment forms.Comment)
+	addThread = func(comment forms.Comment) {
+		comments = append(comments, comment)
+		for _, reply := range replies[comment.ID] {
+			addThread(reply)
+		}
+	}
+	for _, root := range roots {
+		addThread(root)
+	}
+
+	return newCommentsPage(comments, query, total), nil
+}
+
+// sortComments sorts comments by the given column and order, with the ID as
+// the tie-breaker, in the same way as the ORDER BY of BlogModel.ListComments.
+func sortComments(comments []forms.Comment, column, order string) {
+	sort.Slice(comments, func(i, j int) bool {
+		a, b := comments[i], comments[j]
+
+		var c int
+		if column == "updated_at" {
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		} else {
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if c == 0 {
+			c = a.ID - b.ID
+		}
+
+		if order == "asc" {
+			return c < 0
+		}
+		return c > 0
+	})
+}
+
+// findComment returns the index of a comment in the comments of a blog.
+// It returns an ErrNotFound error if there is no such comment, or if it is
+// hidden (see commentHidden). The caller must hold the lock.
+func (m *MemoryBlogModel) findComment(blogID, commentID int) (int, error) {
+	i, ok := m.findAnyComment(blogID, commentID)
+	if !ok || m.commentHidden(m.comments[blogID][i]) {
+		return 0, newError(ErrNotFound, "comment %d not found", commentID)
+	}
+	return i, nil
+}
+
+// findAnyComment returns the index of a comment in the comments of a blog,
+// including comments in the trash. The caller must hold the lock.
+func (m *MemoryBlogModel) findAnyComment(blogID, commentID int) (int, bool) {
+	for i, comment := range m.comments[blogID] {
+		if comment.ID == commentID {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// commentHidden reports whether a comment can't be seen because its blog,
+// the comment itself or one of the comments it replies to is in the trash.
+// The caller must hold the lock.
+func (m *MemoryBlogModel) commentHidden(comment forms.Comment) bool {
+	if _, deleted := m.deletedBlogs[comment.BlogID]; deleted {
+		return true
+	}
+
+	// The path holds the IDs of the comment and all its ancestors.
+	for _, id := range strings.Split(comment.Path, ".") {
+		id, _ := strconv.Atoi(id)
+		if _, deleted := m.deletedComments[id]; deleted {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplyToComment adds a reply to a comment of a blog.
+func (m *MemoryBlogModel) ReplyToComment(blogID, parentID, authorID int, comment forms.CreateCommentRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, err := m.findComment(blogID, parentID)
+	if err != nil {
+		return err
+	}
+	parent := m.comments[blogID][i]
+
+	if parent.Depth >= forms.MaxCommentDepth {
+		return newError(ErrValidation, "replies can be nested at most %d levels deep", forms.MaxCommentDepth)
+	}
+
+	now := m.now()
+	m.comments[blogID] = append(m.comments[blogID], forms.Comment{
+		ID:        m.nextCommentID,
+		BlogID:    blogID,
+		ParentID:  &parent.ID,
+		Depth:     parent.Depth + 1,
+		Path:      parent.Path + "." + strconv.Itoa(m.nextCommentID),
+		Content:   comment.Content,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Author:    m.users.author(authorID),
+	})
+	m.nextCommentID++
+
+	return nil
+}
+
+// GetCommentInfo returns who wrote a comment and whether it is hidden.
+func (m *MemoryBlogModel) GetCommentInfo(blogID, commentID int) (CommentInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i, ok := m.findAnyComment(blogID, commentID)
+	if !ok {
+		return CommentInfo{}, newError(ErrNotFound, "comment %d not found", commentID)
+	}
+	comment := m.comments[blogID][i]
+
+	_, deleted := m.deletedComments[commentID]
+	info := CommentInfo{Deleted: deleted, Hidden: m.commentHidden(comment)}
+	if comment.Author != nil {
+		info.AuthorID = comment.Author.ID
+	}
+	return info, nil
+}
+
+// UpdateComment updates the content of a comment.
+func (m *MemoryBlogModel) UpdateComment(blogID, commentID int, comment forms.UpdateCommentRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, err := m.findComment(blogID, commentID)
+	if err != nil {
+		return err
+	}
+
+	m.comments[blogID][i].Content = comment.Content
+	m.comments[blogID][i].UpdatedAt = m.now()
+
+	return nil
+}
+
+// DeleteComment moves a comment to the trash. Its replies are hidden until
+// the comment is restored.
+func (m *MemoryBlogModel) DeleteComment(blogID, commentID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.findComment(blogID, commentID); err != nil {
+		return err
+	}
+
+	m.deletedComments[commentID] = m.now()
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"blog/forms"
+)
+
+// PublishBlog publishes a draft, scheduled or archived blog.
+func (m *MemoryBlogModel) PublishBlog(id int) error {
+	return m.changeStatus(id, forms.StatusPublished)
+}
+
+// UnpublishBlog turns a published or scheduled blog back into a draft.
+func (m *MemoryBlogModel) UnpublishBlog(id int) error {
+	return m.changeStatus(id, forms.StatusDraft)
+}
+
+// ArchiveBlog archives a published blog.
+func (m *MemoryBlogModel) ArchiveBlog(id int) error {
+	return m.changeStatus(id, forms.StatusArchived)
+}
+
+// changeStatus moves a blog to another status, with the same checks as
+// BlogModel.changeStatus.
+func (m *MemoryBlogModel) changeStatus(id int, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	blog, ok := m.liveBlog(id)
+	if !ok {
+		return newError(ErrNotFound, "blog %d not found", id)
+	}
+
+	if err := checkTransition(id, blog.Status, status); err != nil {
+		return err
+	}
+
+	now := m.now()
+	blog.Status = status
+	if status == forms.StatusPublished && blog.PublishedAt == nil {
+		blog.PublishedAt = &now
+	}
+	blog.PublishAt = nil
+	blog.UpdatedAt = now
+	m.blogs[id] = blog
+
+	return nil
+}
+
+// ScheduleBlog schedules a draft to be published at publishAt.
+func (m *MemoryBlogModel) ScheduleBlog(id int, publishAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	blog, ok := m.liveBlog(id)
+	if !ok {
+		return newError(ErrNotFound, "blog %d not found", id)
+	}
+
+	if err := checkTransition(id, blog.Status, forms.StatusScheduled); err != nil {
+		return err
+	}
+
+	blog.Status = forms.StatusScheduled
+	blog.PublishAt = &publishAt
+	blog.UpdatedAt = m.now()
+	m.blogs[id] = blog
+
+	return nil
+}
+
+// PublishDueBlogs publishes up to limit scheduled blogs whose publish_at is
+// not after now, earliest first, and returns their IDs.
+func (m *MemoryBlogModel) PublishDueBlogs(now time.Time, limit int) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []forms.Blog
+	for _, blog := range m.blogs {
+		if _, deleted := m.deletedBlogs[blog.ID]; deleted {
+			continue
+		}
+		if blog.Status == forms.StatusScheduled && !blog.PublishAt.After(now) {
+			due = append(due, blog)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].PublishAt.Before(*due[j].PublishAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	ids := make([]int, 0, len(due))
+	for _, blog := range due {
+		if blog.PublishedAt == nil {
+			blog.PublishedAt = blog.PublishAt
+		}
+		blog.Status = forms.StatusPublished
+		blog.PublishAt = nil
+		blog.UpdatedAt = m.now()
+		m.blogs[blog.ID] = blog
+
+		ids = append(ids, blog.ID)
+	}
+
+	return ids, nil
+}
+
+// RenderStaleHTML does nothing and returns 0. The HTML of blogs in memory is
+// rendered when they are written, by the markdown package of the running
+// program, so it is never stale.
+func (m *MemoryBlogModel) RenderStaleHTML(limit int) (int, error) {
+	return 0, nil
+}
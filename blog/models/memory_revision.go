@@ -0,0 +1,83 @@
+package models
+
+import (
+	"blog/forms"
+)
+
+// ListRevisions returns a page of the revisions of a blog, newest first.
+func (m *MemoryBlogModel) ListRevisions(blogID int, query forms.ListRevisionsQuery) (forms.ListRevisionsResponse, error) {
+	query.Normalize()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	all, ok := m.revisions[blogID]
+	if !ok {
+		return forms.ListRevisionsResponse{}, newError(ErrNotFound, "blog %d not found", blogID)
+	}
+
+	// The revisions are stored oldest first, so walk them backwards.
+	revisions := []forms.Revision{}
+	for i := len(all) - 1 - query.Offset; i >= 0 && len(revisions) < query.Limit; i-- {
+		revisions = append(revisions, all[i])
+	}
+
+	return newRevisionsPage(revisions, query, len(all)), nil
+}
+
+// GetRevision returns a single revision of a blog.
+func (m *MemoryBlogModel) GetRevision(blogID, number int) (forms.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.getRevision(blogID, number)
+}
+
+// getRevision returns a single revision of a blog. The caller must hold the
+// lock.
+func (m *MemoryBlogModel) getRevision(blogID, number int) (forms.Revision, error) {
+	revisions := m.revisions[blogID]
+	// Revisions are numbered from 1 without gaps, so the number is the index
+	// plus one.
+	if number < 1 || number > len(revisions) {
+		return forms.Revision{}, newError(ErrNotFound, "revision %d of blog %d not found", number, blogID)
+	}
+	return revisions[number-1], nil
+}
+
+// RestoreRevision makes an old revision of a blog the current version, and
+// saves it as a new revision.
+func (m *MemoryBlogModel) RestoreRevision(blogID, number, editorID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revision, err := m.getRevision(blogID, number)
+	if err != nil {
+		return err
+	}
+
+	return m.updateBlog(blogID, editorID, forms.UpdateBlogRequest{
+		Title:    revision.Title,
+		Content:  revision.Content,
+		Language: revision.Language,
+	}, number)
+}
+
+// addRevision saves the current version of a blog as its next revision.
+// The caller must hold the write lock.
+func (m *MemoryBlogModel) addRevision(blog forms.Blog, editorID, restoredFrom int) {
+	revision := forms.Revision{
+		Number:    len(m.revisions[blog.ID]) + 1,
+		BlogID:    blog.ID,
+		Title:     blog.Title,
+		Content:   blog.Content,
+		Language:  blog.Language,
+		Editor:    m.users.author(editorID),
+		CreatedAt: blog.UpdatedAt,
+	}
+	if restoredFrom > 0 {
+		revision.RestoredFrom = &restoredFrom
+	}
+
+	m.revisions[blog.ID] = append(m.revisions[blog.ID], revision)
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"blog/forms"
+	"blog/slug"
+)
+
+// GetBlogIDBySlug returns the ID and the current slug of the blog with the
+// given slug, in the same way as BlogModel.GetBlogIDBySlug.
+func (m *MemoryBlogModel) GetBlogIDBySlug(s string) (int, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for id, blog := range m.blogs {
+		if blog.Slug != s {
+			continue
+		}
+		if _, ok := m.liveBlog(id); ok {
+			return id, blog.Slug, nil
+		}
+	}
+
+	if id, ok := m.slugHistory[s]; ok {
+		if blog, ok := m.liveBlog(id); ok {
+			return id, blog.Slug, nil
+		}
+	}
+
+	return 0, "", newError(ErrNotFound, "blog %q not found", s)
+}
+
+// uniqueSlug returns a slug for the title that no other blog uses, now or
+// in its slug history, in the same way as the uniqueSlug function of
+// BlogModel. The caller must hold the lock.
+func (m *MemoryBlogModel) uniqueSlug(title string, blogID int) string {
+	taken := map[string]bool{}
+	for id, blog := range m.blogs {
+		if id != blogID {
+			taken[blog.Slug] = true
+		}
+	}
+	for s, id := range m.slugHistory {
+		if id != blogID {
+			taken[s] = true
+		}
+	}
+
+	base := slug.Make(title)
+	candidate := base
+	for n := 2; taken[candidate]; n++ {
+		candidate = slug.WithSuffix(base, n)
+	}
+	return candidate
+}
+
+// updateSlug changes the slug of a blog whose title changes to newTitle, in
+// the same way as the updatedSlug function of BlogModel. The caller must hold
+// the write lock.
+func (m *MemoryBlogModel) updateSlug(blog *forms.Blog, newTitle string) {
+	if slug.Make(newTitle) == slug.Make(blog.Title) {
+		return
+	}
+
+	newSlug := m.uniqueSlug(newTitle, blog.ID)
+	if newSlug == blog.Slug {
+		return
+	}
+
+	m.slugHistory[blog.Slug] = blog.ID
+	delete(m.slugHistory, newSlug)
+	blog.Slug = newSlug
+}
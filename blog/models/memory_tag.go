@@ -0,0 +1,224 @@
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"blog/forms"
+)
+
+// ListTags returns a page of the tags, sorted by name.
+func (m *MemoryBlogModel) ListTags(query forms.ListTagsQuery) (forms.ListTagsResponse, error) {
+	query.Normalize()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tags := make([]forms.Tag, 0, len(m.tags))
+	for _, tag := range m.tags {
+		tags = append(tags, tag)
+	}
+	sortTags(tags)
+
+	total := len(tags)
+	if query.Offset >= len(tags) {
+		tags = tags[:0]
+	} else {
+		tags = tags[query.Offset:]
+	}
+	if len(tags) > query.Limit {
+		tags = tags[:query.Limit]
+	}
+
+	return newTagsPage(tags, query, total), nil
+}
+
+// GetTag returns a single tag.
+func (m *MemoryBlogModel) GetTag(id int) (forms.Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tag, ok := m.tags[id]
+	if !ok {
+		return forms.Tag{}, newError(ErrNotFound, "tag %d not found", id)
+	}
+
+	return tag, nil
+}
+
+// CreateTag creates a tag and returns it. It returns an ErrConflict error if
+// a tag with the same slug exists.
+func (m *MemoryBlogModel) CreateTag(req forms.TagRequest) (forms.Tag, error) {
+	name, s, err := tagName(req.Name)
+	if err != nil {
+		return forms.Tag{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tagBySlug(s); ok {
+		return forms.Tag{}, newError(ErrConflict, "a tag with the slug %q already exists", s)
+	}
+
+	return m.addTag(name, s), nil
+}
+
+// UpdateTag renames a tag and returns it. The slug changes with the name. It
+// returns an ErrConflict error if another tag has the new slug.
+func (m *MemoryBlogModel) UpdateTag(id int, req forms.TagRequest) (forms.Tag, error) {
+	name, s, err := tagName(req.Name)
+	if err != nil {
+		return forms.Tag{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tag, ok := m.tags[id]
+	if !ok {
+		return forms.Tag{}, newError(ErrNotFound, "tag %d not found", id)
+	}
+	if other, ok := m.tagBySlug(s); ok && other.ID != id {
+		return forms.Tag{}, newError(ErrConflict, "a tag with the slug %q already exists", s)
+	}
+
+	tag.Name = name
+	tag.Slug = s
+	m.tags[id] = tag
+
+	return tag, nil
+}
+
+// DeleteTag deletes a tag and removes it from its blogs.
+func (m *MemoryBlogModel) DeleteTag(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tags[id]; !ok {
+		return newError(ErrNotFound, "tag %d not found", id)
+	}
+
+	delete(m.tags, id)
+	for blogID, tagIDs := range m.blogTags {
+		kept := tagIDs[:0]
+		for _, tagID := range tagIDs {
+			if tagID != id {
+				kept = append(kept, tagID)
+			}
+		}
+		m.blogTags[blogID] = kept
+	}
+
+	return nil
+}
+
+// TagCloud returns the tags of the published blogs with the number of blogs
+// that have them, the most used first.
+func (m *MemoryBlogModel) TagCloud(query forms.TagCloudQuery) (forms.TagCloudResponse, error) {
+	query.Normalize()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := map[int]int{}
+	for blogID, tagIDs := range m.blogTags {
+		if blog, ok := m.liveBlog(blogID); !ok || blog.Status != forms.StatusPublished {
+			continue
+		}
+		for _, tagID := range tagIDs {
+			counts[tagID]++
+		}
+	}
+
+	cloud := forms.TagCloudResponse{Data: []forms.TagCount{}}
+	for tagID, count := range counts {
+		cloud.Data = append(cloud.Data, forms.TagCount{Tag: m.tags[tagID], Count: count})
+	}
+
+	sort.Slice(cloud.Data, func(i, j int) bool {
+		a, b := cloud.Data[i], cloud.Data[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+	if len(cloud.Data) > query.Limit {
+		cloud.Data = cloud.Data[:query.Limit]
+	}
+
+	return cloud, nil
+}
+
+// setBlogTags replaces the tags of a blog with the tags with the given
+// names, in the same way as the setBlogTags function of BlogModel. The
+// names must have been checked with tagNames. The caller must hold the
+// write lock.
+func (m *MemoryBlogModel) setBlogTags(blogID int, names, slugs []string) {
+	tagIDs := make([]int, 0, len(names))
+	for i, name := range names {
+		tag, ok := m.tagBySlug(slugs[i])
+		if !ok {
+			tag = m.addTag(name, slugs[i])
+		}
+		tagIDs = append(tagIDs, tag.ID)
+	}
+
+	m.blogTags[blogID] = tagIDs
+}
+
+// blogTagList returns the tags of a blog, sorted by name. The caller must
+// hold the lock.
+func (m *MemoryBlogModel) blogTagList(blogID int) []forms.Tag {
+	tags := []forms.Tag{}
+	for _, tagID := range m.blogTags[blogID] {
+		tags = append(tags, m.tags[tagID])
+	}
+	sortTags(tags)
+
+	return tags
+}
+
+// hasTag reports whether a blog has the tag with the given slug. The caller
+// must hold the lock.
+func (m *MemoryBlogModel) hasTag(blogID int, s string) bool {
+	for _, tagID := range m.blogTags[blogID] {
+		if m.tags[tagID].Slug == s {
+			return true
+		}
+	}
+	return false
+}
+
+// tagBySlug returns the tag with the given slug. The caller must hold the
+// lock.
+func (m *MemoryBlogModel) tagBySlug(s string) (forms.Tag, bool) {
+	for _, tag := range m.tags {
+		if tag.Slug == s {
+			return tag, true
+		}
+	}
+	return forms.Tag{}, false
+}
+
+// addTag adds a new tag. The caller must hold the write lock.
+func (m *MemoryBlogModel) addTag(name, s string) forms.Tag {
+	tag := forms.Tag{ID: m.nextTagID, Name: name, Slug: s}
+	m.tags[tag.ID] = tag
+	m.nextTagID++
+
+	return tag
+}
+
+// sortTags sorts tags by name, with the ID as the tie-breaker.
+func sortTags(tags []forms.Tag) {
+	sort.Slice(tags, func(i, j int) bool {
+		if c := strings.Compare(tags[i].Name, tags[j].Name); c != 0 {
+			return c < 0
+		}
+		return tags[i].ID < tags[j].ID
+	})
+}
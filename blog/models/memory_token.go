@@ -0,0 +1,151 @@
+package models
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryTokenModel is an in-memory implementation of TokenStore.
+//
+// All methods are safe for concurrent use.
+type MemoryTokenModel struct {
+	mu sync.Mutex
+
+	tokens map[string]*memoryToken // Tokens keyed by hash.
+	now    func() time.Time
+}
+
+// memoryToken is a refresh token together with its revocation state.
+type memoryToken struct {
+	RefreshToken
+	revoked bool
+}
+
+// NewMemoryTokenModel returns a new, empty MemoryTokenModel.
+func NewMemoryTokenModel() *MemoryTokenModel {
+	return &MemoryTokenModel{
+		tokens: map[string]*memoryToken{},
+		now:    time.Now,
+	}
+}
+
+// CreateRefreshToken stores a new refresh token.
+func (m *MemoryTokenModel) CreateRefreshToken(token RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tokens[token.Hash]; ok {
+		return newError(ErrConflict, "refresh token already exists")
+	}
+	m.tokens[token.Hash] = &memoryToken{RefreshToken: token}
+
+	return nil
+}
+
+// GetRefreshToken returns a refresh token, in the same way as
+// TokenModel.GetRefreshToken.
+func (m *MemoryTokenModel) GetRefreshToken(hash string) (RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[hash]
+	if !ok {
+		return RefreshToken{}, newError(ErrNotFound, "refresh token not found")
+	}
+	if token.revoked {
+		return RefreshToken{}, newError(ErrConflict, "refresh token was revoked")
+	}
+	if m.now().After(token.ExpiresAt) {
+		return RefreshToken{}, newError(ErrNotFound, "refresh token has expired")
+	}
+
+	return token.RefreshToken, nil
+}
+
+// RotateRefreshToken revokes the old refresh token and stores the next one,
+// in the same way as TokenModel.RotateRefreshToken.
+func (m *MemoryTokenModel) RotateRefreshToken(oldHash string, next RefreshToken) (RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.tokens[oldHash]
+	if !ok {
+		return RefreshToken{}, newError(ErrNotFound, "refresh token not found")
+	}
+	if old.revoked {
+		m.revokeFamily(old.FamilyID)
+		return RefreshToken{}, newError(ErrConflict, "refresh token was already used")
+	}
+	if m.now().After(old.ExpiresAt) {
+		return RefreshToken{}, newError(ErrNotFound, "refresh token has expired")
+	}
+
+	old.revoked = true
+	next.UserID = old.UserID
+	next.FamilyID = old.FamilyID
+	m.tokens[next.Hash] = &memoryToken{RefreshToken: next}
+
+	return next, nil
+}
+
+// RevokeRefreshTokenFamily revokes a refresh token and its whole family.
+func (m *MemoryTokenModel) RevokeRefreshTokenFamily(hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[hash]
+	if !ok {
+		return newError(ErrNotFound, "refresh token not found")
+	}
+	m.revokeFamily(token.FamilyID)
+
+	return nil
+}
+
+// revokeFamily revokes all tokens of a family. The caller must hold m.mu.
+func (m *MemoryTokenModel) revokeFamily(familyID string) {
+	for _, token := range m.tokens {
+		if token.FamilyID == familyID {
+			token.revoked = true
+		}
+	}
+}
+
+// PurgeRefreshTokens removes up to limit refresh tokens that can't be used
+// any more, in the same way as TokenModel.PurgeRefreshTokens.
+func (m *MemoryTokenModel) PurgeRefreshTokens(before time.Time, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// A family expires with its token that expires last.
+	familyExpiry := map[string]time.Time{}
+	for _, token := range m.tokens {
+		if token.ExpiresAt.After(familyExpiry[token.FamilyID]) {
+			familyExpiry[token.FamilyID] = token.ExpiresAt
+		}
+	}
+
+	var purge []*memoryToken
+	for _, token := range m.tokens {
+		if !token.ExpiresAt.Before(before) {
+			continue
+		}
+		if token.revoked && !familyExpiry[token.FamilyID].Before(before) {
+			continue
+		}
+		purge = append(purge, token)
+	}
+
+	sort.Slice(purge, func(i, j int) bool {
+		return purge[i].ExpiresAt.Before(purge[j].ExpiresAt)
+	})
+	if len(purge) > limit {
+		purge = purge[:limit]
+	}
+	for _, token := range purge {
+		delete(m.tokens, token.Hash)
+	}
+
+	return len(purge), nil
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryPurgeRefreshTokens(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMemoryTokenModel()
+	m.now = func() time.Time { return now }
+
+	create := func(hash, family string, expiresAt time.Time) {
+		t.Helper()
+		if err := m.CreateRefreshToken(RefreshToken{UserID: 1, FamilyID: family, Hash: hash, ExpiresAt: expiresAt}); err != nil {
+			t.Fatalf("CreateRefreshToken(%s): %v", hash, err)
+		}
+	}
+	rotate := func(old, next string, expiresAt time.Time) {
+		t.Helper()
+		if _, err := m.RotateRefreshToken(old, RefreshToken{Hash: next, ExpiresAt: expiresAt}); err != nil {
+			t.Fatalf("RotateRefreshToken(%s): %v", old, err)
+		}
+	}
+
+	// A family that is still alive: its first token was rotated and has
+	// expired since, but the one that replaced it hasn't.
+	create("live-1", "live", now.Add(time.Hour))
+	rotate("live-1", "live-2", now.Add(3*time.Hour))
+
+	// A family whose tokens have all expired, one of them revoked.
+	create("dead-1", "dead", now.Add(time.Minute))
+	rotate("dead-1", "dead-2", now.Add(2*time.Minute))
+
+	// A token that expired without being used.
+	create("unused", "unused", now.Add(time.Minute))
+
+	now = now.Add(2 * time.Hour)
+
+	n, err := m.PurgeRefreshTokens(now, 10)
+	if err != nil || n != 3 {
+		t.Fatalf("PurgeRefreshTokens() = %d, %v; want 3, nil", n, err)
+	}
+	for _, hash := range []string{"dead-1", "dead-2", "unused"} {
+		if _, ok := m.tokens[hash]; ok {
+			t.Errorf("token %s was kept", hash)
+		}
+	}
+
+	// The revoked token of the live family is kept, so that reusing it is
+	// still noticed and revokes the family.
+	if _, ok := m.tokens["live-1"]; !ok {
+		t.Fatal("revoked token of a live family was removed")
+	}
+	if _, err := m.RotateRefreshToken("live-1", RefreshToken{Hash: "stolen", ExpiresAt: now.Add(time.Hour)}); !errors.Is(err, ErrConflict) {
+		t.Errorf("reusing a revoked token = %v, want ErrConflict", err)
+	}
+	if _, err := m.RotateRefreshToken("live-2", RefreshToken{Hash: "live-3", ExpiresAt: now.Add(time.Hour)}); !errors.Is(err, ErrConflict) {
+		t.Errorf("rotating after reuse = %v, want ErrConflict", err)
+	}
+}
+
+func TestMemoryPurgeRefreshTokensLimit(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMemoryTokenModel()
+
+	expired := map[string]time.Duration{"a": time.Hour, "b": time.Minute, "c": 3 * time.Minute}
+	for hash, ago := range expired {
+		if err := m.CreateRefreshToken(RefreshToken{UserID: 1, FamilyID: hash, Hash: hash, ExpiresAt: now.Add(-ago)}); err != nil {
+			t.Fatalf("CreateRefreshToken(%s): %v", hash, err)
+		}
+	}
+
+	// The oldest tokens go first.
+	n, err := m.PurgeRefreshTokens(now, 2)
+	if err != nil || n != 2 {
+		t.Fatalf("PurgeRefreshTokens() = %d, %v; want 2, nil", n, err)
+	}
+	if _, ok := m.tokens["b"]; !ok || len(m.tokens) != 1 {
+		t.Errorf("tokens left after the first batch: %v; want b", m.tokens)
+	}
+
+	n, err = m.PurgeRefreshTokens(now, 2)
+	if err != nil || n != 1 || len(m.tokens) != 0 {
+		t.Errorf("PurgeRefreshTokens() = %d, %v with %d tokens left; want 1, nil and none", n, err, len(m.tokens))
+	}
+}
+
+func TestMemoryGetRefreshToken(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMemoryTokenModel()
+	m.now = func() time.Time { return now }
+
+	for _, hash := range []string{"a", "b", "c"} {
+		if err := m.CreateRefreshToken(RefreshToken{UserID: 1, FamilyID: hash, Hash: hash, ExpiresAt: now.Add(time.Hour)}); err != nil {
+			t.Fatalf("CreateRefreshToken(%s): %v", hash, err)
+		}
+	}
+	if err := m.RevokeRefreshTokenFamily("b"); err != nil {
+		t.Fatalf("RevokeRefreshTokenFamily: %v", err)
+	}
+	m.tokens["c"].ExpiresAt = now.Add(-time.Second)
+
+	// Getting a token doesn't rotate it, so it can be got again.
+	for i := 0; i < 2; i++ {
+		if token, err := m.GetRefreshToken("a"); err != nil || token.UserID != 1 || token.FamilyID != "a" {
+			t.Errorf("GetRefreshToken(a) = %+v, %v; want the token", token, err)
+		}
+	}
+	if _, err := m.GetRefreshToken("b"); !errors.Is(err, ErrConflict) {
+		t.Errorf("GetRefreshToken of a revoked token = %v, want ErrConflict", err)
+	}
+	if _, err := m.GetRefreshToken("c"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetRefreshToken of an expired token = %v, want ErrNotFound", err)
+	}
+	if _, err := m.GetRefreshToken("d"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetRefreshToken of an unknown token = %v, want ErrNotFound", err)
+	}
+}
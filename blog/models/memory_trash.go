@@ -0,0 +1,228 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"blog/forms"
+)
+
+// ListTrashedBlogs returns a page of the blogs in the trash, most recently
+// deleted first.
+func (m *MemoryBlogModel) ListTrashedBlogs(query forms.TrashQuery) (forms.ListTrashedBlogsResponse, error) {
+	query.Normalize()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	blogs := []forms.TrashedBlog{}
+	for id, deletedAt := range m.deletedBlogs {
+		blog := m.blogs[id]
+		if !query.ViewAll && (blog.Author == nil || blog.Author.ID != query.ViewerID) {
+			continue
+		}
+
+		blogs = append(blogs, forms.TrashedBlog{
+			ID:        blog.ID,
+			Title:     blog.Title,
+			Status:    blog.Status,
+			Author:    blog.Author,
+			DeletedAt: deletedAt,
+		})
+	}
+
+	sort.Slice(blogs, func(i, j int) bool {
+		if !blogs[i].DeletedAt.Equal(blogs[j].DeletedAt) {
+			return blogs[i].DeletedAt.After(blogs[j].DeletedAt)
+		}
+		return blogs[i].ID > blogs[j].ID
+	})
+
+	total := len(blogs)
+	if query.Offset >= len(blogs) {
+		blogs = blogs[:0]
+	} else {
+		blogs = blogs[query.Offset:]
+	}
+	if len(blogs) > query.Limit {
+		blogs = blogs[:query.Limit]
+	}
+
+	return forms.ListTrashedBlogsResponse{
+		Data:       blogs,
+		Pagination: newTrashPagination(len(blogs), query, total),
+	}, nil
+}
+
+// ListTrashedComments returns a page of the comments in the trash, most
+// recently deleted first. Comments of blogs in the trash are left out; they
+// come back with their blog.
+func (m *MemoryBlogModel) ListTrashedComments(query forms.TrashQuery) (forms.ListTrashedCommentsResponse, error) {
+	query.Normalize()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	comments := []forms.TrashedComment{}
+	for blogID, all := range m.comments {
+		if _, deleted := m.deletedBlogs[blogID]; deleted {
+			continue
+		}
+
+		for _, comment := range all {
+			deletedAt, deleted := m.deletedComments[comment.ID]
+			if !deleted {
+				continue
+			}
+			if !query.ViewAll && (comment.Author == nil || comment.Author.ID != query.ViewerID) {
+				continue
+			}
+
+			comments = append(comments, forms.TrashedComment{
+				ID:        comment.ID,
+				BlogID:    comment.BlogID,
+				ParentID:  comment.ParentID,
+				Content:   comment.Content,
+				Author:    comment.Author,
+				DeletedAt: deletedAt,
+			})
+		}
+	}
+
+	sort.Slice(comments, func(i, j int) bool {
+		if !comments[i].DeletedAt.Equal(comments[j].DeletedAt) {
+			return comments[i].DeletedAt.After(comments[j].DeletedAt)
+		}
+		return comments[i].ID > comments[j].ID
+	})
+
+	total := len(comments)
+	if query.Offset >= len(comments) {
+		comments = comments[:0]
+	} else {
+		comments = comments[query.Offset:]
+	}
+	if len(comments) > query.Limit {
+		comments = comments[:query.Limit]
+	}
+
+	return forms.ListTrashedCommentsResponse{
+		Data:       comments,
+		Pagination: newTrashPagination(len(comments), query, total),
+	}, nil
+}
+
+// RestoreBlog takes a blog out of the trash, together with its comments.
+func (m *MemoryBlogModel) RestoreBlog(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, deleted := m.deletedBlogs[id]; !deleted {
+		return newError(ErrNotFound, "blog %d is not in the trash", id)
+	}
+
+	delete(m.deletedBlogs, id)
+
+	return nil
+}
+
+// RestoreComment takes a comment out of the trash, together with its
+// replies. The blog of the comment must not be in the trash.
+func (m *MemoryBlogModel) RestoreComment(blogID, commentID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, blogDeleted := m.deletedBlogs[blogID]
+	_, deleted := m.deletedComments[commentID]
+	if _, ok := m.findAnyComment(blogID, commentID); !ok || !deleted || blogDeleted {
+		return newError(ErrNotFound, "comment %d is not in the trash", commentID)
+	}
+
+	delete(m.deletedComments, commentID)
+
+	return nil
+}
+
+// PurgeDeleted permanently removes up to limit blogs and up to limit
+// comments that were moved to the trash before the given time, and returns
+// how many were removed.
+//
+// Like the ON DELETE CASCADE foreign keys in Postgres, removing a blog
+// removes its comments and revisions, and removing a comment removes its
+// replies. These are not counted.
+func (m *MemoryBlogModel) PurgeDeleted(before time.Time, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	blogs := oldestDeleted(m.deletedBlogs, before, limit)
+	for _, id := range blogs {
+		for _, comment := range m.comments[id] {
+			delete(m.deletedComments, comment.ID)
+		}
+		delete(m.blogs, id)
+		delete(m.comments, id)
+		delete(m.revisions, id)
+		delete(m.deletedBlogs, id)
+		delete(m.blogTags, id)
+		delete(m.blogCategories, id)
+		for s, blogID := range m.slugHistory {
+			if blogID == id {
+				delete(m.slugHistory, s)
+			}
+		}
+	}
+
+	comments := oldestDeleted(m.deletedComments, before, limit)
+	for _, id := range comments {
+		delete(m.deletedComments, id)
+	}
+	if len(comments) > 0 {
+		m.removeComments(comments)
+	}
+
+	return len(blogs) + len(comments), nil
+}
+
+// removeComments removes comments and all their replies. The caller must
+// hold the write lock.
+func (m *MemoryBlogModel) removeComments(ids []int) {
+	removed := map[int]bool{}
+	for _, id := range ids {
+		removed[id] = true
+	}
+
+	for blogID, all := range m.comments {
+		kept := all[:0]
+		for _, comment := range all {
+			// The comments are stored in the order they were created, so a
+			// parent is always seen before its replies.
+			if removed[comment.ID] || (comment.ParentID != nil && removed[*comment.ParentID]) {
+				removed[comment.ID] = true
+				delete(m.deletedComments, comment.ID)
+				continue
+			}
+			kept = append(kept, comment)
+		}
+		m.comments[blogID] = kept
+	}
+}
+
+// oldestDeleted returns the IDs of up to limit items that were deleted
+// before the given time, oldest first.
+func oldestDeleted(deleted map[int]time.Time, before time.Time, limit int) []int {
+	var ids []int
+	for id, deletedAt := range deleted {
+		if deletedAt.Before(before) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return deleted[ids[i]].Before(deleted[ids[j]])
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	return ids
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"blog/forms"
+	"blog/policy"
+)
+
+// MemoryUserModel is an in-memory implementation of UserStore.
+//
+// Like MemoryBlogModel, it loses all data on restart. All methods are safe
+// for concurrent use.
+type MemoryUserModel struct {
+	mu sync.RWMutex
+
+	users   map[int]memoryUser
+	byEmail map[string]int // User IDs keyed by lower-cased email address.
+	nextID  int
+	now     func() time.Time
+}
+
+// memoryUser is a user together with their password hash.
+type memoryUser struct {
+	forms.User
+	passwordHash string
+}
+
+// NewMemoryUserModel returns a new, empty MemoryUserModel.
+func NewMemoryUserModel() *MemoryUserModel {
+	return &MemoryUserModel{
+		users:   map[int]memoryUser{},
+		byEmail: map[string]int{},
+		nextID:  1,
+		now:     time.Now,
+	}
+}
+
+// CreateUser adds a new user and returns it.
+// It returns an ErrConflict error if the email address is already taken.
+func (m *MemoryUserModel) CreateUser(user forms.RegisterRequest, passwordHash string) (forms.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := strings.ToLower(user.Email)
+	if _, ok := m.byEmail[key]; ok {
+		return forms.User{}, newError(ErrConflict, "email address %s is already registered", user.Email)
+	}
+
+	now := m.now()
+	created := forms.User{
+		ID:        m.nextID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      string(policy.DefaultRole),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	m.users[created.ID] = memoryUser{User: created, passwordHash: passwordHash}
+	m.byEmail[key] = created.ID
+	m.nextID++
+
+	return created, nil
+}
+
+// GetUserByID returns a single user.
+func (m *MemoryUserModel) GetUserByID(id int) (forms.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return forms.User{}, newError(ErrNotFound, "user %d not found", id)
+	}
+
+	return user.User, nil
+}
+
+// author returns the public information of a user, or nil if the user
+// doesn't exist. It is used by MemoryBlogModel to fill in authors.
+func (m *MemoryUserModel) author(id int) *forms.Author {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil
+	}
+	return &forms.Author{ID: user.ID, Name: user.Name}
+}
+
+// GetUserByEmail returns a single user and their password hash.
+func (m *MemoryUserModel) GetUserByEmail(email string) (forms.User, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.byEmail[strings.ToLower(email)]
+	if !ok {
+		return forms.User{}, "", newError(ErrNotFound, "user %s not found", email)
+	}
+
+	user := m.users[id]
+	return user.User, user.passwordHash, nil
+}
+
+// SetUserRole changes the role of a user and returns the updated user.
+func (m *MemoryUserModel) SetUserRole(id int, role policy.Role) (forms.User, error) {
+	if !role.Valid() {
+		return forms.User{}, newError(ErrValidation, "unknown role %q", role)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return forms.User{}, newError(ErrNotFound, "user %d not found", id)
+	}
+
+	user.Role = string(role)
+	user.UpdatedAt = m.now()
+	m.users[id] = user
+
+	return user.User, nil
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// cursor is the position of a row in a list sorted by (created_at, id).
+//
+// Clients only ever see it encoded, so its format can change without
+// breaking them.
+type cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"id"`
+}
+
+// encodeCursor returns the opaque cursor string for a row.
+func encodeCursor(createdAt time.Time, id int) string {
+	b, _ := json.Marshal(cursor{CreatedAt: createdAt, ID: id})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses a cursor string returned by encodeCursor.
+// It returns an ErrValidation error if the cursor is not valid.
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &c) != nil || c.ID < 1 {
+		return cursor{}, newError(ErrValidation, "invalid cursor %q", s)
+	}
+
+	return c, nil
+}
+
+// after reports whether the row is after the cursor in the given order.
+func (c cursor) after(createdAt time.Time, id int, order string) bool {
+	if order == "asc" {
+		return createdAt.After(c.CreatedAt) || (createdAt.Equal(c.CreatedAt) && id > c.ID)
+	}
+	return createdAt.Before(c.CreatedAt) || (createdAt.Equal(c.CreatedAt) && id < c.ID)
+}
+
+// queryBuilder collects the conditions and arguments of a WHERE clause.
+//
+// The conditions are joined with AND, and every argument gets the next
+// $n placeholder, so the values are always passed to Postgres as parameters
+// and never pasted into the SQL.
+type queryBuilder struct {
+	conds []string
+	args  []any
+}
+
+// arg adds an argument and returns its placeholder, for example "$3".
+func (q *queryBuilder) arg(value any) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// where adds a condition.
+func (q *queryBuilder) where(cond string) {
+	q.conds = append(q.conds, cond)
+}
+
+// clause returns the WHERE clause, or an empty string if there are no
+// conditions.
+func (q *queryBuilder) clause() string {
+	if len(q.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(q.conds, " AND ")
+}
+
+// likeEscaper escapes the wildcard characters of a LIKE pattern, so that a
+// search for "50%" matches the text "50%" and not "500".
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern returns an ILIKE pattern that matches text containing s.
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+
+	"blog/forms"
+)
+
+// ListRevisions returns a page of the revisions of a blog, newest first.
+func (m *BlogModel) ListRevisions(blogID int, query forms.ListRevisionsQuery) (forms.ListRevisionsResponse, error) {
+	query.Normalize()
+
+	// Every blog has at least one revision, so a count of 0 means that the
+	// blog doesn't exist.
+	var total int
+	if err := m.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM blog_revisions
+		WHERE blog_id = $1
+	`, blogID).Scan(&total); err != nil {
+		return forms.ListRevisionsResponse{}, fmt.Errorf("failed to count revisions: %w", err)
+	}
+	if total == 0 {
+		return forms.ListRevisionsResponse{}, newError(ErrNotFound, "blog %d not found", blogID)
+	}
+
+	rows, err := m.db.Query(`
+		SELECT r.revision, r.blog_id, r.title, r.content, r.language::text, r.restored_from, r.created_at, u.id, u.name
+		FROM blog_revisions AS r
+		LEFT JOIN users AS u ON u.id = r.editor_id
+		WHERE r.blog_id = $1
+		ORDER BY r.revision DESC
+		LIMIT $2 OFFSET $3
+	`, blogID, query.Limit, query.Offset)
+	if err != nil {
+		return forms.ListRevisionsResponse{}, fmt.Errorf("failed to get revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []forms.Revision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return forms.ListRevisionsResponse{}, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return forms.ListRevisionsResponse{}, fmt.Errorf("failed to get revisions: %w", err)
+	}
+
+	return newRevisionsPage(revisions, query, total), nil
+}
+
+// newRevisionsPage returns a page of revisions with its pagination.
+func newRevisionsPage(revisions []forms.Revision, query forms.ListRevisionsQuery, total int) forms.ListRevisionsResponse {
+	return forms.ListRevisionsResponse{
+		Data: revisions,
+		Pagination: forms.Pagination{
+			Limit:   query.Limit,
+			Offset:  query.Offset,
+			Total:   total,
+			HasMore: query.Offset+len(revisions) < total,
+		},
+	}
+}
+
+// GetRevision returns a single revision of a blog.
+func (m *BlogModel) GetRevision(blogID, number int) (forms.Revision, error) {
+	row := m.db.QueryRow(`
+		SELECT r.revision, r.blog_id, r.title, r.content, r.language::text, r.restored_from, r.created_at, u.id, u.name
+		FROM blog_revisions AS r
+		LEFT JOIN users AS u ON u.id = r.editor_id
+		WHERE r.blog_id = $1 AND r.revision = $2
+	`, blogID, number)
+
+	revision, err := scanRevision(row)
+	if err != nil {
+		return forms.Revision{}, translate(err, "revision %d of blog %d not found", number, blogID)
+	}
+
+	return revision, nil
+}
+
+// RestoreRevision makes an old revision of a blog the current version.
+//
+// The blog isn't rolled back: the old title, content and language are saved
+// as a new revision, so the history keeps every version, including the one
+// that was replaced.
+func (m *BlogModel) RestoreRevision(blogID, number, editorID int) error {
+	return retryOnSlugConflict(func() error {
+		tx, err := m.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback() // Rollback is a no-op once the transaction is committed.
+
+		var blog forms.UpdateBlogRequest
+		if err := tx.QueryRow(`
+			SELECT title, content, language::text
+			FROM blog_revisions
+			WHERE blog_id = $1 AND revision = $2
+		`, blogID, number).Scan(&blog.Title, &blog.Content, &blog.Language); err != nil {
+			return translate(err, "revision %d of blog %d not found", number, blogID)
+		}
+
+		if err := updateBlog(tx, blogID, editorID, blog, number); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows, so that
+// scanRevision can be used with QueryRow and Query.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanRevision scans a revision selected by ListRevisions or GetRevision.
+func scanRevision(row rowScanner) (forms.Revision, error) {
+	var revision forms.Revision
+	var restoredFrom sql.NullInt64
+	var editor authorColumns
+	if err := row.Scan(
+		&revision.Number,
+		&revision.BlogID,
+		&revision.Title,
+		&revision.Content,
+		&revision.Language,
+		&restoredFrom,
+		&revision.CreatedAt,
+		&editor.id,
+		&editor.name,
+	); err != nil {
+		return forms.Revision{}, err
+	}
+
+	revision.Editor = editor.author()
+	if restoredFrom.Valid {
+		n := int(restoredFrom.Int64)
+		revision.RestoredFrom = &n
+	}
+
+	return revision, nil
+}
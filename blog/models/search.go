@@ -0,0 +1,160 @@
+package models
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"blog/forms"
+)
+
+// The markers that ts_headline puts around matching words.
+//
+// We use characters from the Unicode private use area, which never appear in
+// normal text, instead of <mark> tags. That way we can first HTML-escape the
+// whole snippet (the content is user input) and only then turn the markers
+// into <mark> tags. See highlight.
+const (
+	markStart = "\uE000"
+	markStop  = "\uE001"
+)
+
+// headlineOptions are the options passed to ts_headline.
+//
+// For more information on ts_headline, see:
+// https://www.postgresql.org/docs/current/textsearch-controls.html#TEXTSEARCH-HEADLINE
+var (
+	titleHeadlineOptions   = "HighlightAll=true, StartSel=" + markStart + ", StopSel=" + markStop
+	snippetHeadlineOptions = "MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=\" … \", StartSel=" + markStart + ", StopSel=" + markStop
+)
+
+// searchMatches is the common part of the search queries. It finds the blogs
+// and comments that match the query, using the GIN indexes on search_vector.
+//
+// $1 is the search text and $2 the language used to parse it.
+// websearch_to_tsquery understands "quoted phrases", OR and -excluded words,
+// and never fails on bad syntax.
+//
+// ts_rank_cd ranks a match higher when the matching words are close together
+// and when they are in the title (weight A) rather than the content.
+//
+// Only published blogs, and the comments of published blogs, are searched.
+// Blogs and comments in the trash are left out.
+const searchMatches = `
+	WITH q AS (
+		SELECT websearch_to_tsquery($2::regconfig, $1) AS query
+	),
+	blog_hits AS (
+		SELECT b.id, ts_rank_cd(b.search_vector, q.query) AS rank
+		FROM blogs AS b, q
+		WHERE b.search_vector @@ q.query AND b.status = 'published' AND b.deleted_at IS NULL
+	),
+	comment_hits AS (
+		SELECT c.blog_id, COUNT(*) AS matches, MAX(ts_rank_cd(c.search_vector, q.query)) AS rank
+		FROM comments AS c
+		JOIN blogs AS cb ON cb.id = c.blog_id, q
+		WHERE c.search_vector @@ q.query AND c.deleted_at IS NULL
+			AND cb.status = 'published' AND cb.deleted_at IS NULL
+		GROUP BY c.blog_id
+	)`
+
+// Search returns the blogs whose title, content or comments match the query,
+// best matches first.
+func (m *BlogModel) Search(query forms.SearchQuery) (forms.SearchResponse, error) {
+	query.Normalize()
+
+	// Count all the matching blogs, for the pagination.
+	var total int
+	if err := m.db.QueryRow(searchMatches+`
+		SELECT COUNT(*)
+		FROM (
+			SELECT id FROM blog_hits
+			UNION
+			SELECT blog_id FROM comment_hits
+		) AS hits
+	`, query.Q, query.Language).Scan(&total); err != nil {
+		return forms.SearchResponse{}, translate(err, "failed to count search results")
+	}
+
+	// Get the page of results.
+	//
+	// A blog matches if its own text matches or any of its comments match.
+	// Comment matches count for half as much as blog matches. If only the
+	// comments match, the snippet is taken from the best matching comment.
+	rows, err := m.db.Query(searchMatches+`
+		SELECT
+			b.id,
+			b.title,
+			b.created_at,
+			ts_headline(b.language, b.title, q.query, $3),
+			CASE
+				WHEN bh.id IS NOT NULL THEN ts_headline(b.language, b.content, q.query, $4)
+				ELSE (
+					SELECT ts_headline(c.language, c.content, q.query, $4)
+					FROM comments AS c
+					WHERE c.blog_id = b.id AND c.search_vector @@ q.query AND c.deleted_at IS NULL
+					ORDER BY ts_rank_cd(c.search_vector, q.query) DESC, c.id
+					LIMIT 1
+				)
+			END,
+			COALESCE(bh.rank, 0) + COALESCE(ch.rank, 0) / 2 AS rank,
+			COALESCE(ch.matches, 0)
+		FROM q, blogs AS b
+		LEFT JOIN blog_hits AS bh ON bh.id = b.id
+		LEFT JOIN comment_hits AS ch ON ch.blog_id = b.id
+		WHERE bh.id IS NOT NULL OR ch.blog_id IS NOT NULL
+		ORDER BY rank DESC, b.id DESC
+		LIMIT $5 OFFSET $6
+	`, query.Q, query.Language, titleHeadlineOptions, snippetHeadlineOptions, query.Limit, query.Offset)
+	if err != nil {
+		return forms.SearchResponse{}, translate(err, "failed to search blogs")
+	}
+	defer rows.Close() // Remember to close the rows when you're done with them!
+
+	results := make([]forms.SearchResult, 0, query.Limit)
+	for rows.Next() {
+		var r forms.SearchResult
+		if err := rows.Scan(
+			&r.ID,
+			&r.Title,
+			&r.CreatedAt,
+			&r.TitleHighlight,
+			&r.Snippet,
+			&r.Rank,
+			&r.MatchingComments,
+		); err != nil {
+			return forms.SearchResponse{}, fmt.Errorf("failed to scan search result: %w", err)
+		}
+
+		r.TitleHighlight = highlight(r.TitleHighlight)
+		r.Snippet = highlight(r.Snippet)
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return forms.SearchResponse{}, fmt.Errorf("failed to search blogs: %w", err)
+	}
+
+	return newSearchPage(results, query, total), nil
+}
+
+// newSearchPage returns the page for a list of search results.
+func newSearchPage(results []forms.SearchResult, query forms.SearchQuery, total int) forms.SearchResponse {
+	return forms.SearchResponse{
+		Data: results,
+		Pagination: forms.Pagination{
+			Limit:   query.Limit,
+			Offset:  query.Offset,
+			Total:   total,
+			HasMore: query.Offset+len(results) < total,
+		},
+	}
+}
+
+// highlighter turns the markers into <mark> tags.
+var highlighter = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+// highlight HTML-escapes a text containing markers and then turns the markers
+// into <mark> tags, so that the result is safe to insert into a web page.
+func highlight(s string) string {
+	return highlighter.Replace(html.EscapeString(s))
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"blog/slug"
+)
+
+// slugBatchSize is how many candidate slugs uniqueSlug checks per query.
+const slugBatchSize = 20
+
+// slugAttempts is how often a write is tried when another request takes the
+// same slug at the same time.
+const slugAttempts = 3
+
+// querier is implemented by both *sql.DB and *sql.Tx, so that uniqueSlug can
+// be used inside and outside a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// uniqueSlug returns a slug for the title that no other blog uses, now or
+// in its slug history. The first blog with a title gets the plain slug, and
+// the others get a suffix: "hello-world", "hello-world-2", "hello-world-3".
+//
+// The old slugs of the blog with the ID blogID don't count as taken, so a
+// blog that gets its old title back also gets its old slug back. Use 0 for
+// new blogs.
+func uniqueSlug(q querier, title string, blogID int) (string, error) {
+	base := slug.Make(title)
+
+	for n := 1; ; n += slugBatchSize {
+		candidates := make([]string, 0, slugBatchSize)
+		for i := n; i < n+slugBatchSize; i++ {
+			if i == 1 {
+				candidates = append(candidates, base)
+			} else {
+				candidates = append(candidates, slug.WithSuffix(base, i))
+			}
+		}
+
+		taken, err := takenSlugs(q, candidates, blogID)
+		if err != nil {
+			return "", err
+		}
+
+		for _, candidate := range candidates {
+			if !taken[candidate] {
+				return candidate, nil
+			}
+		}
+	}
+}
+
+// takenSlugs returns which of the candidates are used by blogs other than
+// the one with the ID blogID.
+func takenSlugs(q querier, candidates []string, blogID int) (map[string]bool, error) {
+	rows, err := q.Query(`
+		SELECT slug FROM blogs WHERE slug = ANY($1) AND id <> $2
+		UNION
+		SELECT slug FROM blog_slug_history WHERE slug = ANY($1) AND blog_id <> $2
+	`, candidates, blogID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check slugs: %w", err)
+	}
+	defer rows.Close()
+
+	taken := map[string]bool{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, fmt.Errorf("failed to scan slug: %w", err)
+		}
+		taken[s] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check slugs: %w", err)
+	}
+
+	return taken, nil
+}
+
+// retryOnSlugConflict calls write until it doesn't fail because of a taken
+// slug, at most slugAttempts times.
+//
+// uniqueSlug only finds a free slug; it doesn't reserve it. If two requests
+// create blogs with the same title at the same time, both may pick the same
+// slug, and the unique constraint makes one of them fail. Trying again picks
+// the next free slug.
+func retryOnSlugConflict(write func() error) error {
+	var err error
+	for attempt := 0; attempt < slugAttempts; attempt++ {
+		err = write()
+		if !isSlugConflict(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// isSlugConflict reports whether err is caused by a slug that is already
+// taken.
+func isSlugConflict(err error) bool {
+	if !errors.Is(err, ErrConflict) {
+		return false
+	}
+	name := constraintName(err)
+	return name == "blogs_slug_key" || name == "blog_slug_history_pkey"
+}
+
+// GetBlogIDBySlug returns the ID and the current slug of the blog with the
+// given slug. If s is an old slug of the blog, the current slug is different
+// from s.
+//
+// It returns an ErrNotFound error if no blog has or had the slug, or if the
+// blog is in the trash.
+func (m *BlogModel) GetBlogIDBySlug(s string) (int, string, error) {
+	// The current slugs come first, in case an old slug of one blog is the
+	// current slug of another.
+	var id int
+	var current string
+	if err := m.db.QueryRow(`
+		SELECT id, slug
+		FROM (
+			SELECT b.id, b.slug, 1 AS priority
+			FROM blogs AS b
+			WHERE b.slug = $1 AND b.deleted_at IS NULL
+			UNION ALL
+			SELECT b.id, b.slug, 2 AS priority
+			FROM blog_slug_history AS h
+			JOIN blogs AS b ON b.id = h.blog_id
+			WHERE h.slug = $1 AND b.deleted_at IS NULL
+		) AS matches
+		ORDER BY priority
+		LIMIT 1
+	`, s).Scan(&id, &current); err != nil {
+		return 0, "", translate(err, "blog %q not found", s)
+	}
+
+	return id, current, nil
+}
+
+// updatedSlug returns the slug of the blog with the given ID after its title
+// changes from oldTitle to newTitle, as part of the transaction tx.
+//
+// The slug only changes if the new title makes a different slug, so fixing
+// the case or the punctuation of a title keeps its links. When it changes,
+// the old slug is added to the slug history, so that links to it can be
+// redirected.
+func updatedSlug(tx *sql.Tx, id int, oldTitle, oldSlug, newTitle string) (string, error) {
+	if slug.Make(newTitle) == slug.Make(oldTitle) {
+		return oldSlug, nil
+	}
+
+	newSlug, err := uniqueSlug(tx, newTitle, id)
+	if err != nil {
+		return "", err
+	}
+	if newSlug == oldSlug {
+		return oldSlug, nil
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO blog_slug_history (slug, blog_id)
+		VALUES ($1, $2)
+		ON CONFLICT (slug) DO NOTHING
+	`, oldSlug, id); err != nil {
+		return "", translate(err, "failed to save the old slug of blog %d", id)
+	}
+
+	// The new slug may be an old slug of the same blog, which is now current
+	// again.
+	if _, err := tx.Exec(`
+		DELETE FROM blog_slug_history
+		WHERE slug = $1 AND blog_id = $2
+	`, newSlug, id); err != nil {
+		return "", translate(err, "failed to update the slug history of blog %d", id)
+	}
+
+	return newSlug, nil
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"blog/forms"
+)
+
+// BlogStore is the interface that the blog storage backends implement.
+//
+// The controllers depend on this interface rather than on a concrete model,
+// so the API can run on top of Postgres (BlogModel) or entirely in memory
+// (MemoryBlogModel) without any changes to the controllers.
+//
+// For more information on interfaces, see:
+// https://golang.org/doc/effective_go.html#interfaces
+type BlogStore interface {
+	CreateBlog(authorID int, blog forms.CreateBlogRequest) error
+	GetAllBlogs(query forms.ListBlogsQuery) (forms.ListBlogsResponse, error)
+	GetBlogByID(id int) (forms.GetBlogByIDResponse, error)
+	GetBlogIDBySlug(slug string) (int, string, error)
+	GetBlogInfo(id int) (BlogInfo, error)
+	UpdateBlog(id, editorID int, blog forms.UpdateBlogRequest) error
+	DeleteBlog(id int) error
+	CreateComment(blogID, authorID int, comment forms.CreateCommentRequest) error
+	ReplyToComment(blogID, parentID, authorID int, comment forms.CreateCommentRequest) error
+	ListComments(blogID int, query forms.ListCommentsQuery) (forms.ListCommentsResponse, error)
+	GetCommentInfo(blogID, commentID int) (CommentInfo, error)
+	UpdateComment(blogID, commentID int, comment forms.UpdateCommentRequest) error
+	DeleteComment(blogID, commentID int) error
+	Search(query forms.SearchQuery) (forms.SearchResponse, error)
+
+	PublishBlog(id int) error
+	UnpublishBlog(id int) error
+	ArchiveBlog(id int) error
+	ScheduleBlog(id int, publishAt time.Time) error
+	PublishDueBlogs(now time.Time, limit int) ([]int, error)
+	RenderStaleHTML(limit int) (int, error)
+
+	ListRevisions(blogID int, query forms.ListRevisionsQuery) (forms.ListRevisionsResponse, error)
+	GetRevision(blogID, number int) (forms.Revision, error)
+	RestoreRevision(blogID, number, editorID int) error
+
+	ListTrashedBlogs(query forms.TrashQuery) (forms.ListTrashedBlogsResponse, error)
+	ListTrashedComments(query forms.TrashQuery) (forms.ListTrashedCommentsResponse, error)
+	RestoreBlog(id int) error
+	RestoreComment(blogID, commentID int) error
+	PurgeDeleted(before time.Time, limit int) (int, error)
+
+	ListTags(query forms.ListTagsQuery) (forms.ListTagsResponse, error)
+	GetTag(id int) (forms.Tag, error)
+	CreateTag(tag forms.TagRequest) (forms.Tag, error)
+	UpdateTag(id int, tag forms.TagRequest) (forms.Tag, error)
+	DeleteTag(id int) error
+	TagCloud(query forms.TagCloudQuery) (forms.TagCloudResponse, error)
+
+	ListCategories() (forms.ListCategoriesResponse, error)
+	GetCategory(id int) (forms.Category, error)
+	CreateCategory(category forms.CategoryRequest) (forms.Category, error)
+	UpdateCategory(id int, category forms.CategoryRequest) (forms.Category, error)
+	DeleteCategory(id int) error
+}
+
+// BlogInfo is what the controllers need to know about a blog to decide
+// whether a user may see or change it.
+//
+// AuthorID is 0 for blogs without an author. Status is one of the
+// forms.Status constants. Deleted is true if the blog is in the trash.
+type BlogInfo struct {
+	AuthorID int
+	Status   string
+	Deleted  bool
+}
+
+// CommentInfo is what the controllers need to know about a comment to decide
+// whether a user may change it.
+//
+// AuthorID is 0 for comments without an author. Deleted is true if the
+// comment is in the trash. Hidden is true if it can't be seen, because the
+// comment itself, one of the comments it replies to, or its blog is in the
+// trash.
+type CommentInfo struct {
+	AuthorID int
+	Deleted  bool
+	Hidden   bool
+}
+
+// These lines make the compiler check that both models implement BlogStore.
+var (
+	_ BlogStore = (*BlogModel)(nil)
+	_ BlogStore = (*MemoryBlogModel)(nil)
+)
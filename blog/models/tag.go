@@ -0,0 +1,272 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"blog/forms"
+	"blog/slug"
+)
+
+// tagName trims the name of a tag and returns it with its slug. It returns
+// an ErrValidation error if nothing is left of the name, or if the name has
+// no letters or digits: tags are identified by their slug, and all such
+// names would share one.
+func tagName(name string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "", newError(ErrValidation, "tag names can't be blank")
+	}
+	s := slug.Tag(name)
+	if s == "" {
+		return "", "", newError(ErrValidation, fmt.Sprintf("tag name %q needs a letter or a digit", name))
+	}
+	return name, s, nil
+}
+
+// tagNames returns the names and slugs of the tags of a blog, without the
+// names that have the same slug as an earlier one.
+func tagNames(names []string) ([]string, []string, error) {
+	seen := map[string]bool{}
+	var kept, slugs []string
+	for _, name := range names {
+		name, s, err := tagName(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		kept = append(kept, name)
+		slugs = append(slugs, s)
+	}
+	return kept, slugs, nil
+}
+
+// ListTags returns a page of the tags, sorted by name.
+func (m *BlogModel) ListTags(query forms.ListTagsQuery) (forms.ListTagsResponse, error) {
+	query.Normalize()
+
+	var total int
+	if err := m.db.QueryRow(`SELECT COUNT(*) FROM tags`).Scan(&total); err != nil {
+		return forms.ListTagsResponse{}, fmt.Errorf("failed to count tags: %w", err)
+	}
+
+	rows, err := m.db.Query(`
+		SELECT id, name, slug
+		FROM tags
+		ORDER BY name, id
+		LIMIT $1 OFFSET $2
+	`, query.Limit, query.Offset)
+	if err != nil {
+		return forms.ListTagsResponse{}, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer rows.Close() // Remember to close the rows when you're done with them!
+
+	tags := []forms.Tag{}
+	for rows.Next() {
+		var tag forms.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Slug); err != nil {
+			return forms.ListTagsResponse{}, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return forms.ListTagsResponse{}, fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	return newTagsPage(tags, query, total), nil
+}
+
+// newTagsPage returns a page of tags with its pagination.
+func newTagsPage(tags []forms.Tag, query forms.ListTagsQuery, total int) forms.ListTagsResponse {
+	return forms.ListTagsResponse{
+		Data: tags,
+		Pagination: forms.Pagination{
+			Limit:   query.Limit,
+			Offset:  query.Offset,
+			Total:   total,
+			HasMore: query.Offset+len(tags) < total,
+		},
+	}
+}
+
+// GetTag returns a single tag.
+func (m *BlogModel) GetTag(id int) (forms.Tag, error) {
+	var tag forms.Tag
+	if err := m.db.QueryRow(`
+		SELECT id, name, slug
+		FROM tags
+		WHERE id = $1
+	`, id).Scan(&tag.ID, &tag.Name, &tag.Slug); err != nil {
+		return forms.Tag{}, translate(err, "tag %d not found", id)
+	}
+
+	return tag, nil
+}
+
+// CreateTag creates a tag and returns it. It returns an ErrConflict error if
+// a tag with the same slug exists.
+func (m *BlogModel) CreateTag(req forms.TagRequest) (forms.Tag, error) {
+	name, s, err := tagName(req.Name)
+	if err != nil {
+		return forms.Tag{}, err
+	}
+
+	tag := forms.Tag{Name: name, Slug: s}
+	if err := m.db.QueryRow(`
+		INSERT INTO tags (name, slug)
+		VALUES ($1, $2)
+		RETURNING id
+	`, name, s).Scan(&tag.ID); err != nil {
+		return forms.Tag{}, translate(err, "a tag with the slug %q already exists", s)
+	}
+
+	return tag, nil
+}
+
+// UpdateTag renames a tag and returns it. The slug changes with the name. It
+// returns an ErrConflict error if another tag has the new slug.
+func (m *BlogModel) UpdateTag(id int, req forms.TagRequest) (forms.Tag, error) {
+	name, s, err := tagName(req.Name)
+	if err != nil {
+		return forms.Tag{}, err
+	}
+
+	result, err := m.db.Exec(`
+		UPDATE tags
+		SET name = $1, slug = $2
+		WHERE id = $3
+	`, name, s, id)
+	if err != nil {
+		return forms.Tag{}, translate(err, "a tag with the slug %q already exists", s)
+	}
+	if err := checkRowsAffected(result, "tag %d not found", id); err != nil {
+		return forms.Tag{}, err
+	}
+
+	return forms.Tag{ID: id, Name: name, Slug: s}, nil
+}
+
+// DeleteTag deletes a tag. The foreign key removes it from its blogs.
+func (m *BlogModel) DeleteTag(id int) error {
+	result, err := m.db.Exec(`DELETE FROM tags WHERE id = $1`, id)
+	if err != nil {
+		return translate(err, "failed to delete tag %d", id)
+	}
+
+	return checkRowsAffected(result, "tag %d not found", id)
+}
+
+// TagCloud returns the tags of the published blogs with the number of blogs
+// that have them, the most used first.
+func (m *BlogModel) TagCloud(query forms.TagCloudQuery) (forms.TagCloudResponse, error) {
+	query.Normalize()
+
+	rows, err := m.db.Query(`
+		SELECT t.id, t.name, t.slug, COUNT(*) AS count
+		FROM tags AS t
+		JOIN blog_tags AS bt ON bt.tag_id = t.id
+		JOIN blogs AS b ON b.id = bt.blog_id
+		WHERE b.status = $1 AND b.deleted_at IS NULL
+		GROUP BY t.id
+		ORDER BY count DESC, t.name, t.id
+		LIMIT $2
+	`, forms.StatusPublished, query.Limit)
+	if err != nil {
+		return forms.TagCloudResponse{}, fmt.Errorf("failed to get tag cloud: %w", err)
+	}
+	defer rows.Close() // Remember to close the rows when you're done with them!
+
+	cloud := forms.TagCloudResponse{Data: []forms.TagCount{}}
+	for rows.Next() {
+		var tag forms.TagCount
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Slug, &tag.Count); err != nil {
+			return forms.TagCloudResponse{}, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		cloud.Data = append(cloud.Data, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return forms.TagCloudResponse{}, fmt.Errorf("failed to get tag cloud: %w", err)
+	}
+
+	return cloud, nil
+}
+
+// setBlogTags replaces the tags of a blog with the tags with the given
+// names, as part of the transaction tx. Tags that don't exist yet are
+// created.
+func setBlogTags(tx *sql.Tx, blogID int, names []string) error {
+	names, slugs, err := tagNames(names)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM blog_tags WHERE blog_id = $1`, blogID); err != nil {
+		return translate(err, "failed to update the tags of blog %d", blogID)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	// ON CONFLICT DO NOTHING keeps the names of the tags that exist, so
+	// "go" is added to the tag "Go" rather than renaming it.
+	if _, err := tx.Exec(`
+		INSERT INTO tags (name, slug)
+		SELECT * FROM unnest($1::text[], $2::text[])
+		ON CONFLICT (slug) DO NOTHING
+	`, names, slugs); err != nil {
+		return translate(err, "failed to create tags")
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO blog_tags (blog_id, tag_id)
+		SELECT $1, id
+		FROM tags
+		WHERE slug = ANY($2)
+	`, blogID, slugs); err != nil {
+		return translate(err, "failed to update the tags of blog %d", blogID)
+	}
+
+	return nil
+}
+
+// blogTags returns the tags of the blogs with the given IDs, keyed by blog
+// ID and sorted by name. Blogs without tags get an empty list.
+func blogTags(q querier, ids []int) (map[int][]forms.Tag, error) {
+	tags := make(map[int][]forms.Tag, len(ids))
+	for _, id := range ids {
+		tags[id] = []forms.Tag{}
+	}
+	if len(ids) == 0 {
+		return tags, nil
+	}
+
+	rows, err := q.Query(`
+		SELECT bt.blog_id, t.id, t.name, t.slug
+		FROM blog_tags AS bt
+		JOIN tags AS t ON t.id = bt.tag_id
+		WHERE bt.blog_id = ANY($1)
+		ORDER BY t.name, t.id
+	`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var blogID int
+		var tag forms.Tag
+		if err := rows.Scan(&blogID, &tag.ID, &tag.Name, &tag.Slug); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags[blogID] = append(tags[blogID], tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,216 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RefreshToken is a refresh token as stored in the database.
+//
+// Hash is the hash of the token, never the token itself. FamilyID groups
+// the tokens that were obtained by rotating each other.
+type RefreshToken struct {
+	UserID    int
+	FamilyID  string
+	Hash      string
+	ExpiresAt time.Time
+}
+
+// TokenStore is the interface that the refresh token storage backends
+// implement.
+type TokenStore interface {
+	CreateRefreshToken(token RefreshToken) error
+	GetRefreshToken(hash string) (RefreshToken, error)
+	RotateRefreshToken(oldHash string, next RefreshToken) (RefreshToken, error)
+	RevokeRefreshTokenFamily(hash string) error
+	PurgeRefreshTokens(before time.Time, limit int) (int, error)
+}
+
+// These lines make the compiler check that both models implement TokenStore.
+var (
+	_ TokenStore = (*TokenModel)(nil)
+	_ TokenStore = (*MemoryTokenModel)(nil)
+)
+
+// TokenModel wraps a sql.DB connection pool.
+// It is the Postgres implementation of TokenStore.
+type TokenModel struct {
+	db *sql.DB
+}
+
+// NewTokenModel returns a new TokenModel.
+func NewTokenModel(db *sql.DB) *TokenModel {
+	return &TokenModel{db: db}
+}
+
+// CreateRefreshToken inserts a new refresh token into the database.
+func (m *TokenModel) CreateRefreshToken(token RefreshToken) error {
+	if _, err := m.db.Exec(`
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, token.UserID, token.FamilyID, token.Hash, token.ExpiresAt); err != nil {
+		return translate(err, "failed to create refresh token")
+	}
+
+	return nil
+}
+
+// GetRefreshToken returns the refresh token with the given hash, without
+// rotating it.
+//
+// It returns an ErrNotFound error if the token doesn't exist or has expired,
+// and an ErrConflict error if it has been revoked.
+func (m *TokenModel) GetRefreshToken(hash string) (RefreshToken, error) {
+	token := RefreshToken{Hash: hash}
+	var revoked sql.NullTime
+	if err := m.db.QueryRow(`
+		SELECT user_id, family_id, expires_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`, hash).Scan(&token.UserID, &token.FamilyID, &token.ExpiresAt, &revoked); err != nil {
+		return RefreshToken{}, translate(err, "refresh token not found")
+	}
+
+	if revoked.Valid {
+		return RefreshToken{}, newError(ErrConflict, "refresh token was revoked")
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return RefreshToken{}, newError(ErrNotFound, "refresh token has expired")
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken revokes the refresh token with the given hash and stores
+// the next one in its place. The user and family of next are taken from the
+// old token, and the completed next token is returned.
+//
+// It returns an ErrNotFound error if the old token doesn't exist or has
+// expired. If the old token has already been revoked, somebody is reusing it,
+// so the whole family is revoked and an ErrConflict error is returned.
+func (m *TokenModel) RotateRefreshToken(oldHash string, next RefreshToken) (RefreshToken, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return RefreshToken{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback is a no-op once the transaction is committed.
+
+	// FOR UPDATE locks the row, so that two requests using the same token at
+	// the same time can't both rotate it.
+	var revoked sql.NullTime
+	var expiresAt time.Time
+	if err := tx.QueryRow(`
+		SELECT user_id, family_id, expires_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, oldHash).Scan(&next.UserID, &next.FamilyID, &expiresAt, &revoked); err != nil {
+		return RefreshToken{}, translate(err, "refresh token not found")
+	}
+
+	if revoked.Valid {
+		if _, err := tx.Exec(`
+			UPDATE refresh_tokens
+			SET revoked_at = now()
+			WHERE family_id = $1 AND revoked_at IS NULL
+		`, next.FamilyID); err != nil {
+			return RefreshToken{}, fmt.Errorf("failed to revoke token family: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return RefreshToken{}, fmt.Errorf("failed to revoke token family: %w", err)
+		}
+		return RefreshToken{}, newError(ErrConflict, "refresh token was already used")
+	}
+
+	if time.Now().After(expiresAt) {
+		return RefreshToken{}, newError(ErrNotFound, "refresh token has expired")
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE refresh_tokens
+		SET revoked_at = now()
+		WHERE token_hash = $1
+	`, oldHash); err != nil {
+		return RefreshToken{}, fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, next.UserID, next.FamilyID, next.Hash, next.ExpiresAt); err != nil {
+		return RefreshToken{}, translate(err, "failed to create refresh token")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return RefreshToken{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return next, nil
+}
+
+// RevokeRefreshTokenFamily revokes the refresh token with the given hash and
+// all other tokens of its family. It is used to log out.
+//
+// It returns an ErrNotFound error if the token doesn't exist.
+func (m *TokenModel) RevokeRefreshTokenFamily(hash string) error {
+	var familyID string
+	err := m.db.QueryRow(`
+		SELECT family_id
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`, hash).Scan(&familyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(ErrNotFound, "refresh token not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if _, err := m.db.Exec(`
+		UPDATE refresh_tokens
+		SET revoked_at = now()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID); err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeRefreshTokens removes up to limit refresh tokens that can't be used
+// any more, oldest first, and returns how many were removed. These are the
+// tokens that expired before the given time, except for revoked tokens of a
+// family that has a token that hasn't expired yet: they are kept, so that
+// RotateRefreshToken can still tell that they are being reused.
+//
+// Like PurgeDeleted, it is safe to call from several instances at the same
+// time.
+func (m *TokenModel) PurgeRefreshTokens(before time.Time, limit int) (int, error) {
+	result, err := m.db.Exec(`
+		DELETE FROM refresh_tokens
+		WHERE id IN (
+			SELECT t.id
+			FROM refresh_tokens AS t
+			WHERE t.expires_at < $1
+				AND (t.revoked_at IS NULL OR NOT EXISTS (
+					SELECT 1
+					FROM refresh_tokens AS f
+					WHERE f.family_id = t.family_id AND f.expires_at >= $1
+				))
+			ORDER BY t.expires_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+	`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge refresh tokens: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge refresh tokens: %w", err)
+	}
+
+	return int(purged), nil
+}
@@ -0,0 +1,227 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"blog/forms"
+)
+
+// ListTrashedBlogs returns a page of the blogs in the trash, most recently
+// deleted first.
+func (m *BlogModel) ListTrashedBlogs(query forms.TrashQuery) (forms.ListTrashedBlogsResponse, error) {
+	query.Normalize()
+
+	var qb queryBuilder
+	qb.where("b.deleted_at IS NOT NULL")
+	if !query.ViewAll {
+		qb.where("b.author_id = " + qb.arg(query.ViewerID))
+	}
+
+	var total int
+	if err := m.db.QueryRow(
+		`SELECT COUNT(*) FROM blogs AS b `+qb.clause(),
+		qb.args...,
+	).Scan(&total); err != nil {
+		return forms.ListTrashedBlogsResponse{}, fmt.Errorf("failed to count deleted blogs: %w", err)
+	}
+
+	rows, err := m.db.Query(
+		fmt.Sprintf(`SELECT b.id, b.title, b.status, b.deleted_at, u.id, u.name
+		FROM blogs AS b
+		LEFT JOIN users AS u ON u.id = b.author_id
+		%s
+		ORDER BY b.deleted_at DESC, b.id DESC
+		LIMIT %s OFFSET %s`,
+			qb.clause(),
+			qb.arg(query.Limit), qb.arg(query.Offset),
+		),
+		qb.args...,
+	)
+	if err != nil {
+		return forms.ListTrashedBlogsResponse{}, fmt.Errorf("failed to get deleted blogs: %w", err)
+	}
+	defer rows.Close() // Remember to close the rows when you're done with them!
+
+	blogs := []forms.TrashedBlog{}
+	for rows.Next() {
+		var blog forms.TrashedBlog
+		var author authorColumns
+		if err := rows.Scan(&blog.ID, &blog.Title, &blog.Status, &blog.DeletedAt, &author.id, &author.name); err != nil {
+			return forms.ListTrashedBlogsResponse{}, fmt.Errorf("failed to scan blog: %w", err)
+		}
+		blog.Author = author.author()
+
+		blogs = append(blogs, blog)
+	}
+	if err := rows.Err(); err != nil {
+		return forms.ListTrashedBlogsResponse{}, fmt.Errorf("failed to get deleted blogs: %w", err)
+	}
+
+	return forms.ListTrashedBlogsResponse{
+		Data:       blogs,
+		Pagination: newTrashPagination(len(blogs), query, total),
+	}, nil
+}
+
+// ListTrashedComments returns a page of the comments in the trash, most
+// recently deleted first. Comments of blogs in the trash are left out; they
+// come back with their blog.
+func (m *BlogModel) ListTrashedComments(query forms.TrashQuery) (forms.ListTrashedCommentsResponse, error) {
+	query.Normalize()
+
+	var qb queryBuilder
+	qb.where("c.deleted_at IS NOT NULL")
+	qb.where("b.deleted_at IS NULL")
+	if !query.ViewAll {
+		qb.where("c.author_id = " + qb.arg(query.ViewerID))
+	}
+
+	var total int
+	if err := m.db.QueryRow(
+		`SELECT COUNT(*) FROM comments AS c JOIN blogs AS b ON b.id = c.blog_id `+qb.clause(),
+		qb.args...,
+	).Scan(&total); err != nil {
+		return forms.ListTrashedCommentsResponse{}, fmt.Errorf("failed to count deleted comments: %w", err)
+	}
+
+	rows, err := m.db.Query(
+		fmt.Sprintf(`SELECT c.id, c.blog_id, c.parent_id, c.content, c.deleted_at, u.id, u.name
+		FROM comments AS c
+		JOIN blogs AS b ON b.id = c.blog_id
+		LEFT JOIN users AS u ON u.id = c.author_id
+		%s
+		ORDER BY c.deleted_at DESC, c.id DESC
+		LIMIT %s OFFSET %s`,
+			qb.clause(),
+			qb.arg(query.Limit), qb.arg(query.Offset),
+		),
+		qb.args...,
+	)
+	if err != nil {
+		return forms.ListTrashedCommentsResponse{}, fmt.Errorf("failed to get deleted comments: %w", err)
+	}
+	defer rows.Close() // Remember to close the rows when you're done with them!
+
+	comments := []forms.TrashedComment{}
+	for rows.Next() {
+		var comment forms.TrashedComment
+		var parentID sql.NullInt64
+		var author authorColumns
+		if err := rows.Scan(&comment.ID, &comment.BlogID, &parentID, &comment.Content, &comment.DeletedAt, &author.id, &author.name); err != nil {
+			return forms.ListTrashedCommentsResponse{}, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			comment.ParentID = &id
+		}
+		comment.Author = author.author()
+
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return forms.ListTrashedCommentsResponse{}, fmt.Errorf("failed to get deleted comments: %w", err)
+	}
+
+	return forms.ListTrashedCommentsResponse{
+		Data:       comments,
+		Pagination: newTrashPagination(len(comments), query, total),
+	}, nil
+}
+
+// newTrashPagination returns the pagination of a page of n items in the
+// trash.
+func newTrashPagination(n int, query forms.TrashQuery, total int) forms.Pagination {
+	return forms.Pagination{
+		Limit:   query.Limit,
+		Offset:  query.Offset,
+		Total:   total,
+		HasMore: query.Offset+n < total,
+	}
+}
+
+// RestoreBlog takes a blog out of the trash, together with its comments.
+// It returns an ErrNotFound error if the blog isn't in the trash.
+func (m *BlogModel) RestoreBlog(id int) error {
+	result, err := m.db.Exec(`
+		UPDATE blogs
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`, id)
+	if err != nil {
+		return translate(err, "failed to restore blog %d", id)
+	}
+
+	return checkRowsAffected(result, "blog %d is not in the trash", id)
+}
+
+// RestoreComment takes a comment out of the trash, together with its
+// replies. It returns an ErrNotFound error if the comment isn't in the
+// trash, or if its blog is.
+func (m *BlogModel) RestoreComment(blogID, commentID int) error {
+	result, err := m.db.Exec(`
+		UPDATE comments AS c
+		SET deleted_at = NULL
+		FROM blogs AS b
+		WHERE c.id = $1 AND c.blog_id = $2 AND c.deleted_at IS NOT NULL
+			AND b.id = c.blog_id AND b.deleted_at IS NULL
+	`, commentID, blogID)
+	if err != nil {
+		return translate(err, "failed to restore comment %d", commentID)
+	}
+
+	return checkRowsAffected(result, "comment %d is not in the trash", commentID)
+}
+
+// PurgeDeleted permanently removes up to limit blogs and up to limit
+// comments that were moved to the trash before the given time, oldest first,
+// and returns how many were removed.
+//
+// The foreign keys remove the comments and revisions of the blogs, and the
+// replies of the comments, with them. These are not counted.
+//
+// Like PublishDueBlogs, it is safe to call from several instances at the
+// same time: FOR UPDATE SKIP LOCKED makes each instance skip the rows that
+// another one is removing.
+func (m *BlogModel) PurgeDeleted(before time.Time, limit int) (int, error) {
+	blogs, err := m.db.Exec(`
+		DELETE FROM blogs
+		WHERE id IN (
+			SELECT id
+			FROM blogs
+			WHERE deleted_at < $1
+			ORDER BY deleted_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+	`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted blogs: %w", err)
+	}
+	purgedBlogs, err := blogs.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted blogs: %w", err)
+	}
+
+	comments, err := m.db.Exec(`
+		DELETE FROM comments
+		WHERE id IN (
+			SELECT id
+			FROM comments
+			WHERE deleted_at < $1
+			ORDER BY deleted_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+	`, before, limit)
+	if err != nil {
+		return int(purgedBlogs), fmt.Errorf("failed to purge deleted comments: %w", err)
+	}
+	purgedComments, err := comments.RowsAffected()
+	if err != nil {
+		return int(purgedBlogs), fmt.Errorf("failed to purge deleted comments: %w", err)
+	}
+
+	return int(purgedBlogs + purgedComments), nil
+}
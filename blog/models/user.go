@@ -0,0 +1,162 @@
+package models
+
+import (
+	"database/sql"
+
+	"blog/forms"
+	"blog/policy"
+)
+
+// UserStore is the interface that the user storage backends implement.
+//
+// The password hash is passed and returned separately from forms.User, so
+// that it can never end up in a response by accident.
+type UserStore interface {
+	CreateUser(user forms.RegisterRequest, passwordHash string) (forms.User, error)
+	GetUserByID(id int) (forms.User, error)
+	GetUserByEmail(email string) (forms.User, string, error)
+	SetUserRole(id int, role policy.Role) (forms.User, error)
+}
+
+// These lines make the compiler check that both models implement UserStore.
+var (
+	_ UserStore = (*UserModel)(nil)
+	_ UserStore = (*MemoryUserModel)(nil)
+)
+
+// UserModel wraps a sql.DB connection pool.
+// It is the Postgres implementation of UserStore.
+type UserModel struct {
+	db *sql.DB
+}
+
+// NewUserModel returns a new UserModel.
+func NewUserModel(db *sql.DB) *UserModel {
+	return &UserModel{db: db}
+}
+
+// CreateUser inserts a new user into the database and returns it.
+//
+// It returns an ErrConflict error if the email address is already taken.
+// Email addresses are compared without regard to case.
+func (m *UserModel) CreateUser(user forms.RegisterRequest, passwordHash string) (forms.User, error) {
+	// RETURNING gives us the columns filled in by the database, such as the
+	// ID and the timestamps, without a second query.
+	//
+	// For more information on RETURNING, see:
+	// https://www.postgresql.org/docs/current/dml-returning.html
+	var created forms.User
+	if err := m.db.QueryRow(`
+		INSERT INTO users (email, name, password_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, email, name, role, created_at, updated_at
+	`, user.Email, user.Name, passwordHash).Scan(
+		&created.ID,
+		&created.Email,
+		&created.Name,
+		&created.Role,
+		&created.CreatedAt,
+		&created.UpdatedAt,
+	); err != nil {
+		// The unique index on lower(email) turns a duplicate email address
+		// into ErrConflict.
+		return forms.User{}, translate(err, "email address %s is already registered", user.Email)
+	}
+
+	return created, nil
+}
+
+// GetUserByID returns a single user from the database, based on its ID.
+func (m *UserModel) GetUserByID(id int) (forms.User, error) {
+	var user forms.User
+	if err := m.db.QueryRow(`
+		SELECT id, email, name, role, created_at, updated_at
+		FROM users
+		WHERE id = $1
+	`, id).Scan(
+		&user.ID,
+		&user.Email,
+		&user.Name,
+		&user.Role,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	); err != nil {
+		return forms.User{}, translate(err, "user %d not found", id)
+	}
+
+	return user, nil
+}
+
+// GetUserByEmail returns a single user and their password hash, based on the
+// email address. Email addresses are compared without regard to case.
+func (m *UserModel) GetUserByEmail(email string) (forms.User, string, error) {
+	var user forms.User
+	var passwordHash string
+	if err := m.db.QueryRow(`
+		SELECT id, email, name, role, created_at, updated_at, password_hash
+		FROM users
+		WHERE lower(email) = lower($1)
+	`, email).Scan(
+		&user.ID,
+		&user.Email,
+		&user.Name,
+		&user.Role,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&passwordHash,
+	); err != nil {
+		return forms.User{}, "", translate(err, "user %s not found", email)
+	}
+
+	return user, passwordHash, nil
+}
+
+// SetUserRole changes the role of a user and returns the updated user.
+func (m *UserModel) SetUserRole(id int, role policy.Role) (forms.User, error) {
+	var user forms.User
+	if err := m.db.QueryRow(`
+		UPDATE users
+		SET role = $2
+		WHERE id = $1
+		RETURNING id, email, name, role, created_at, updated_at
+	`, id, string(role)).Scan(
+		&user.ID,
+		&user.Email,
+		&user.Name,
+		&user.Role,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	); err != nil {
+		// The check constraint on role turns an unknown role into
+		// ErrValidation.
+		return forms.User{}, translate(err, "failed to set the role of user %d", id)
+	}
+
+	return user, nil
+}
+
+// nullID returns id as a nullable column value. IDs start at 1, so 0 means
+// "no ID" and is stored as NULL.
+func nullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id > 0}
+}
+
+// authorColumns holds the user columns of a LEFT JOIN with the users table.
+//
+// The columns are NULL when a blog or comment has no author, so we scan them
+// into sql.Null types rather than into an int and a string.
+//
+// For more information on sql.Null types, see:
+// https://golang.org/pkg/database/sql/#NullInt64
+type authorColumns struct {
+	id   sql.NullInt64
+	name sql.NullString
+}
+
+// author returns the scanned author, or nil if there is none.
+func (a authorColumns) author() *forms.Author {
+	if !a.id.Valid {
+		return nil
+	}
+	return &forms.Author{ID: int(a.id.Int64), Name: a.name.String}
+}
@@ -0,0 +1,131 @@
+// Package policy decides who may do what with blogs, comments and accounts.
+//
+// All the rules are declared in one table, rules, so that they can be read
+// (and changed) in one place. The controllers ask Allowed before changing
+// anything, and the rules themselves don't know about HTTP or the database,
+// so they can be checked in isolation.
+package policy
+
+// Role is the role of a user. Every user has exactly one role.
+type Role string
+
+// The roles, from most to least powerful.
+//
+//   - Admins can do anything, including changing the roles of other users.
+//   - Editors can edit any blog, remove any comment, and manage the tags and
+//     categories.
+//   - Authors can write blogs, and edit and delete their own.
+//   - Commenters can only write comments.
+//
+// Everybody can edit and delete their own comments.
+const (
+	RoleAdmin     Role = "admin"
+	RoleEditor    Role = "editor"
+	RoleAuthor    Role = "author"
+	RoleCommenter Role = "commenter"
+)
+
+// DefaultRole is the role of new users.
+const DefaultRole = RoleAuthor
+
+// Roles lists all the roles.
+var Roles = []Role{RoleAdmin, RoleEditor, RoleAuthor, RoleCommenter}
+
+// Valid reports whether r is one of the roles.
+func (r Role) Valid() bool {
+	return hasRole(Roles, r)
+}
+
+// Action is something a user wants to do.
+type Action string
+
+// The actions that are checked.
+const (
+	CreateBlog     Action = "blog:create"
+	UpdateBlog     Action = "blog:update"
+	DeleteBlog     Action = "blog:delete"
+	PublishBlog    Action = "blog:publish"
+	ViewDraft      Action = "blog:view-draft"
+	CreateComment  Action = "comment:create"
+	UpdateComment  Action = "comment:update"
+	DeleteComment  Action = "comment:delete"
+	ManageTaxonomy Action = "taxonomy:manage"
+	ManageUsers    Action = "user:manage"
+)
+
+// User is the user who wants to do something.
+// The zero User is an anonymous user, who may do nothing.
+type User struct {
+	ID   int
+	Role Role
+}
+
+// rule says which roles may do an action.
+//
+// any lists the roles that may do it to every resource. own lists the roles
+// that may only do it to resources they own.
+type rule struct {
+	any []Role
+	own []Role
+}
+
+// rules are the access rules, one per action.
+//
+// An action that isn't in the table is denied to everybody. Admins don't need
+// to be listed: they may do anything.
+var rules = map[Action]rule{
+	CreateBlog: {any: []Role{RoleEditor, RoleAuthor}},
+	UpdateBlog: {any: []Role{RoleEditor}, own: []Role{RoleAuthor}},
+	DeleteBlog: {own: []Role{RoleEditor, RoleAuthor}},
+
+	// Publishing covers unpublishing and archiving. Blogs that aren't
+	// published (drafts, for example) can only be seen by those who may
+	// publish them.
+	PublishBlog: {any: []Role{RoleEditor}, own: []Role{RoleAuthor}},
+	ViewDraft:   {any: []Role{RoleEditor}, own: Roles},
+
+	CreateComment: {any: Roles},
+	UpdateComment: {own: Roles},
+	DeleteComment: {any: []Role{RoleEditor}, own: Roles},
+
+	// Tags and categories are shared by all blogs, so only editors manage
+	// them. Authors can still add new tags to their own blogs.
+	ManageTaxonomy: {any: []Role{RoleEditor}},
+
+	ManageUsers: {},
+}
+
+// Allowed reports whether the user may do the action to a resource owned by
+// the user with ID ownerID.
+//
+// ownerID is 0 for resources without an owner, such as blogs written before
+// accounts existed, and for actions that don't have a resource yet, such as
+// CreateBlog. Only the "any" roles may act on those.
+func Allowed(user User, action Action, ownerID int) bool {
+	if user.ID < 1 || !user.Role.Valid() {
+		return false
+	}
+	if user.Role == RoleAdmin {
+		return true
+	}
+
+	r, ok := rules[action]
+	if !ok {
+		return false
+	}
+
+	if hasRole(r.any, user.Role) {
+		return true
+	}
+	return ownerID > 0 && ownerID == user.ID && hasRole(r.own, user.Role)
+}
+
+// hasRole reports whether role is in roles.
+func hasRole(roles []Role, role Role) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package policy
+
+import "testing"
+
+// The users of the tests. They all have ID 1, so they own the resources
+// owned by 1 and not those owned by 2.
+const (
+	self  = 1
+	other = 2
+)
+
+func TestAllowed(t *testing.T) {
+	// For every action, the roles that may do it to somebody else's
+	// resource and to their own. The table is written out rather than
+	// derived from rules, so that a change to the rules must be made here
+	// too.
+	tests := []struct {
+		action Action
+		others []Role
+		own    []Role
+	}{
+		{CreateBlog, []Role{RoleAdmin, RoleEditor, RoleAuthor}, []Role{RoleAdmin, RoleEditor, RoleAuthor}},
+		{UpdateBlog, []Role{RoleAdmin, RoleEditor}, []Role{RoleAdmin, RoleEditor, RoleAuthor}},
+		{DeleteBlog, []Role{RoleAdmin}, []Role{RoleAdmin, RoleEditor, RoleAuthor}},
+		{PublishBlog, []Role{RoleAdmin, RoleEditor}, []Role{RoleAdmin, RoleEditor, RoleAuthor}},
+		{ViewDraft, []Role{RoleAdmin, RoleEditor}, Roles},
+		{CreateComment, Roles, Roles},
+		{UpdateComment, []Role{RoleAdmin}, Roles},
+		{DeleteComment, []Role{RoleAdmin, RoleEditor}, Roles},
+		{ManageTaxonomy, []Role{RoleAdmin, RoleEditor}, []Role{RoleAdmin, RoleEditor}},
+		{ManageUsers, []Role{RoleAdmin}, []Role{RoleAdmin}},
+	}
+
+	if len(tests) != len(rules) {
+		t.Fatalf("the test covers %d actions, but there are rules for %d", len(tests), len(rules))
+	}
+
+	for _, tt := range tests {
+		for _, role := range Roles {
+			user := User{ID: self, Role: role}
+
+			want := hasRole(tt.others, role)
+			if got := Allowed(user, tt.action, other); got != want {
+				t.Errorf("Allowed(%s, %s, other's) = %v; want %v", role, tt.action, got, want)
+			}
+
+			want = hasRole(tt.own, role)
+			if got := Allowed(user, tt.action, self); got != want {
+				t.Errorf("Allowed(%s, %s, own) = %v; want %v", role, tt.action, got, want)
+			}
+
+			// Resources without an owner are treated like somebody else's.
+			want = hasRole(tt.others, role)
+			if got := Allowed(user, tt.action, 0); got != want {
+				t.Errorf("Allowed(%s, %s, no owner) = %v; want %v", role, tt.action, got, want)
+			}
+		}
+	}
+}
+
+func TestAllowedAnonymous(t *testing.T) {
+	// The zero User is anonymous. A user with ID 0 owns nothing either,
+	// whatever role it claims, and neither does a user with an unknown role.
+	users := []User{
+		{},
+		{ID: 0, Role: RoleAdmin},
+		{ID: self, Role: ""},
+		{ID: self, Role: "superuser"},
+	}
+
+	for _, user := range users {
+		for action := range rules {
+			for _, owner := range []int{0, self, other} {
+				if Allowed(user, action, owner) {
+					t.Errorf("Allowed(%+v, %s, %d) = true; want false", user, action, owner)
+				}
+			}
+		}
+	}
+}
+
+func TestAllowedUnknownAction(t *testing.T) {
+	for _, role := range Roles {
+		user := User{ID: self, Role: role}
+		want := role == RoleAdmin
+		if got := Allowed(user, "blog:frobnicate", self); got != want {
+			t.Errorf("Allowed(%s, unknown action) = %v; want %v", role, got, want)
+		}
+	}
+}
+
+func TestRoleValid(t *testing.T) {
+	for _, role := range Roles {
+		if !role.Valid() {
+			t.Errorf("%q.Valid() = false; want true", role)
+		}
+	}
+	for _, role := range []Role{"", "Admin", "root"} {
+		if role.Valid() {
+			t.Errorf("%q.Valid() = true; want false", role)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package scheduler
+
+import "time"
+
+// Clock tells the time and waits.
+//
+// The scheduler only uses the time through a Clock, so that it can be driven
+// by a fake clock that is moved forward by hand, rather than by waiting for
+// real time to pass.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel that receives the time once d has passed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock that uses the real time.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After returns time.After(d).
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
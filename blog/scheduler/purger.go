@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Trash is the part of models.BlogStore that the purger uses.
+type Trash interface {
+	PurgeDeleted(before time.Time, limit int) (int, error)
+}
+
+// Tokens is the part of models.TokenStore that the purger uses.
+type Tokens interface {
+	PurgeRefreshTokens(before time.Time, limit int) (int, error)
+}
+
+// Purger regularly removes the blogs and comments that have been in the
+// trash for longer than the retention period, and the refresh tokens that
+// can't be used any more.
+//
+// Start and Stop start and stop it in the background.
+type Purger struct {
+	runner
+
+	trash     Trash
+	tokens    Tokens
+	retention time.Duration
+	batchSize int
+}
+
+// NewPurger creates a new Purger that empties the trash every interval. It
+// removes the items that were deleted more than retention ago, at most
+// batchSize blogs and batchSize comments per statement, and the refresh
+// tokens that have expired, batchSize at a time.
+//
+// Use SystemClock{} as the clock, except when driving the purger by hand.
+func NewPurger(trash Trash, tokens Tokens, clock Clock, interval, retention time.Duration, batchSize int) *Purger {
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+
+	p := &Purger{
+		runner:    runner{name: "purger", clock: clock, interval: interval},
+		trash:     trash,
+		tokens:    tokens,
+		retention: retention,
+		batchSize: batchSize,
+	}
+	p.job = p.RunOnce
+
+	return p
+}
+
+// RunOnce removes everything that has been in the trash for longer than the
+// retention period, and then the refresh tokens that have expired, in
+// batches of batchSize. It returns how many blogs, comments and tokens were
+// removed.
+//
+// It stops early, between batches, if ctx is cancelled.
+func (p *Purger) RunOnce(ctx context.Context) (int, error) {
+	// The cut-offs are fixed before the first batch, so that items deleted
+	// while purging don't keep the loop going.
+	now := p.clock.Now()
+
+	// PurgeDeleted removes up to batchSize blogs and up to batchSize
+	// comments. If it removed fewer than batchSize in total, neither batch
+	// was full and the trash is empty up to the cut-off.
+	deleted, err := p.purge(ctx, func() (int, error) {
+		return p.trash.PurgeDeleted(now.Add(-p.retention), p.batchSize)
+	})
+	if deleted > 0 {
+		log.Printf("purger: removed %d deleted blogs and comments", deleted)
+	}
+	if err != nil {
+		return deleted, err
+	}
+
+	tokens, err := p.purge(ctx, func() (int, error) {
+		return p.tokens.PurgeRefreshTokens(now, p.batchSize)
+	})
+	if tokens > 0 {
+		log.Printf("purger: removed %d expired refresh tokens", tokens)
+	}
+
+	return deleted + tokens, err
+}
+
+// purge calls batch until it removes fewer than batchSize items, and
+// returns how many it removed in total.
+func (p *Purger) purge(ctx context.Context, batch func() (int, error)) (int, error) {
+	purged := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+
+		n, err := batch()
+		if err != nil {
+			return purged, err
+		}
+		purged += n
+
+		if n < p.batchSize {
+			return purged, nil
+		}
+	}
+}
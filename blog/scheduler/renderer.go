@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+)
+
+// HTMLCache is the part of models.BlogStore that the renderer uses.
+type HTMLCache interface {
+	RenderStaleHTML(limit int) (int, error)
+}
+
+// Renderer renders the HTML of the blogs whose cached HTML is stale, because
+// it was rendered by an older version of the markdown package, and caches
+// it. Until it has, that HTML is rendered on every read.
+//
+// The HTML only becomes stale with a new release, so the Renderer runs once
+// when it is started rather than every interval. Start and Stop start and
+// stop it in the background.
+type Renderer struct {
+	runner
+
+	cache     HTMLCache
+	batchSize int
+}
+
+// NewRenderer creates a new Renderer that renders at most batchSize blogs
+// per transaction.
+func NewRenderer(cache HTMLCache, batchSize int) *Renderer {
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+
+	r := &Renderer{
+		runner:    runner{name: "renderer", clock: SystemClock{}},
+		cache:     cache,
+		batchSize: batchSize,
+	}
+	r.job = r.RunOnce
+
+	return r
+}
+
+// RunOnce renders the stale HTML of all blogs, in batches of batchSize, and
+// returns how many blogs were rendered.
+//
+// It stops early, between batches, if ctx is cancelled.
+func (r *Renderer) RunOnce(ctx context.Context) (int, error) {
+	rendered := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return rendered, err
+		}
+
+		n, err := r.cache.RenderStaleHTML(r.batchSize)
+		if err != nil {
+			return rendered, err
+		}
+		rendered += n
+
+		if n < r.batchSize {
+			if rendered > 0 {
+				log.Printf("renderer: cached the HTML of %d blogs", rendered)
+			}
+			return rendered, nil
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// runner calls a job every interval in a background goroutine. It is the
+// part that the Scheduler, the Purger and the Renderer have in common.
+type runner struct {
+	name  string
+	clock Clock
+
+	// interval is the time between two rounds of the job. If it is 0, the
+	// job runs once.
+	interval time.Duration
+
+	// job does one round of work and returns how much it did.
+	job func(ctx context.Context) (int, error)
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start starts the job in a new goroutine. It runs until ctx is cancelled or
+// Stop is called.
+//
+// It returns an error if the job is already running.
+func (r *runner) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.done != nil {
+		return errors.New("the " + r.name + " is already running")
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+
+	go r.loop(ctx, r.done)
+
+	return nil
+}
+
+// Stop stops the job and waits until it has finished the current batch, or
+// until ctx is done. It does nothing if the job isn't running.
+func (r *runner) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel, r.done = nil, nil
+	r.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// loop calls the job every interval until ctx is cancelled. It closes done
+// when it returns.
+func (r *runner) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	for {
+		// Run first and then wait, so that work that became due while the
+		// service was down is done right after it starts.
+		if _, err := r.job(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("%s: %v", r.name, err)
+		}
+		if r.interval == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.clock.After(r.interval):
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package scheduler runs the background jobs of the service: it publishes
+// scheduled blogs when they are due, empties the trash, and renders stale
+// HTML.
+//
+// The Scheduler runs in a background goroutine next to the HTTP server. Every
+// interval it asks the store to publish the scheduled blogs whose publish_at
+// has passed. It is safe to run one Scheduler in each instance of the
+// service: the Postgres store makes sure that every blog is published exactly
+// once (see models.BlogModel.PublishDueBlogs).
+//
+// The Purger works in the same way. It permanently removes the blogs and
+// comments that have been in the trash for longer than the retention period.
+//
+// The Renderer runs once, at startup. It caches the HTML of the blogs whose
+// cached HTML was rendered by an older version of the markdown package.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// DefaultBatchSize is the batch size used when none is configured.
+const DefaultBatchSize = 100
+
+// Publisher is the part of models.BlogStore that the scheduler uses.
+type Publisher interface {
+	PublishDueBlogs(now time.Time, limit int) ([]int, error)
+}
+
+// Scheduler regularly publishes the scheduled blogs that are due.
+//
+// Start and Stop start and stop it in the background.
+type Scheduler struct {
+	runner
+
+	publisher Publisher
+	batchSize int
+}
+
+// NewScheduler creates a new Scheduler that checks for due blogs every
+// interval and publishes at most batchSize blogs per transaction.
+//
+// Use SystemClock{} as the clock, except when driving the scheduler by hand.
+func NewScheduler(publisher Publisher, clock Clock, interval time.Duration, batchSize int) *Scheduler {
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+
+	s := &Scheduler{
+		runner:    runner{name: "scheduler", clock: clock, interval: interval},
+		publisher: publisher,
+		batchSize: batchSize,
+	}
+	s.job = s.RunOnce
+
+	return s
+}
+
+// RunOnce publishes all the scheduled blogs that are due now, in batches of
+// batchSize, and returns how many were published.
+//
+// It stops early, between batches, if ctx is cancelled.
+func (s *Scheduler) RunOnce(ctx context.Context) (int, error) {
+	published := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return published, err
+		}
+
+		ids, err := s.publisher.PublishDueBlogs(s.clock.Now(), s.batchSize)
+		if err != nil {
+			return published, err
+		}
+		for _, id := range ids {
+			log.Printf("scheduler: published blog %d", id)
+		}
+		published += len(ids)
+
+		// A batch that isn't full was the last one.
+		if len(ids) < s.batchSize {
+			return published, nil
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+
+	// waiting receives a value every time After is called, so that a test
+	// can wait until the runner is idle before it moves the clock.
+	waiting chan struct{}
+}
+
+// waiter is a call to After that hasn't fired yet.
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:     time.Date(2030, 1, 31, 9, 0, 0, 0, time.UTC),
+		waiting: make(chan struct{}, 100),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), ch: ch})
+	c.waiting <- struct{}{}
+	return ch
+}
+
+// Advance moves the clock forward by d and fires the waiters that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = pending
+}
+
+// fakePublisher is an in-memory Publisher. It publishes the blogs in
+// scheduled whose time has come, lowest ID first.
+type fakePublisher struct {
+	mu        sync.Mutex
+	scheduled map[int]time.Time
+	published []int
+	calls     []int // the number of blogs published by each call
+
+	// If started is set, every call sends to it and then waits for
+	// release, so that a test can catch the scheduler in the middle of a
+	// batch.
+	started chan struct{}
+	release chan struct{}
+}
+
+func newFakePublisher() *fakePublisher {
+	return &fakePublisher{scheduled: map[int]time.Time{}}
+}
+
+func (p *fakePublisher) schedule(id int, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.scheduled[id] = at
+}
+
+func (p *fakePublisher) PublishDueBlogs(now time.Time, limit int) ([]int, error) {
+	if p.started != nil {
+		p.started <- struct{}{}
+		<-p.release
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var due []int
+	for id, at := range p.scheduled {
+		if !at.After(now) {
+			due = append(due, id)
+		}
+	}
+	sort.Ints(due)
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for _, id := range due {
+		delete(p.scheduled, id)
+	}
+	p.published = append(p.published, due...)
+	p.calls = append(p.calls, len(due))
+
+	return due, nil
+}
+
+func (p *fakePublisher) state() (published, calls []int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]int(nil), p.published...), append([]int(nil), p.calls...)
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRunOncePublishesDueBlogs(t *testing.T) {
+	clock := newFakeClock()
+	publisher := newFakePublisher()
+	publisher.schedule(1, clock.Now().Add(-time.Hour))
+	publisher.schedule(2, clock.Now())
+	publisher.schedule(3, clock.Now().Add(time.Hour))
+
+	s := NewScheduler(publisher, clock, time.Minute, 10)
+
+	n, err := s.RunOnce(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("RunOnce() = %d, %v; want 2, nil", n, err)
+	}
+	if published, _ := publisher.state(); !equalInts(published, []int{1, 2}) {
+		t.Fatalf("published %v; want [1 2]", published)
+	}
+
+	// Nothing else is due until the clock reaches the third blog.
+	if n, err := s.RunOnce(context.Background()); err != nil || n != 0 {
+		t.Fatalf("RunOnce() = %d, %v; want 0, nil", n, err)
+	}
+
+	clock.Advance(time.Hour)
+	if n, err := s.RunOnce(context.Background()); err != nil || n != 1 {
+		t.Fatalf("RunOnce() after an hour = %d, %v; want 1, nil", n, err)
+	}
+	if published, _ := publisher.state(); !equalInts(published, []int{1, 2, 3}) {
+		t.Fatalf("published %v; want [1 2 3]", published)
+	}
+}
+
+func TestRunOnceBatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		due   int
+		batch int
+		calls []int
+	}{
+		{"nothing due", 0, 2, []int{0}},
+		{"one partial batch", 1, 2, []int{1}},
+		{"last batch partial", 5, 2, []int{2, 2, 1}},
+		// When the last batch is exactly full, one more call is needed to
+		// find out that there is nothing left.
+		{"last batch full", 4, 2, []int{2, 2, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			publisher := newFakePublisher()
+			for id := 1; id <= tt.due; id++ {
+				publisher.schedule(id, clock.Now())
+			}
+
+			s := NewScheduler(publisher, clock, time.Minute, tt.batch)
+			n, err := s.RunOnce(context.Background())
+			if err != nil || n != tt.due {
+				t.Fatalf("RunOnce() = %d, %v; want %d, nil", n, err, tt.due)
+			}
+			if _, calls := publisher.state(); !equalInts(calls, tt.calls) {
+				t.Errorf("batches %v; want %v", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestRunOnceCancelled(t *testing.T) {
+	clock := newFakeClock()
+	publisher := newFakePublisher()
+	publisher.schedule(1, clock.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := NewScheduler(publisher, clock, time.Minute, 10)
+	if n, err := s.RunOnce(ctx); n != 0 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunOnce() = %d, %v; want 0, context.Canceled", n, err)
+	}
+	if _, calls := publisher.state(); len(calls) != 0 {
+		t.Errorf("PublishDueBlogs was called %d times; want 0", len(calls))
+	}
+}
+
+func TestStartRunsEveryInterval(t *testing.T) {
+	clock := newFakeClock()
+	publisher := newFakePublisher()
+	publisher.schedule(1, clock.Now())
+	publisher.schedule(2, clock.Now().Add(30*time.Second))
+
+	s := NewScheduler(publisher, clock, time.Minute, 10)
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	defer s.Stop(context.Background())
+
+	if err := s.Start(context.Background()); err == nil {
+		t.Error("second Start() = nil; want an error")
+	}
+
+	// The first round runs right away; then the scheduler waits.
+	<-clock.waiting
+	if published, _ := publisher.state(); !equalInts(published, []int{1}) {
+		t.Fatalf("published %v after the first round; want [1]", published)
+	}
+
+	// Blog 2 is due after 30s, but the next round is after a minute.
+	clock.Advance(30 * time.Second)
+	if published, _ := publisher.state(); !equalInts(published, []int{1}) {
+		t.Fatalf("published %v before the interval passed; want [1]", published)
+	}
+
+	clock.Advance(30 * time.Second)
+	<-clock.waiting
+	if published, _ := publisher.state(); !equalInts(published, []int{1, 2}) {
+		t.Fatalf("published %v after the second round; want [1 2]", published)
+	}
+}
+
+func TestStopWaitsForCurrentBatch(t *testing.T) {
+	clock := newFakeClock()
+	publisher := newFakePublisher()
+	publisher.started = make(chan struct{})
+	publisher.release = make(chan struct{})
+	publisher.schedule(1, clock.Now())
+
+	s := NewScheduler(publisher, clock, time.Minute, 10)
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	<-publisher.started
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Stop(context.Background())
+	}()
+
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop() = %v before the batch finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(publisher.release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop() = %v; want nil", err)
+	}
+
+	// The batch that was running when Stop was called was finished, and no
+	// other batch was started.
+	if published, calls := publisher.state(); !equalInts(published, []int{1}) || len(calls) != 1 {
+		t.Errorf("published %v in %d batches; want [1] in 1", published, len(calls))
+	}
+
+	// Stopping a stopped scheduler does nothing.
+	if err := s.Stop(context.Background()); err != nil {
+		t.Errorf("second Stop() = %v; want nil", err)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	clock := newFakeClock()
+	publisher := newFakePublisher()
+	publisher.started = make(chan struct{})
+	publisher.release = make(chan struct{})
+	defer close(publisher.release)
+
+	s := NewScheduler(publisher, clock, time.Minute, 10)
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	<-publisher.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stop() = %v; want context.DeadlineExceeded", err)
+	}
+}
+
+// fakeTrash is an in-memory Trash that records the cut-offs it was given.
+type fakeTrash struct {
+	deleted []time.Time
+	cutoffs []time.Time
+}
+
+func (f *fakeTrash) PurgeDeleted(before time.Time, limit int) (int, error) {
+	f.cutoffs = append(f.cutoffs, before)
+
+	kept := f.deleted[:0]
+	n := 0
+	for _, at := range f.deleted {
+		if n < limit && at.Before(before) {
+			n++
+		} else {
+			kept = append(kept, at)
+		}
+	}
+	f.deleted = kept
+	return n, nil
+}
+
+// fakeTokens is an in-memory Tokens with the expiry times of its tokens.
+type fakeTokens struct {
+	fakeTrash
+}
+
+func (f *fakeTokens) PurgeRefreshTokens(before time.Time, limit int) (int, error) {
+	return f.PurgeDeleted(before, limit)
+}
+
+func TestPurgerRunOnce(t *testing.T) {
+	clock := newFakeClock()
+	retention := 30 * 24 * time.Hour
+	trash := &fakeTrash{deleted: []time.Time{
+		clock.Now().Add(-retention - time.Hour),
+		clock.Now().Add(-retention - time.Minute),
+		clock.Now().Add(-retention - time.Second),
+		clock.Now().Add(-time.Hour),
+	}}
+	tokens := &fakeTokens{fakeTrash{deleted: []time.Time{
+		clock.Now().Add(-time.Hour),
+		clock.Now().Add(-time.Second),
+		clock.Now().Add(time.Hour),
+	}}}
+
+	p := NewPurger(trash, tokens, clock, time.Hour, retention, 2)
+	n, err := p.RunOnce(context.Background())
+	if err != nil || n != 5 {
+		t.Fatalf("RunOnce() = %d, %v; want 5, nil", n, err)
+	}
+	if len(trash.deleted) != 1 {
+		t.Errorf("%d items left in the trash; want 1", len(trash.deleted))
+	}
+	if len(tokens.deleted) != 1 {
+		t.Errorf("%d refresh tokens left; want 1", len(tokens.deleted))
+	}
+
+	// Every batch uses the same cut-off, fixed before the first one.
+	want := clock.Now().Add(-retention)
+	for i, cutoff := range trash.cutoffs {
+		if !cutoff.Equal(want) {
+			t.Errorf("batch %d: cut-off %v; want %v", i, cutoff, want)
+		}
+	}
+
+	// Tokens are removed as soon as they expire.
+	for i, cutoff := range tokens.cutoffs {
+		if !cutoff.Equal(clock.Now()) {
+			t.Errorf("token batch %d: cut-off %v; want %v", i, cutoff, clock.Now())
+		}
+	}
+}
+
+// fakeCache is an in-memory HTMLCache with a number of stale blogs.
+type fakeCache struct {
+	mu    sync.Mutex
+	stale int
+	calls int
+}
+
+func (f *fakeCache) RenderStaleHTML(limit int) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	n := f.stale
+	if n > limit {
+		n = limit
+	}
+	f.stale -= n
+	return n, nil
+}
+
+func TestRendererRunsOnce(t *testing.T) {
+	cache := &fakeCache{stale: 5}
+
+	r := NewRenderer(cache, 2)
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+
+	// The renderer stops by itself after one round.
+	r.mu.Lock()
+	done := r.done
+	r.mu.Unlock()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the renderer didn't stop after one round")
+	}
+	if err := r.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.stale != 0 || cache.calls != 3 {
+		t.Errorf("%d stale blogs left after %d batches; want 0 after 3", cache.stale, cache.calls)
+	}
+}
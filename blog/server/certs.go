@@ -0,0 +1,110 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader holds the TLS certificate of the server, and loads it again
+// when the files on disk change. Certificates are rotated before they
+// expire, for example by certbot, and the server picks up the new one
+// without a restart.
+//
+// The files are checked when a client connects, at most once per interval,
+// so there is no goroutine to stop and no check while nobody connects.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// newCertReloader loads the certificate and returns a certReloader for it.
+// It returns an error if the certificate can't be loaded, so that a wrong
+// path or a broken file is reported at startup.
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+	}
+
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the current certificate. It is called by the TLS
+// handshake of every new connection.
+//
+// If loading a changed certificate fails, for example because only one of
+// the two files has been replaced yet, the old certificate is kept and the
+// files are checked again after the next interval.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if r.interval > 0 && now.Sub(r.checkedAt) >= r.interval {
+		r.checkedAt = now
+
+		modTime, err := r.filesModTime()
+		if err != nil {
+			log.Printf("failed to check TLS certificate: %v", err)
+		} else if !modTime.Equal(r.modTime) {
+			if err := r.load(modTime); err != nil {
+				log.Printf("failed to reload TLS certificate: %v", err)
+			} else {
+				log.Printf("reloaded TLS certificate from %s", r.certFile)
+			}
+		}
+	}
+
+	return r.cert, nil
+}
+
+// load loads the certificate and its key. The caller must hold the lock,
+// except in newCertReloader.
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	r.cert = &cert
+	r.modTime = modTime
+	r.checkedAt = time.Now()
+
+	return nil
+}
+
+// filesModTime returns the time the certificate or the key was last
+// modified, whichever is later.
+func (r *certReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
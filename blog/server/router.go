@@ -5,14 +5,30 @@ package server
 
 import (
 	"blog/controllers"
+	"blog/middleware"
+	"blog/web"
 
 	"github.com/gin-gonic/gin"
 )
 
 // NewRouter creates a new router.
-func NewRouter(blogCtrl *controllers.BlogController) *gin.Engine {
+func NewRouter(blogCtrl *controllers.BlogController, userCtrl *controllers.UserController, site *web.Site) *gin.Engine {
 	// Create a new router.
-	r := gin.Default()
+	// gin.New creates a router without any middleware, unlike gin.Default,
+	// so that we can replace the default recovery middleware with one that
+	// writes a problem details response.
+	r := gin.New()
+	r.Use(
+		middleware.RequestID(),
+		gin.Logger(),
+		gin.CustomRecovery(controllers.Recovery),
+	)
+
+	// Requests that match no route, or a route with another method, get the
+	// same problem details responses as the rest of the API.
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(controllers.NoRoute)
+	r.NoMethod(controllers.NoMethod)
 
 	// Register the health route.
 	r.GET("/health", blogCtrl.Health)
@@ -29,13 +45,112 @@ func NewRouter(blogCtrl *controllers.BlogController) *gin.Engine {
 	// https://godoc.org/github.com/gin-gonic/gin#RouterGroup
 	blogs := r.Group("/blogs")
 	{
-		blogs.POST("", blogCtrl.CreateBlog)
-		blogs.GET("", blogCtrl.GetAllBlogs)
-		blogs.GET("/:id", blogCtrl.GetBlogByID)
-		blogs.PUT("/:id", blogCtrl.UpdateBlog)
-		blogs.DELETE("/:id", blogCtrl.DeleteBlog)
-		blogs.POST("/:id/comments", blogCtrl.CreateComment)
+		// Anyone can read published blogs. OptionalAuth lets logged-in
+		// users see their drafts too.
+		blogs.GET("", userCtrl.OptionalAuth, blogCtrl.GetAllBlogs)
+		blogs.GET("/:id", userCtrl.OptionalAuth, blogCtrl.GetBlogByID)
+		blogs.GET("/by-slug/:slug", userCtrl.OptionalAuth, blogCtrl.GetBlogBySlug)
+
+		// Only logged-in users can change them. The RequireAuth middleware
+		// runs before the handler and rejects requests without a valid
+		// access token.
+		blogs.POST("", userCtrl.RequireAuth, blogCtrl.CreateBlog)
+		blogs.PUT("/:id", userCtrl.RequireAuth, blogCtrl.UpdateBlog)
+		blogs.DELETE("/:id", userCtrl.RequireAuth, blogCtrl.DeleteBlog)
+		blogs.POST("/:id/restore", userCtrl.RequireAuth, blogCtrl.RestoreBlog)
+		blogs.POST("/:id/comments", userCtrl.RequireAuth, blogCtrl.CreateComment)
+
+		// Comments can be read by anyone, and changed by their authors and
+		// by editors.
+		blogs.GET("/:id/comments", userCtrl.OptionalAuth, blogCtrl.ListComments)
+		blogs.PUT("/:id/comments/:commentId", userCtrl.RequireAuth, blogCtrl.UpdateComment)
+		blogs.DELETE("/:id/comments/:commentId", userCtrl.RequireAuth, blogCtrl.DeleteComment)
+		blogs.POST("/:id/comments/:commentId/restore", userCtrl.RequireAuth, blogCtrl.RestoreComment)
+		blogs.POST("/:id/comments/:commentId/replies", userCtrl.RequireAuth, blogCtrl.ReplyToComment)
+
+		// The lifecycle of a blog: draft, scheduled, published, archived.
+		blogs.POST("/:id/publish", userCtrl.RequireAuth, blogCtrl.PublishBlog)
+		blogs.POST("/:id/unpublish", userCtrl.RequireAuth, blogCtrl.UnpublishBlog)
+		blogs.POST("/:id/archive", userCtrl.RequireAuth, blogCtrl.ArchiveBlog)
+		blogs.POST("/:id/schedule", userCtrl.RequireAuth, blogCtrl.ScheduleBlog)
+
+		// The revision history of a blog. Every update is saved as a
+		// revision, which can be compared with another one or restored.
+		blogs.GET("/:id/revisions", userCtrl.RequireAuth, blogCtrl.ListRevisions)
+		blogs.GET("/:id/revisions/diff", userCtrl.RequireAuth, blogCtrl.DiffRevisions)
+		blogs.GET("/:id/revisions/:revision", userCtrl.RequireAuth, blogCtrl.GetRevision)
+		blogs.POST("/:id/revisions/:revision/restore", userCtrl.RequireAuth, blogCtrl.RestoreRevision)
+	}
+
+	// Register the trash routes. Deleted blogs and comments stay in the trash
+	// until they are restored or purged.
+	trash := r.Group("/trash", userCtrl.RequireAuth)
+	{
+		trash.GET("/blogs", blogCtrl.ListTrashedBlogs)
+		trash.GET("/comments", blogCtrl.ListTrashedComments)
+	}
+
+	// Register the tag and category routes. Anyone can read them, and
+	// editors and admins can change them, which the handlers check.
+	tags := r.Group("/tags")
+	{
+		tags.GET("", blogCtrl.ListTags)
+		tags.GET("/cloud", blogCtrl.TagCloud)
+		tags.GET("/:id", blogCtrl.GetTag)
+		tags.POST("", userCtrl.RequireAuth, blogCtrl.CreateTag)
+		tags.PUT("/:id", userCtrl.RequireAuth, blogCtrl.UpdateTag)
+		tags.DELETE("/:id", userCtrl.RequireAuth, blogCtrl.DeleteTag)
 	}
 
+	categories := r.Group("/categories")
+	{
+		categories.GET("", blogCtrl.ListCategories)
+		categories.GET("/:id", blogCtrl.GetCategory)
+		categories.POST("", userCtrl.RequireAuth, blogCtrl.CreateCategory)
+		categories.PUT("/:id", userCtrl.RequireAuth, blogCtrl.UpdateCategory)
+		categories.DELETE("/:id", userCtrl.RequireAuth, blogCtrl.DeleteCategory)
+	}
+
+	// Register the account routes.
+	accounts := r.Group("/auth")
+	{
+		accounts.POST("/register", userCtrl.Register)
+		accounts.POST("/login", userCtrl.Login)
+		accounts.POST("/refresh", userCtrl.Refresh)
+		accounts.POST("/logout", userCtrl.Logout)
+	}
+
+	// Register the user management routes. Only admins may use them, which
+	// the handlers check.
+	users := r.Group("/users", userCtrl.RequireAuth)
+	{
+		users.PUT("/:id/role", userCtrl.SetRole)
+	}
+
+	// Register the search route.
+	r.GET("/search", blogCtrl.Search)
+
+	// Register the routes of the public site, which shows the same blogs as
+	// HTML pages. It has its own login with a session cookie, and its forms
+	// are protected against CSRF, which the handlers check.
+	// For more information on the site, see:
+	// blog/web/site.go
+	r.GET("/", site.Index)
+	r.GET("/posts/:slug", site.Post)
+	r.POST("/posts/:slug/comments", site.CreateComment)
+	r.GET("/tag/:slug", site.Tag)
+	r.GET("/login", site.LoginForm)
+	r.POST("/login", site.Login)
+	r.POST("/logout", site.Logout)
+	r.GET("/static/*filepath", site.Static)
+
+	// Register the feeds of the site and of each tag, in three formats.
+	r.GET("/feed.rss", site.RSSFeed)
+	r.GET("/feed.atom", site.AtomFeed)
+	r.GET("/feed.json", site.JSONFeed)
+	r.GET("/tag/:slug/feed.rss", site.RSSFeed)
+	r.GET("/tag/:slug/feed.atom", site.AtomFeed)
+	r.GET("/tag/:slug/feed.json", site.JSONFeed)
+
 	return r
 }
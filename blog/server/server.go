@@ -1,51 +1,269 @@
 // Package server provides a wrapper around the gin server
 //
-// This file contains the server struct and the Run method.
+// This file contains the server struct and the methods that start and stop
+// it.
 //
-// The Run method is used to start the server.
+// The server is an http.Server rather than gin's own Run method, so that it
+// has timeouts and can be shut down gracefully. It can also serve HTTPS,
+// with HTTP/2, and redirect plain HTTP to it.
 package server
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Config is the configuration of the server.
+//
+// The timeouts protect the server against slow or idle clients, which would
+// otherwise hold on to a connection, and a goroutine, for as long as they
+// like. A timeout of 0 means no timeout.
+//
+// For more information on the timeouts, see:
+// https://pkg.go.dev/net/http#Server
+type Config struct {
+	// Addr is the address to listen on, for example ":8080".
+	Addr string
+
+	// ReadHeaderTimeout is how long a client may take to send the headers
+	// of a request, and ReadTimeout how long it may take to send the whole
+	// request, including the body.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+
+	// WriteTimeout is how long the server may take to handle a request and
+	// write the response.
+	WriteTimeout time.Duration
+
+	// IdleTimeout is how long a keep-alive connection is kept open while
+	// it waits for the next request.
+	IdleTimeout time.Duration
+
+	// MaxHeaderBytes is the maximum size of the headers of a request. 0
+	// means http.DefaultMaxHeaderBytes (1 MB).
+	MaxHeaderBytes int
+
+	// TLS makes the server serve HTTPS. It is off if it has no CertFile.
+	TLS TLSConfig
+}
+
+// TLSConfig is the HTTPS configuration of the server.
+type TLSConfig struct {
+	// CertFile and KeyFile are the PEM files of the certificate and its
+	// private key. CertFile may contain intermediate certificates after the
+	// certificate of the server.
+	CertFile string
+	KeyFile  string
+
+	// ReloadInterval is how often the files are checked for a new
+	// certificate. 0 means the certificate is never reloaded.
+	ReloadInterval time.Duration
+
+	// RedirectAddr is the address of a plain HTTP listener that redirects
+	// every request to HTTPS, for example ":80". It is off if empty.
+	RedirectAddr string
+}
+
+// Enabled reports whether the server serves HTTPS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// tlsConfig returns the TLS configuration of the server. It only allows
+// TLS 1.2 and 1.3, and for TLS 1.2 only cipher suites with forward secrecy
+// and authenticated encryption. The cipher suites of TLS 1.3, and the key
+// exchange curves, are left to Go, whose defaults are the secure ones.
+//
+// For more information on these settings, see:
+// https://wiki.mozilla.org/Security/Server_Side_TLS
+func tlsConfig(certs *certReloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+		// HTTP/2 is offered first; clients that don't support it use
+		// HTTP/1.1.
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+}
+
 // Server is a wrapper around the gin server.
-// It contains the router and the port to run the server on.
-// The Run method is used to start the server.
+// It contains the http.Server that serves the router.
+// The Listen, Serve and Shutdown methods are used to run the server.
 type Server struct {
-	// The gin.Engine is the router.
-	// For more information on the gin.Engine, see:
-	// https://godoc.org/github.com/gin-gonic/gin#Engine
-	router *gin.Engine
+	// The http.Server serves the requests with the router, which is a
+	// gin.Engine.
+	// For more information on the http.Server, see:
+	// https://pkg.go.dev/net/http#Server
+	http *http.Server
+
+	// The listener that Listen opened, which Serve accepts connections on.
+	listener net.Listener
 
-	// The port to run the server on.
-	// It is a string because it can contain a colon and a port number.
-	// For example, ":8080".
-	port string
+	// tls is the HTTPS configuration, which Listen uses.
+	tls TLSConfig
+
+	// redirect is the server that redirects plain HTTP to HTTPS, and
+	// redirectListener its listener. redirect is nil if there is none.
+	redirect         *http.Server
+	redirectListener net.Listener
 }
 
 // NewServer creates a new server.
-// It takes a pointer to a gin.Engine and a port string as arguments.
+// It takes a pointer to a gin.Engine and the configuration as arguments.
 // It returns a pointer to a Server.
-func NewServer(r *gin.Engine, port string) *Server {
-	return &Server{
-		router: r,
-		port:   port,
+func NewServer(r *gin.Engine, cfg Config) *Server {
+	s := &Server{
+		http: newHTTPServer(cfg, cfg.Addr, r),
+		tls:  cfg.TLS,
+	}
+	if cfg.TLS.Enabled() && cfg.TLS.RedirectAddr != "" {
+		s.redirect = newHTTPServer(cfg, cfg.TLS.RedirectAddr, redirectToHTTPS(cfg.Addr))
+	}
+
+	return s
+}
+
+// newHTTPServer returns an http.Server with the timeouts of the config.
+func newHTTPServer(cfg Config, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
 	}
 }
 
-// Run starts the server.
-func (s *Server) Run() {
-	// s.router.Run starts the server.
-	// It takes a port string as an argument.
-	// It returns an error if the server fails to start.
-	// If the server fails to start, we log the error and exit the program.
-	//
-	// For more information on s.router.Run, see:
-	// https://godoc.org/github.com/gin-gonic/gin#Engine.Run
-	if err := s.router.Run(s.port); err != nil {
-		log.Fatalf("failed to run server on port %s with %v", s.port, err)
+// Listen opens the addresses of the server and loads its certificate. It is
+// separate from Serve, so that a port that is already in use or a broken
+// certificate is reported at startup, before anything else is started.
+func (s *Server) Listen() error {
+	if s.tls.Enabled() {
+		certs, err := newCertReloader(s.tls.CertFile, s.tls.KeyFile, s.tls.ReloadInterval)
+		if err != nil {
+			return err
+		}
+		s.http.TLSConfig = tlsConfig(certs)
+	}
+
+	listener, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.http.Addr, err)
+	}
+	s.listener = listener
+
+	if s.redirect != nil {
+		listener, err := net.Listen("tcp", s.redirect.Addr)
+		if err != nil {
+			s.listener.Close()
+			return fmt.Errorf("failed to listen on %s: %w", s.redirect.Addr, err)
+		}
+		s.redirectListener = listener
+	}
+
+	return nil
+}
+
+// Serve serves requests until the server is shut down. It returns nil
+// after Shutdown, and an error if the server, or the redirect server,
+// stops for another reason.
+func (s *Server) Serve() error {
+	errs := make(chan error, 2)
+	go func() {
+		if s.tls.Enabled() {
+			// The certificate comes from the TLSConfig, so no files are
+			// passed here. ServeTLS enables HTTP/2.
+			errs <- s.http.ServeTLS(s.listener, "", "")
+		} else {
+			errs <- s.http.Serve(s.listener)
+		}
+	}()
+
+	servers := 1
+	if s.redirect != nil {
+		servers++
+		go func() {
+			errs <- s.redirect.Serve(s.redirectListener)
+		}()
+	}
+
+	// Both servers stop when Shutdown is called. If one stops for another
+	// reason first, its error is returned.
+	for i := 0; i < servers; i++ {
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	return nil
+}
+
+// URL returns the URL the server can be reached at, for the logs.
+func (s *Server) URL() string {
+	scheme := "http"
+	if s.tls.Enabled() {
+		scheme = "https"
+	}
+	return scheme + "://" + s.http.Addr
+}
+
+// redirectToHTTPS returns a handler that redirects requests to the same URL
+// on the HTTPS address addr.
+//
+// 308 Permanent Redirect is used rather than 301, so that clients repeat
+// POST and PUT requests with the same method and body.
+func redirectToHTTPS(addr string) http.Handler {
+	_, port, _ := net.SplitHostPort(addr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]") // IPv6 addresses are in brackets.
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// Shutdown stops the server gracefully: it stops accepting connections,
+// waits for the requests in flight to finish and closes idle connections.
+//
+// If ctx is done before all the requests have finished, the remaining
+// connections are closed, which aborts their requests, and the error of
+// ctx is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.redirect != nil {
+		// Redirects are answered right away, so there is nothing to wait
+		// for.
+		s.redirect.Close()
+	}
+
+	if err := s.http.Shutdown(ctx); err != nil {
+		s.http.Close()
+		return err
 	}
+	return nil
 }
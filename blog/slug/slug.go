@@ -0,0 +1,136 @@
+// Package slug turns titles into slugs: short, readable strings that
+// identify a blog in a URL, such as "hello-world" for "Hello, World!".
+//
+// Slugs keep the letters and digits of every script, so a Thai or Japanese
+// title gets a Thai or Japanese slug rather than an empty one. Accents are
+// removed from Latin letters ("Café" becomes "cafe"), because they are easy
+// to get wrong when typing a URL.
+package slug
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength is the maximum length of a slug in characters, including any
+// suffix added by WithSuffix.
+const MaxLength = 80
+
+// Fallback is the slug of titles that contain no letters or digits.
+const Fallback = "blog"
+
+// Make returns the slug of a title.
+//
+// It lowercases the title, removes accents from Latin letters, and replaces
+// every run of characters that aren't letters or digits by a single dash.
+// Long slugs are cut at a dash where possible.
+func Make(title string) string {
+	if s := slugify(title); s != "" {
+		return s
+	}
+	return Fallback
+}
+
+// tagSymbols are the names of the symbols that tell tags apart, such as
+// those of "C", "C++" and "C#". Tag spells them out, since Make drops them.
+var tagSymbols = map[rune]string{'+': "plus", '#': "sharp", '&': "and"}
+
+// Tag returns the slug of a tag name. Unlike Make, it keeps the symbols in
+// tagSymbols, so that "C++" becomes "c-plus-plus" and "C#" becomes
+// "c-sharp" rather than both becoming "c". "+" and "#" are only kept after
+// a letter, a digit or another symbol, so that "#go" is the same tag as
+// "go".
+//
+// Tags are identified by their slug, so there is no fallback: Tag returns an
+// empty string for names without letters or digits, which would otherwise
+// all be the same tag.
+func Tag(name string) string {
+	var b strings.Builder
+	var prev rune
+	for _, r := range name {
+		word, ok := tagSymbols[r]
+		_, prevSymbol := tagSymbols[prev]
+		if ok && (r == '&' || unicode.IsLetter(prev) || unicode.IsDigit(prev) || prevSymbol) {
+			b.WriteString(" " + word + " ")
+		} else {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+
+	if !hasLetterOrDigit(name) {
+		return ""
+	}
+	return slugify(b.String())
+}
+
+// hasLetterOrDigit reports whether s contains a letter or a digit.
+func hasLetterOrDigit(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// slugify returns the slug of a title, or an empty string if the title has no
+// letters or digits.
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	var base rune
+	for _, r := range norm.NFKD.String(title) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// A combining mark, such as the accent of "é" after NFKD.
+			// Marks are part of the letter in many scripts (Thai vowels,
+			// for example), so they are only dropped after Latin letters.
+			if !unicode.Is(unicode.Latin, base) {
+				b.WriteRune(r)
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			base = r
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			dash = true
+		}
+	}
+
+	// Put the characters that NFKD took apart, and that we kept, back
+	// together.
+	return truncate(norm.NFC.String(b.String()), MaxLength)
+}
+
+// WithSuffix returns the slug with a number added, such as "hello-world-2",
+// to tell it apart from other blogs with the same title. The result is never
+// longer than MaxLength.
+func WithSuffix(slug string, n int) string {
+	suffix := "-" + strconv.Itoa(n)
+	return truncate(slug, MaxLength-len(suffix)) + suffix
+}
+
+// truncate shortens s to at most max characters. It cuts at the last dash
+// that keeps at least half of the characters, so that words aren't cut in
+// half, and otherwise in the middle of a word.
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+
+	runes = runes[:max]
+	for i := len(runes) - 1; i >= max/2; i-- {
+		if runes[i] == '-' {
+			return string(runes[:i])
+		}
+	}
+	return strings.TrimSuffix(string(runes), "-")
+}
@@ -0,0 +1,76 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"  Café au lait  ", "cafe-au-lait"},
+		{"Go 1.20 is out", "go-1-20-is-out"},
+		{"C++", "c"},
+		{"สวัสดี", "สวัสดี"},
+		{"", Fallback},
+		{"!!!", Fallback},
+	}
+
+	for _, tt := range tests {
+		if got := Make(tt.title); got != tt.want {
+			t.Errorf("Make(%q) = %q; want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestMakeLong(t *testing.T) {
+	title := strings.Repeat("word ", 40)
+	got := Make(title)
+	if len([]rune(got)) > MaxLength || strings.HasSuffix(got, "-") {
+		t.Errorf("Make(long title) = %q; want at most %d characters, cut at a dash", got, MaxLength)
+	}
+}
+
+func TestTag(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Go", "go"},
+		{"#go", "go"},
+		{"C", "c"},
+		{"C++", "c-plus-plus"},
+		{"C#", "c-sharp"},
+		{"F#", "f-sharp"},
+		{"C++11", "c-plus-plus-11"},
+		{"R&D", "r-and-d"},
+		{"Node.js", "node-js"},
+		{"Café", "cafe"},
+		// Names without letters or digits have no slug, rather than all
+		// sharing the fallback.
+		{"", ""},
+		{"++", ""},
+		{"#", ""},
+		{"!?", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Tag(tt.name); got != tt.want {
+			t.Errorf("Tag(%q) = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWithSuffix(t *testing.T) {
+	if got := WithSuffix("hello-world", 2); got != "hello-world-2" {
+		t.Errorf("WithSuffix = %q; want hello-world-2", got)
+	}
+
+	long := strings.Repeat("a", MaxLength)
+	if got := WithSuffix(long, 12); len(got) != MaxLength || !strings.HasSuffix(got, "-12") {
+		t.Errorf("WithSuffix(long slug) = %q; want %d characters ending in -12", got, MaxLength)
+	}
+}
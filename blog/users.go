@@ -0,0 +1,53 @@
+// This file contains the users subcommand, which manages user accounts from
+// the command line.
+//
+// Usage:
+//
+//	blog users set-role <email> <role>  Change the role of a user
+//
+// It is mostly useful to make the first admin, who can then change the roles
+// of other users with PUT /users/:id/role.
+package main
+
+import (
+	"fmt"
+
+	"blog/models"
+	"blog/policy"
+)
+
+// runUsers runs the users subcommand with the given arguments.
+// It returns an error if the arguments are invalid or the command fails.
+func runUsers(userModel models.UserStore, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing users command (expected set-role)")
+	}
+
+	switch args[0] {
+	case "set-role":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: users set-role <email> <role>")
+		}
+
+		role := policy.Role(args[2])
+		if !role.Valid() {
+			return fmt.Errorf("unknown role %q (expected one of %v)", args[2], policy.Roles)
+		}
+
+		user, _, err := userModel.GetUserByEmail(args[1])
+		if err != nil {
+			return err
+		}
+
+		user, err = userModel.SetUserRole(user.ID, role)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("user %d (%s) is now %s\n", user.ID, user.Email, user.Role)
+
+	default:
+		return fmt.Errorf("unknown users command %q (expected set-role)", args[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"blog/feed"
+	"blog/forms"
+	"blog/markdown"
+
+	"github.com/gin-gonic/gin"
+)
+
+// feedSize is the number of posts in a feed, the most recent first.
+const feedSize = 20
+
+// feedFormat is a format of the feeds: its name, its content type and the
+// function that writes it.
+type feedFormat struct {
+	name        string
+	contentType string
+	write       func(feed.Feed) ([]byte, error)
+}
+
+var (
+	rssFormat  = feedFormat{"rss", feed.ContentTypeRSS, feed.RSS}
+	atomFormat = feedFormat{"atom", feed.ContentTypeAtom, feed.Atom}
+	jsonFormat = feedFormat{"json", feed.ContentTypeJSON, feed.JSON}
+)
+
+// RSSFeed writes the RSS feed of the site, or of a tag if the URL has one.
+func (s *Site) RSSFeed(ctx *gin.Context) {
+	s.writeFeed(ctx, rssFormat)
+}
+
+// AtomFeed writes the Atom feed of the site, or of a tag.
+func (s *Site) AtomFeed(ctx *gin.Context) {
+	s.writeFeed(ctx, atomFormat)
+}
+
+// JSONFeed writes the JSON Feed of the site, or of a tag.
+func (s *Site) JSONFeed(ctx *gin.Context) {
+	s.writeFeed(ctx, jsonFormat)
+}
+
+// writeFeed writes a feed of the most recent published blogs with the tag
+// in the URL, or of all of them if there is no tag.
+//
+// Feed readers fetch feeds often, so the feeds support conditional GET: the
+// response has an ETag, which is derived from which blogs are in the feed
+// and when they were last updated or published. A reader that sends it back
+// with If-None-Match gets 304 Not Modified, without a body, if nothing has
+// changed.
+//
+// There is no Last-Modified header. The newest time in the feed doesn't
+// change when a blog is unpublished, deleted or archived, so a reader that
+// only sends If-Modified-Since would keep a blog that is gone.
+func (s *Site) writeFeed(ctx *gin.Context, format feedFormat) {
+	tag := ctx.Param("slug")
+
+	// The feed has the most recently published blogs, however long ago
+	// they were written.
+	blogs, err := s.blogs.GetAllBlogs(forms.ListBlogsQuery{
+		Limit:       feedSize,
+		Sort:        "published_at",
+		Tag:         tag,
+		FullContent: true,
+	})
+	if err != nil {
+		s.renderError(ctx, "Failed to get blogs", err)
+		return
+	}
+
+	// Like the tag pages, a tag without published blogs has no feed.
+	if tag != "" && len(blogs.Data) == 0 {
+		s.notFound(ctx)
+		return
+	}
+
+	base := s.baseURL
+	f := feed.Feed{
+		Title:       "Blog",
+		Description: "The latest posts",
+		URL:         base + ctx.Request.URL.Path,
+		Link:        base + "/",
+		// The Unix epoch stands for "never" in an empty feed.
+		Updated: time.Unix(0, 0),
+	}
+	if tag != "" {
+		name := tagName(blogs.Data[0], tag)
+		f.Title = "Blog: " + name
+		f.Description = "The latest posts tagged “" + name + "”"
+		f.Link = base + "/tag/" + url.PathEscape(tag)
+	}
+
+	for _, blog := range blogs.Data {
+		item := feedItem(base, blog)
+		if item.Updated.After(f.Updated) {
+			f.Updated = item.Updated
+		}
+		f.Items = append(f.Items, item)
+	}
+
+	body, err := format.write(f)
+	if err != nil {
+		s.renderError(ctx, "Failed to write feed", err)
+		return
+	}
+
+	ctx.Header("Content-Type", format.contentType)
+	ctx.Header("ETag", feedETag(format, f))
+	ctx.Header("Cache-Control", "public, max-age=300")
+
+	// ServeContent compares the conditional headers of the request with the
+	// ETag, and writes 304 Not Modified if they match. With a zero
+	// modification time, it neither sends Last-Modified nor looks at
+	// If-Modified-Since.
+	http.ServeContent(ctx.Writer, ctx.Request, "", time.Time{}, bytes.NewReader(body))
+}
+
+// feedItem returns the item of a feed for a blog.
+func feedItem(base string, blog forms.GetAllBlogsResponse) feed.Item {
+	published := blog.CreatedAt
+	if blog.PublishedAt != nil {
+		published = *blog.PublishedAt
+	}
+
+	// Publishing a blog doesn't necessarily update it, so a blog that was
+	// written long ago and published today counts as updated today.
+	updated := blog.UpdatedAt
+	if published.After(updated) {
+		updated = published
+	}
+
+	item := feed.Item{
+		// The ID must never change, so it is the URL of the blog in the
+		// JSON API, which has the ID of the blog rather than its slug.
+		ID:          base + "/blogs/" + strconv.Itoa(blog.ID),
+		Title:       blog.Title,
+		Link:        base + "/posts/" + url.PathEscape(blog.Slug),
+		ContentHTML: blog.ContentHTML,
+		Published:   published,
+		Updated:     updated,
+	}
+	if blog.Author != nil {
+		item.Author = blog.Author.Name
+	}
+	for _, tag := range blog.Tags {
+		item.Tags = append(item.Tags, tag.Name)
+	}
+
+	return item
+}
+
+// feedETag returns the ETag of a feed. It changes whenever a blog is added
+// to or removed from the feed or one of its blogs is updated, and also when
+// the markdown package renders blogs differently.
+func feedETag(format feedFormat, f feed.Feed) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%d\n", format.name, f.URL, markdown.Version)
+	for _, item := range f.Items {
+		fmt.Fprintf(h, "%s %d\n", item.ID, item.Updated.UnixNano())
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// tagName returns the name of the tag with the given slug, which the blog
+// has. The slug is used if the blog doesn't have the tag.
+func tagName(blog forms.GetAllBlogsResponse, slug string) string {
+	for _, tag := range blog.Tags {
+		if tag.Slug == slug {
+			return tag.Name
+		}
+	}
+	return slug
+}
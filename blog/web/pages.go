@@ -0,0 +1,306 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"blog/auth"
+	"blog/forms"
+	"blog/models"
+	"blog/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The number of blogs per page, of comments per page of a blog, and of tags
+// in the tag cloud of the front page.
+const (
+	blogsPerPage    = 10
+	commentsPerPage = 50
+	cloudSize       = 30
+)
+
+// pager holds the links to the neighbouring pages of a list, which are
+// empty on the first and the last page.
+type pager struct {
+	Newer string
+	Older string
+}
+
+// newPager returns the pager of the page with the given number. path is
+// the path of the first page; hasMore says whether there is a next page.
+func newPager(path string, page int, hasMore bool) pager {
+	var p pager
+	switch {
+	case page == 2:
+		p.Newer = path
+	case page > 2:
+		p.Newer = path + "?page=" + strconv.Itoa(page-1)
+	}
+	if hasMore {
+		p.Older = path + "?page=" + strconv.Itoa(page+1)
+	}
+	return p
+}
+
+// pageNumber returns the page number in the query string, which is 1 if
+// there is none. If the page number is invalid, it writes a 404 page and
+// returns false.
+func (s *Site) pageNumber(ctx *gin.Context) (int, bool) {
+	value, ok := ctx.GetQuery("page")
+	if !ok {
+		return 1, true
+	}
+
+	page, err := strconv.Atoi(value)
+	if err != nil || page < 1 {
+		s.notFound(ctx)
+		return 0, false
+	}
+	return page, true
+}
+
+// indexPage is the data of the front page and of the tag pages.
+type indexPage struct {
+	Heading string
+	Blogs   []forms.GetAllBlogsResponse
+	Pager   pager
+	Tags    []forms.TagCount
+}
+
+// Index shows the published blogs, most recently published first, with the
+// tag cloud on the first page.
+func (s *Site) Index(ctx *gin.Context) {
+	page, ok := s.pageNumber(ctx)
+	if !ok {
+		return
+	}
+
+	blogs, err := s.blogs.GetAllBlogs(forms.ListBlogsQuery{
+		Limit:  blogsPerPage,
+		Offset: (page - 1) * blogsPerPage,
+		Sort:   "published_at",
+	})
+	if err != nil {
+		s.renderError(ctx, "Failed to get blogs", err)
+		return
+	}
+	if page > 1 && len(blogs.Data) == 0 {
+		s.notFound(ctx)
+		return
+	}
+
+	data := indexPage{
+		Blogs: blogs.Data,
+		Pager: newPager("/", page, blogs.Pagination.HasMore),
+	}
+	if page == 1 {
+		cloud, err := s.blogs.TagCloud(forms.TagCloudQuery{Limit: cloudSize})
+		if err != nil {
+			s.renderError(ctx, "Failed to get tag cloud", err)
+			return
+		}
+		data.Tags = cloud.Data
+	}
+
+	s.render(ctx, http.StatusOK, "index", "", data)
+}
+
+// Tag shows the published blogs with a tag, most recently published first.
+func (s *Site) Tag(ctx *gin.Context) {
+	slug := ctx.Param("slug")
+
+	page, ok := s.pageNumber(ctx)
+	if !ok {
+		return
+	}
+
+	blogs, err := s.blogs.GetAllBlogs(forms.ListBlogsQuery{
+		Limit:  blogsPerPage,
+		Offset: (page - 1) * blogsPerPage,
+		Sort:   "published_at",
+		Tag:    slug,
+	})
+	if err != nil {
+		s.renderError(ctx, "Failed to get blogs", err)
+		return
+	}
+
+	// A tag without published blogs has no page.
+	if len(blogs.Data) == 0 {
+		s.notFound(ctx)
+		return
+	}
+
+	// The name of the tag is taken from the blogs, which all have it.
+	name := tagName(blogs.Data[0], slug)
+
+	s.render(ctx, http.StatusOK, "index", name, indexPage{
+		Heading: "Posts tagged “" + name + "”",
+		Blogs:   blogs.Data,
+		Pager:   newPager("/tag/"+url.PathEscape(slug), page, blogs.Pagination.HasMore),
+	})
+}
+
+// postPage is the data of the page of a blog.
+type postPage struct {
+	Blog     forms.Blog
+	Comments []forms.Comment
+	Pager    pager
+
+	// Error and Draft are set when a comment is rejected, so that the form
+	// shows what was wrong and keeps what was written.
+	Error string
+	Draft string
+}
+
+// Post shows a published blog with its comments. Old slugs are redirected
+// to the current one, like in the JSON API.
+func (s *Site) Post(ctx *gin.Context) {
+	id, ok := s.publishedBlogID(ctx)
+	if !ok {
+		return
+	}
+
+	page, ok := s.pageNumber(ctx)
+	if !ok {
+		return
+	}
+
+	s.renderPost(ctx, http.StatusOK, id, page, postPage{})
+}
+
+// renderPost writes the page of a blog with a page of its comments. data
+// may hold an error and a draft of the comment form.
+func (s *Site) renderPost(ctx *gin.Context, status, id, page int, data postPage) {
+	blog, err := s.blogs.GetBlogByID(id)
+	if err != nil {
+		s.renderError(ctx, "Failed to get blog", err)
+		return
+	}
+
+	comments, err := s.blogs.ListComments(id, forms.ListCommentsQuery{
+		Limit:  commentsPerPage,
+		Offset: (page - 1) * commentsPerPage,
+	})
+	if err != nil {
+		s.renderError(ctx, "Failed to get comments", err)
+		return
+	}
+	if page > 1 && len(comments.Data) == 0 {
+		s.notFound(ctx)
+		return
+	}
+
+	data.Blog = blog.Blog
+	data.Comments = comments.Data
+	data.Pager = newPager("/posts/"+url.PathEscape(blog.Slug), page, comments.Pagination.HasMore)
+
+	s.render(ctx, status, "post", blog.Title, data)
+}
+
+// CreateComment adds a comment to a blog from the comment form, and
+// redirects to the last page of comments, where the new comment is.
+func (s *Site) CreateComment(ctx *gin.Context) {
+	claims, ok := s.currentUser(ctx)
+	if !ok {
+		ctx.Redirect(http.StatusSeeOther, loginURL(strings.TrimSuffix(ctx.Request.URL.Path, "/comments")))
+		return
+	}
+	if !s.checkCSRF(ctx) {
+		return
+	}
+
+	id, ok := s.publishedBlogID(ctx)
+	if !ok {
+		return
+	}
+
+	if !policy.Allowed(claimsUser(claims), policy.CreateComment, 0) {
+		s.render(ctx, http.StatusForbidden, "error", "Forbidden", errorPage{Message: "You are not allowed to comment."})
+		return
+	}
+
+	content := strings.TrimSpace(ctx.PostForm("content"))
+	if content == "" {
+		s.renderPost(ctx, http.StatusUnprocessableEntity, id, 1, postPage{Error: "Write something first."})
+		return
+	}
+
+	if err := s.blogs.CreateComment(id, claims.UserID(), forms.CreateCommentRequest{Content: content}); err != nil {
+		s.renderError(ctx, "Failed to create comment", err)
+		return
+	}
+
+	// Comments are shown oldest first, so the new one is on the last page.
+	last := 1
+	comments, err := s.blogs.ListComments(id, forms.ListCommentsQuery{Limit: 1})
+	if err == nil && comments.Pagination.Total > commentsPerPage {
+		last = (comments.Pagination.Total + commentsPerPage - 1) / commentsPerPage
+	}
+
+	location := strings.TrimSuffix(ctx.Request.URL.Path, "/comments")
+	if last > 1 {
+		location += "?page=" + strconv.Itoa(last)
+	}
+
+	// 303 See Other makes the browser load the page with GET, so that
+	// reloading it doesn't post the comment again.
+	ctx.Redirect(http.StatusSeeOther, location+"#comments")
+}
+
+// publishedBlogID returns the ID of the published blog with the slug in the
+// URL. An old slug is redirected to the current one. If there is no such
+// blog, it writes a 404 page and returns false.
+//
+// The site only shows published blogs, even to their authors, who can see
+// their drafts with the JSON API.
+func (s *Site) publishedBlogID(ctx *gin.Context) (int, bool) {
+	slug := ctx.Param("slug")
+
+	id, current, err := s.blogs.GetBlogIDBySlug(slug)
+	if err != nil {
+		s.renderError(ctx, "Failed to get blog", err)
+		return 0, false
+	}
+
+	info, err := s.blogs.GetBlogInfo(id)
+	if err != nil {
+		s.renderError(ctx, "Failed to get blog", err)
+		return 0, false
+	}
+	if info.Deleted || info.Status != forms.StatusPublished {
+		s.notFound(ctx)
+		return 0, false
+	}
+
+	if current != slug && ctx.Request.Method == http.MethodGet {
+		location := "/posts/" + url.PathEscape(current)
+		if ctx.Request.URL.RawQuery != "" {
+			location += "?" + ctx.Request.URL.RawQuery
+		}
+		ctx.Redirect(http.StatusMovedPermanently, location)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// isNotFound reports whether err is an ErrNotFound error of the models.
+func isNotFound(err error) bool {
+	return errors.Is(err, models.ErrNotFound)
+}
+
+// loginURL returns the URL of the login page that comes back to path.
+func loginURL(path string) string {
+	return fmt.Sprintf("/login?next=%s", url.QueryEscape(path))
+}
+
+// claimsUser returns the policy user of the claims of an access token.
+func claimsUser(claims auth.Claims) policy.User {
+	return policy.User{ID: claims.UserID(), Role: policy.Role(claims.Role)}
+}
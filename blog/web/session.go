@@ -0,0 +1,344 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"blog/auth"
+	"blog/forms"
+	"blog/middleware"
+	"blog/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The cookies of the site. The session cookie holds an access token, like
+// the Authorization header of the JSON API, and the refresh cookie a refresh
+// token, which gets a new access token when it expires. A session therefore
+// lasts as long as the refresh token. The CSRF cookie holds the token that
+// every form must send back.
+const (
+	sessionCookie = "blog_session"
+	refreshCookie = "blog_refresh"
+	csrfCookie    = "blog_csrf"
+)
+
+// csrfTokenKey is the key of the CSRF token in the context of a request, so
+// that all the forms of a page get the same token. userKey is the key of
+// the claims of the user, so that the session is only refreshed once per
+// request.
+const (
+	csrfTokenKey = "web_csrf_token"
+	userKey      = "web_user"
+)
+
+// setCookie sets a cookie that JavaScript can't read and that isn't sent
+// with requests from other sites, except for links. A maxAge below 0
+// deletes the cookie.
+//
+// Whether the cookie is Secure comes from the config rather than from the
+// request, which is plain HTTP behind a proxy that does TLS.
+func (s *Site) setCookie(ctx *gin.Context, name, value string, maxAge int) {
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   s.secureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// currentUser returns the claims of the access token in the session cookie.
+// If the access token is missing or has expired, the session is refreshed
+// with the refresh cookie. It returns false if that isn't possible either.
+func (s *Site) currentUser(ctx *gin.Context) (auth.Claims, bool) {
+	if v, ok := ctx.Get(userKey); ok {
+		claims, ok := v.(auth.Claims)
+		return claims, ok
+	}
+
+	var claims auth.Claims
+	token, err := ctx.Cookie(sessionCookie)
+	if err == nil && token != "" {
+		claims, err = s.tokens.VerifyAccessToken(token)
+	}
+	if err != nil || token == "" {
+		claims, err = s.refreshSession(ctx)
+	}
+	if err != nil {
+		// Remember that there is no user, so that the refresh isn't
+		// tried again for the same request.
+		ctx.Set(userKey, nil)
+		return auth.Claims{}, false
+	}
+
+	ctx.Set(userKey, claims)
+	return claims, true
+}
+
+// errNoSession is returned by refreshSession when there is no session to
+// refresh.
+var errNoSession = errors.New("no session")
+
+// refreshSession issues a new access token with the refresh token in the
+// refresh cookie, sets the session cookie, and returns the claims of the new
+// access token.
+//
+// Unlike POST /auth/refresh, the refresh token isn't rotated. A page loads
+// its stylesheet and the browser may load several pages at once, all with
+// the same cookies; if each of them rotated the refresh token, all but the
+// first would look like a reuse of a revoked token and end the session.
+//
+// If the refresh token is invalid, the cookies are deleted. If it has been
+// revoked, it was logged out or it was rotated through the JSON API, which
+// the site never does, so its whole family is revoked as well.
+func (s *Site) refreshSession(ctx *gin.Context) (auth.Claims, error) {
+	refreshToken, err := ctx.Cookie(refreshCookie)
+	if err != nil || refreshToken == "" {
+		return auth.Claims{}, errNoSession
+	}
+
+	hash := auth.HashRefreshToken(refreshToken)
+	token, err := s.refreshTokens.GetRefreshToken(hash)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrConflict):
+			if err := s.refreshTokens.RevokeRefreshTokenFamily(hash); err != nil {
+				log.Printf("[%s] Failed to revoke session: %v", middleware.GetRequestID(ctx), err)
+			}
+			s.clearSession(ctx)
+		case errors.Is(err, models.ErrNotFound):
+			s.clearSession(ctx)
+		default:
+			log.Printf("[%s] Failed to refresh session: %v", middleware.GetRequestID(ctx), err)
+		}
+		return auth.Claims{}, err
+	}
+
+	// The role and name are read again, so that changes show up in the new
+	// access token.
+	user, err := s.users.GetUserByID(token.UserID)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
+	return s.issueAccessToken(ctx, user)
+}
+
+// startSession issues an access token for the user, sets the session and
+// refresh cookies, and returns the claims of the access token.
+func (s *Site) startSession(ctx *gin.Context, user forms.User, refreshToken string) (auth.Claims, error) {
+	claims, err := s.issueAccessToken(ctx, user)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
+	s.setCookie(ctx, refreshCookie, refreshToken, int(s.tokens.RefreshTokenTTL().Seconds()))
+
+	return claims, nil
+}
+
+// issueAccessToken issues an access token for the user, sets the session
+// cookie, and returns the claims of the access token.
+func (s *Site) issueAccessToken(ctx *gin.Context, user forms.User) (auth.Claims, error) {
+	token, _, err := s.tokens.IssueAccessToken(user.ID, user.Name, user.Role)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	claims, err := s.tokens.VerifyAccessToken(token)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
+	s.setCookie(ctx, sessionCookie, token, int(s.tokens.AccessTokenTTL().Seconds()))
+
+	return claims, nil
+}
+
+// clearSession deletes the session and refresh cookies.
+func (s *Site) clearSession(ctx *gin.Context) {
+	s.setCookie(ctx, sessionCookie, "", -1)
+	s.setCookie(ctx, refreshCookie, "", -1)
+}
+
+// csrfToken returns the CSRF token of the browser, and sets the CSRF cookie
+// if the browser doesn't have one yet.
+//
+// The site uses the double-submit pattern: forms send the token in a hidden
+// field, and checkCSRF compares it with the cookie. Other sites can make a
+// browser post a form, but they can't read or set the cookie, so they can't
+// send the right token.
+func (s *Site) csrfToken(ctx *gin.Context) string {
+	if token := ctx.GetString(csrfTokenKey); token != "" {
+		return token
+	}
+
+	token, err := ctx.Cookie(csrfCookie)
+	if err != nil || token == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			// The forms will be rejected, but the page can still be read.
+			log.Printf("[%s] Failed to generate CSRF token: %v", middleware.GetRequestID(ctx), err)
+			return ""
+		}
+		token = base64.RawURLEncoding.EncodeToString(b)
+		s.setCookie(ctx, csrfCookie, token, 0)
+	}
+
+	ctx.Set(csrfTokenKey, token)
+	return token
+}
+
+// checkCSRF checks the CSRF token of a form. If it is missing or wrong, it
+// writes a 403 page and returns false.
+func (s *Site) checkCSRF(ctx *gin.Context) bool {
+	cookie, err := ctx.Cookie(csrfCookie)
+	form := ctx.PostForm("csrf_token")
+	if err == nil && cookie != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(form)) == 1 {
+		return true
+	}
+
+	s.render(ctx, http.StatusForbidden, "error", "Form expired",
+		errorPage{Message: "The form has expired. Go back, reload the page and try again."})
+	return false
+}
+
+// loginPage is the data of the login page.
+type loginPage struct {
+	Next  string
+	Email string
+	Error string
+}
+
+// LoginForm shows the login form. next is where the user goes after logging
+// in.
+func (s *Site) LoginForm(ctx *gin.Context) {
+	next := localPath(ctx.Query("next"))
+	if _, ok := s.currentUser(ctx); ok {
+		ctx.Redirect(http.StatusSeeOther, next)
+		return
+	}
+
+	s.render(ctx, http.StatusOK, "login", "Log in", loginPage{Next: next})
+}
+
+// Login checks the email address and password of the login form, sets the
+// session cookie and redirects to next.
+func (s *Site) Login(ctx *gin.Context) {
+	if !s.checkCSRF(ctx) {
+		return
+	}
+
+	next := localPath(ctx.PostForm("next"))
+	email := strings.TrimSpace(ctx.PostForm("email"))
+
+	user, err := s.authenticate(email, ctx.PostForm("password"))
+	if err != nil {
+		// Like the JSON API, we don't say whether the email address or the
+		// password is wrong.
+		if errors.Is(err, errInvalidCredentials) {
+			s.render(ctx, http.StatusUnauthorized, "login", "Log in", loginPage{
+				Next:  next,
+				Email: email,
+				Error: "The email address or password is incorrect.",
+			})
+			return
+		}
+		s.renderError(ctx, "Failed to log in", err)
+		return
+	}
+
+	// Like a login to the JSON API, every login starts a new family of
+	// refresh tokens.
+	family, err := auth.NewTokenFamily()
+	if err != nil {
+		s.renderError(ctx, "Failed to log in", err)
+		return
+	}
+	refreshToken, hash, err := s.tokens.NewRefreshToken()
+	if err != nil {
+		s.renderError(ctx, "Failed to log in", err)
+		return
+	}
+	if err := s.refreshTokens.CreateRefreshToken(models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  family,
+		Hash:      hash,
+		ExpiresAt: time.Now().Add(s.tokens.RefreshTokenTTL()),
+	}); err != nil {
+		s.renderError(ctx, "Failed to log in", err)
+		return
+	}
+
+	if _, err := s.startSession(ctx, user, refreshToken); err != nil {
+		s.renderError(ctx, "Failed to issue token", err)
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, next)
+}
+
+// Logout revokes the refresh token of the session, deletes the cookies and
+// redirects to the front page.
+func (s *Site) Logout(ctx *gin.Context) {
+	if !s.checkCSRF(ctx) {
+		return
+	}
+
+	if token, err := ctx.Cookie(refreshCookie); err == nil && token != "" {
+		err := s.refreshTokens.RevokeRefreshTokenFamily(auth.HashRefreshToken(token))
+		if err != nil && !errors.Is(err, models.ErrNotFound) {
+			log.Printf("[%s] Failed to revoke session: %v", middleware.GetRequestID(ctx), err)
+		}
+	}
+
+	s.clearSession(ctx)
+	ctx.Redirect(http.StatusSeeOther, "/")
+}
+
+// errInvalidCredentials is returned by authenticate when the email address
+// or password is wrong.
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// authenticate returns the user with the given email address and password,
+// in the same way as the login of the JSON API.
+func (s *Site) authenticate(email, password string) (forms.User, error) {
+	user, hash, err := s.users.GetUserByEmail(email)
+	if errors.Is(err, models.ErrNotFound) {
+		// Check a dummy password, so that a login for an unknown email
+		// address takes as long as one with a wrong password.
+		auth.CheckNoPassword(password)
+		return forms.User{}, errInvalidCredentials
+	}
+	if err != nil {
+		return forms.User{}, err
+	}
+
+	ok, err := auth.CheckPassword(hash, password)
+	if err != nil {
+		return forms.User{}, err
+	}
+	if !ok {
+		return forms.User{}, errInvalidCredentials
+	}
+
+	return user, nil
+}
+
+// localPath returns next if it is a path on this site, and "/" otherwise, so
+// that the login form can't be used to send users to another site.
+func localPath(next string) string {
+	// "//example.com" and "/\example.com" are treated as links to another
+	// host by browsers.
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
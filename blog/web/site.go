@@ -0,0 +1,163 @@
+// Package web is the public site of the blog: HTML pages for readers,
+// rendered on the server with html/template.
+//
+// It shows the published blogs on a front page, one page per blog with its
+// comments, and one page per tag. Logged-in users can comment. The site
+// uses the same stores as the JSON API, so it works with every storage
+// backend.
+//
+// The templates and the static files are compiled into the binary with the
+// embed package, like the migrations, so the site never depends on files
+// being present on disk.
+//
+// For more information on html/template, see:
+// https://pkg.go.dev/html/template
+package web
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+
+	"blog/auth"
+	"blog/middleware"
+	"blog/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// files holds the embedded templates and static files.
+//
+//go:embed templates/*.html static
+var files embed.FS
+
+// pages lists the templates of the pages. Every page is parsed together with
+// the layout, which defines the parts that all pages share.
+var pages = []string{"index", "post", "login", "error"}
+
+// funcs are the functions that the templates can call.
+var funcs = template.FuncMap{
+	// sanitized marks HTML as safe, so that the template doesn't escape
+	// it. It must only be used for HTML that went through the sanitizer of
+	// the markdown package, such as the content_html of blogs.
+	"sanitized": func(s string) template.HTML {
+		return template.HTML(s)
+	},
+}
+
+// Site serves the pages of the public site.
+type Site struct {
+	blogs         models.BlogStore
+	users         models.UserStore
+	tokens        *auth.TokenManager
+	refreshTokens models.TokenStore
+	baseURL       string
+	secureCookies bool
+	templates     map[string]*template.Template
+	static        http.Handler
+}
+
+// NewSite returns a new Site. baseURL is the public URL of the blog, such
+// as "https://blog.example.com", which the feeds link to. secureCookies
+// makes browsers send the cookies of the site over HTTPS only. It returns
+// an error if a template can't be parsed.
+func NewSite(blogs models.BlogStore, users models.UserStore, tokens *auth.TokenManager, refreshTokens models.TokenStore, baseURL string, secureCookies bool) (*Site, error) {
+	s := &Site{
+		blogs:         blogs,
+		users:         users,
+		tokens:        tokens,
+		refreshTokens: refreshTokens,
+		baseURL:       baseURL,
+		secureCookies: secureCookies,
+		templates:     map[string]*template.Template{},
+	}
+
+	for _, name := range pages {
+		t, err := template.New(name).Funcs(funcs).ParseFS(files, "templates/layout.html", "templates/"+name+".html")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+		s.templates[name] = t
+	}
+
+	static, err := fs.Sub(files, "static")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open static files: %w", err)
+	}
+	s.static = http.StripPrefix("/static/", http.FileServer(http.FS(static)))
+
+	return s, nil
+}
+
+// Static serves the static files, such as the stylesheet, under /static/.
+func (s *Site) Static(ctx *gin.Context) {
+	// The files only change with the binary, so browsers may keep them for
+	// a while.
+	ctx.Header("Cache-Control", "public, max-age=3600")
+	s.static.ServeHTTP(ctx.Writer, ctx.Request)
+}
+
+// view is what every template gets: the title of the page, the user who is
+// logged in (or nil), the CSRF token for the forms, the path of the page,
+// and the data of the page itself.
+type view struct {
+	Title     string
+	User      *auth.Claims
+	CSRFToken string
+	Path      string
+	Data      any
+}
+
+// render writes a page with the given status code.
+//
+// The page is rendered into a buffer first, so that a template error
+// results in a clean 500 Internal Server Error rather than half a page.
+func (s *Site) render(ctx *gin.Context, status int, name, title string, data any) {
+	v := view{
+		Title:     title,
+		CSRFToken: s.csrfToken(ctx),
+		Path:      ctx.Request.URL.RequestURI(),
+		Data:      data,
+	}
+	if claims, ok := s.currentUser(ctx); ok {
+		v.User = &claims
+	}
+
+	var buf bytes.Buffer
+	if err := s.templates[name].ExecuteTemplate(&buf, "layout", v); err != nil {
+		log.Printf("[%s] Failed to render %s: %v", middleware.GetRequestID(ctx), name, err)
+		ctx.String(http.StatusInternalServerError, "An unexpected error occurred. Please try again later.")
+		return
+	}
+
+	ctx.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// errorPage is the data of the error page.
+type errorPage struct {
+	Message string
+}
+
+// renderError writes an error page for an error returned by the models.
+// Not found errors get a 404 page; other errors are logged and get a 500
+// page without any details, like in the JSON API.
+func (s *Site) renderError(ctx *gin.Context, action string, err error) {
+	if isNotFound(err) {
+		s.notFound(ctx)
+		return
+	}
+
+	log.Printf("[%s] %s: %v", middleware.GetRequestID(ctx), action, err)
+	s.render(ctx, http.StatusInternalServerError, "error", "Something went wrong",
+		errorPage{Message: "An unexpected error occurred. Please try again later."})
+}
+
+// notFound writes a 404 page.
+func (s *Site) notFound(ctx *gin.Context) {
+	s.render(ctx, http.StatusNotFound, "error", "Page not found",
+		errorPage{Message: "There is nothing here. It may have been moved or deleted."})
+}